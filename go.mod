@@ -12,6 +12,7 @@ require (
 	github.com/mackerelio/go-osstat v0.2.3
 	github.com/sc-js/pour v0.0.0-20230220153202-e036d480b976
 	go.mongodb.org/mongo-driver v1.11.3
+	golang.org/x/sync v0.1.0
 	gorm.io/gorm v1.24.3
 )
 
@@ -40,12 +41,10 @@ require (
 	github.com/mitchellh/go-homedir v1.1.0 // indirect
 	github.com/pkg/errors v0.9.1 // indirect
 	github.com/yuin/gopher-lua v1.0.0 // indirect
-	golang.org/x/sync v0.1.0 // indirect
 	howett.net/plist v1.0.0 // indirect
 )
 
 require (
-	github.com/Jeffail/gabs v1.4.0
 	github.com/jackc/pgpassfile v1.0.0 // indirect
 	github.com/jackc/pgservicefile v0.0.0-20221227161230-091c0ba34f0a // indirect
 	github.com/jinzhu/inflection v1.0.0 // indirect
@@ -74,10 +73,10 @@ require (
 	github.com/pelletier/go-toml/v2 v2.0.6 // indirect
 	github.com/speps/go-hashids v2.0.0+incompatible
 	github.com/twinj/uuid v1.0.0
-	github.com/ugorji/go/codec v1.2.8 // indirect
+	github.com/ugorji/go/codec v1.2.8
 	go.uber.org/zap v1.24.0
 	golang.org/x/crypto v0.5.0 // indirect
-	golang.org/x/net v0.5.0
+	golang.org/x/net v0.5.0 // indirect
 	golang.org/x/sys v0.4.0 // indirect
 	golang.org/x/text v0.6.0 // indirect
 	google.golang.org/protobuf v1.28.1 // indirect
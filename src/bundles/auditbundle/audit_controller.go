@@ -0,0 +1,39 @@
+package auditbundle
+
+import (
+	"github.com/sc-js/backend_core/src/bundles/deepcorebundle"
+	"github.com/sc-js/backend_core/src/tools"
+	"github.com/sc-js/pour"
+)
+
+type auditController struct {
+	deepcorebundle.Controller
+	DataWrap *tools.DataWrap
+}
+
+var controller *auditController
+var storage = STORAGE_POSTGRES
+
+func initialize(wrap *tools.DataWrap, settings map[string]string) *auditController {
+	c := &auditController{Controller: deepcorebundle.Controller{}, DataWrap: wrap}
+	handleSettings(settings, wrap)
+
+	if storage == STORAGE_POSTGRES {
+		deepcorebundle.RegisterModel(AuditEvent{}, []string{"created_at", "action"})
+	}
+	controller = c
+	return c
+}
+
+func handleSettings(settings map[string]string, wrap *tools.DataWrap) {
+	if settings == nil {
+		return
+	}
+	if settings["storage"] == STORAGE_MONGO {
+		if wrap.Mongo == nil {
+			pour.LogColor(false, pour.ColorYellow, "Audit storage set to mongo, but mongo is not connected, falling back to postgres")
+			return
+		}
+		storage = STORAGE_MONGO
+	}
+}
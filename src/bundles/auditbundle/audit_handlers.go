@@ -0,0 +1,103 @@
+package auditbundle
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sc-js/backend_core/src/mongowrap"
+	"github.com/sc-js/backend_core/src/tools"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const collectionName = "audit_event"
+
+// Paged audit event listing, supports the query filters action, actor, target, impersonator, success, request_id, ip, from and to
+// (from/to as RFC3339 timestamps). Newest events come first unless an order is given.
+func (con *auditController) getEventsHandler(c *gin.Context) {
+	query := c.Request.URL.Query()
+	if storage == STORAGE_MONGO {
+		con.getEventsMongo(c, query)
+		return
+	}
+
+	db := con.DataWrap.DB
+	for column, value := range getFilters(query) {
+		db = db.Where(column+"=?", value)
+	}
+	if from, ok := parseTime(query.Get("from")); ok {
+		db = db.Where("created_at >= ?", from)
+	}
+	if to, ok := parseTime(query.Get("to")); ok {
+		db = db.Where("created_at <= ?", to)
+	}
+	if len(query["order"]) == 0 {
+		db = db.Order("created_at DESC")
+	}
+
+	tools.GetPagedAndSend[AuditEvent](c, db)
+}
+
+func (con *auditController) getEventsMongo(c *gin.Context, query url.Values) {
+	where := mongowrap.Where{}
+	for column, value := range getFilters(query) {
+		where[column] = value
+	}
+	created := bson.M{}
+	if from, ok := parseTime(query.Get("from")); ok {
+		created["$gte"] = from
+	}
+	if to, ok := parseTime(query.Get("to")); ok {
+		created["$lte"] = to
+	}
+	if len(created) > 0 {
+		where["created_at"] = created
+	}
+
+	var page, perPage int64 = 0, 50
+	if parsed, err := strconv.ParseInt(query.Get("page"), 10, 64); err == nil && parsed >= 0 {
+		page = parsed
+	}
+	if parsed, err := strconv.ParseInt(query.Get("per_page"), 10, 64); err == nil && parsed > 0 {
+		perPage = parsed
+	}
+
+	mongoQuery := &mongowrap.Query{Where: &where, Sort: &mongowrap.Sort{"created_at": -1}, MongoPaging: &mongowrap.MongoPaging{Page: page, PerPage: perPage}, CollectionName: collectionName}
+	events, err := mongowrap.GetMany[AuditEvent](con.DataWrap.Mongo, mongoQuery, collectionName)
+	if err != nil {
+		tools.RespondError(err, http.StatusBadRequest, c)
+		return
+	}
+	count, err := mongowrap.GetTotalCount(con.DataWrap.Mongo, mongoQuery, collectionName)
+	if err != nil {
+		tools.RespondError(err, http.StatusBadRequest, c)
+		return
+	}
+	tools.RespondWithJSON(c, http.StatusOK, &tools.Paging{Page: uint64(page), PerPage: uint64(perPage), TotalCount: count, Data: events})
+}
+
+// Maps the equality query filters to their column names, ids are passed as hashids
+func getFilters(query url.Values) map[string]interface{} {
+	filters := map[string]interface{}{}
+	for param, column := range map[string]string{"action": "action", "request_id": "request_id", "ip": "ip"} {
+		if value := query.Get(param); len(value) > 0 {
+			filters[column] = value
+		}
+	}
+	for param, column := range map[string]string{"actor": "actor_id", "target": "target_id", "impersonator": "impersonator_id"} {
+		if value := query.Get(param); len(value) > 0 {
+			filters[column] = tools.Decode(value)
+		}
+	}
+	if success, err := strconv.ParseBool(query.Get("success")); err == nil {
+		filters["success"] = success
+	}
+	return filters
+}
+
+func parseTime(value string) (time.Time, bool) {
+	parsed, err := time.Parse(time.RFC3339, value)
+	return parsed, err == nil
+}
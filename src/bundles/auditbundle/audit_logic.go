@@ -0,0 +1,82 @@
+package auditbundle
+
+import (
+	"context"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sc-js/backend_core/src/mongowrap"
+	"github.com/sc-js/backend_core/src/tools"
+	"github.com/sc-js/pour"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Records an audit event, request related fields (IP, user agent, request id) are filled from the gin context if given.
+// Events are written asynchronously, so this never blocks or fails the calling handler.
+func Record(c *gin.Context, event AuditEvent) {
+	if controller == nil {
+		return
+	}
+	if c != nil {
+		event.IP = c.ClientIP()
+		event.UserAgent = c.Request.UserAgent()
+		event.RequestID = tools.GetRequestID(c)
+		if len(event.Subject) == 0 {
+			event.Subject = tools.GetSubject(c)
+		}
+		if event.ImpersonatorID == 0 {
+			event.ImpersonatorID = tools.GetImpersonator(c)
+		}
+	}
+	event.CreatedAt = time.Now()
+
+	go store(event)
+}
+
+func store(event AuditEvent) {
+	var err error
+	switch storage {
+	case STORAGE_MONGO:
+		_, err = mongowrap.PutOne(controller.DataWrap.Mongo, event)
+	default:
+		err = controller.DataWrap.DB.Create(&event).Error
+	}
+	if err != nil {
+		pour.LogColor(true, pour.ColorRed, "Error writing audit event", event.Action, err)
+	}
+}
+
+// Returns all events where the account is actor or target, used for data exports
+func GetEventsForAccount(id tools.ModelID) ([]AuditEvent, error) {
+	events := []AuditEvent{}
+	if controller == nil {
+		return events, nil
+	}
+	if storage == STORAGE_MONGO {
+		cursor, err := controller.DataWrap.Mongo.Database.Collection(collectionName).Find(context.TODO(), accountFilter(id))
+		if err != nil {
+			return events, err
+		}
+		err = cursor.All(context.TODO(), &events)
+		return events, err
+	}
+	err := controller.DataWrap.DB.Where("actor_id=? OR target_id=?", id, id).Order("created_at ASC").Find(&events).Error
+	return events, err
+}
+
+// Strips the personal request data (IP, user agent, details) from all events of an account.
+// The events themselves are kept, as they are still needed as a security record.
+func AnonymizeAccount(id tools.ModelID) error {
+	if controller == nil {
+		return nil
+	}
+	if storage == STORAGE_MONGO {
+		_, err := controller.DataWrap.Mongo.Database.Collection(collectionName).UpdateMany(context.TODO(), accountFilter(id), bson.M{"$set": bson.M{"ip": "", "user_agent": "", "details": ""}})
+		return err
+	}
+	return controller.DataWrap.DB.Model(&AuditEvent{}).Where("actor_id=? OR target_id=?", id, id).Updates(map[string]interface{}{"ip": "", "user_agent": "", "details": ""}).Error
+}
+
+func accountFilter(id tools.ModelID) bson.M {
+	return bson.M{"$or": []bson.M{{"actor_id": id}, {"target_id": id}}}
+}
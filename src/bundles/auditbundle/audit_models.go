@@ -0,0 +1,59 @@
+package auditbundle
+
+import (
+	"time"
+
+	"github.com/sc-js/backend_core/src/tools"
+)
+
+const (
+	ACTION_LOGIN                = "login"
+	ACTION_LOGIN_FAILED         = "login_failed"
+	ACTION_TOKEN_REFRESH        = "token_refresh"
+	ACTION_LOGOUT               = "logout"
+	ACTION_PASSWORD_CHANGE      = "password_change"
+	ACTION_ROLE_CHANGE          = "role_change"
+	ACTION_USER_DISABLE         = "user_disable"
+	ACTION_USER_ENABLE          = "user_enable"
+	ACTION_USER_DELETE          = "user_delete"
+	ACTION_USER_RESTORE         = "user_restore"
+	ACTION_VCLIENT              = "vclient_access"
+	ACTION_SERVICE_TOKEN        = "service_token"
+	ACTION_SERVICE_TOKEN_FAILED = "service_token_failed"
+	ACTION_IMPERSONATE_START    = "impersonate_start"
+	ACTION_IMPERSONATE_END      = "impersonate_end"
+	ACTION_IMPERSONATED_REQUEST = "impersonated_request"
+	ACTION_USER_EXPORT          = "user_export"
+	ACTION_ERASURE_REQUEST      = "erasure_request"
+	ACTION_ERASURE_CANCEL       = "erasure_cancel"
+	ACTION_USER_ERASED          = "user_erased"
+	ACTION_ADMIN_CALL           = "admin_call"
+	ACTION_REGISTER             = "register"
+	ACTION_INVITE_CREATE        = "invite_create"
+	ACTION_INVITE_REVOKE        = "invite_revoke"
+)
+
+const (
+	STORAGE_POSTGRES = "postgres"
+	STORAGE_MONGO    = "mongo"
+)
+
+// A single security relevant event, Actor is the account performing the action, Target the account it was performed on
+// The fields of tools.Model are declared here, so their bson names (created_at) only apply to audit documents.
+// Events are never changed or soft deleted.
+type AuditEvent struct {
+	ID        tools.ModelID `json:"id" bson:"id" gorm:"primaryKey;autoIncrement"`
+	CreatedAt time.Time     `json:"created_at" bson:"created_at" gorm:"index"`
+	Action    string        `json:"action" bson:"action" gorm:"index"`
+	ActorID   tools.ModelID `json:"actor_id" bson:"actor_id" gorm:"index"`
+	TargetID  tools.ModelID `json:"target_id" bson:"target_id" gorm:"index"`
+	// Who made the request, e.G. "user:12" or "service:<client id>". Service clients have no ActorID.
+	Subject string `json:"subject" bson:"subject" gorm:"index"`
+	// Set if the event happened during an impersonated session, ActorID is then the impersonated user
+	ImpersonatorID tools.ModelID `json:"impersonator_id,omitempty" bson:"impersonator_id,omitempty" gorm:"index"`
+	Success        bool          `json:"success" bson:"success"`
+	IP             string        `json:"ip" bson:"ip"`
+	UserAgent      string        `json:"user_agent" bson:"user_agent"`
+	RequestID      string        `json:"request_id" bson:"request_id" gorm:"index"`
+	Details        string        `json:"details" bson:"details"`
+}
@@ -0,0 +1,20 @@
+package auditbundle
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	t "github.com/sc-js/backend_core/src/tools"
+)
+
+var routes []t.GinRoute
+
+func InitBundle(r *gin.RouterGroup, wrap *t.DataWrap, autoMigrate bool, settings map[string]string) {
+	controller := initialize(wrap, settings)
+
+	routes = []t.GinRoute{
+		{Method: http.MethodGet, Endpoint: "/audit/events", Handler: controller.getEventsHandler, Permission: t.PERM_ADMIN},
+	}
+
+	t.InitHandlers(r, routes)
+}
@@ -0,0 +1,221 @@
+package authbundle
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sc-js/backend_core/src/bundles/auditbundle"
+	t "github.com/sc-js/backend_core/src/tools"
+	"github.com/sc-js/pour"
+	"gorm.io/gorm"
+)
+
+// Paged user listing for admins, supports the query filters search, disabled, admin and deleted
+// on top of the default paging/ordering of tools.GetPaged
+func (con *authController) getUsersHandler(c *gin.Context) {
+	db := con.DataWrap.DB.Where("user_type=?", USERTYPE_USER)
+	query := c.Request.URL.Query()
+
+	if search := strings.TrimSpace(query.Get("search")); len(search) > 0 {
+		like := "%" + strings.ToLower(search) + "%"
+		db = db.Where("LOWER(username) LIKE ? OR LOWER(email) LIKE ? OR LOWER(first_name) LIKE ? OR LOWER(last_name) LIKE ?", like, like, like, like)
+	}
+	if disabled, err := strconv.ParseBool(query.Get("disabled")); err == nil {
+		db = db.Where("disabled=?", disabled)
+	}
+	if admin, err := strconv.ParseBool(query.Get("admin")); err == nil {
+		db = db.Where("system_admin=?", admin)
+	}
+	if deleted, err := strconv.ParseBool(query.Get("deleted")); err == nil && deleted {
+		db = db.Unscoped().Where("deleted_at IS NOT NULL")
+	}
+
+	t.GetPagedAndSend[AuthUser](c, db)
+}
+
+func (con *authController) getUserByIdHandler(c *gin.Context) {
+	t.GetSingleByIdAndSend[AuthUser](c, con.DataWrap.DB.Unscoped())
+}
+
+func (con *authController) updateUserHandler(c *gin.Context) {
+	if !validLocaleUpdate(c) {
+		return
+	}
+	if user, err := t.Update[AuthUser](AuthUser{}, con.DataWrap.DB, c); err == nil {
+		invalidateUserLocale(user.ID)
+	}
+}
+
+// Self-service profile update, the id is always taken from the token so users can only edit themselves
+func (con *authController) updateOwnUserHandler(c *gin.Context) {
+	userId, clientType := GetUserIdFromRequest(c)
+	if clientType != CLIENT_TYPE_USER {
+		t.RespondError(errors.New("only_user"), http.StatusNotImplemented, c)
+		return
+	}
+	if !validLocaleUpdate(c) {
+		return
+	}
+	if _, err := t.UpdateById[AuthUser](AuthUser{}, userId, con.DataWrap.DB, c); err == nil {
+		invalidateUserLocale(userId)
+	}
+}
+
+// Rejects locales that don't resolve to a valid one. The body is put back for the update to bind it.
+func validLocaleUpdate(c *gin.Context) bool {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		t.RespondError(err, http.StatusBadRequest, c)
+		return false
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	req := localeUpdate{}
+	if json.Unmarshal(body, &req) != nil || len(req.Locale) == 0 {
+		return true
+	}
+	if _, ok := t.MatchLocale(req.Locale); !ok {
+		t.RespondError(errors.New("err_locale_invalid"), http.StatusBadRequest, c)
+		return false
+	}
+	return true
+}
+
+func (con *authController) disableUserHandler(c *gin.Context) {
+	req := disableRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil && c.Request.ContentLength > 0 {
+		t.RespondError(err, http.StatusBadRequest, c)
+		return
+	}
+	user, ok := con.getTargetUser(c, con.DataWrap.DB)
+	if !ok {
+		return
+	}
+	if err := con.DataWrap.DB.Model(&user).Updates(map[string]interface{}{"disabled": true, "disabled_reason": req.Reason}).Error; err != nil {
+		t.RespondError(err, http.StatusBadRequest, c)
+		return
+	}
+	blockUser(user.ID)
+	pour.LogColor(false, pour.ColorCyan, "AUTH -> User '"+user.Username+"' disabled:", req.Reason)
+	audit(c, auditbundle.ACTION_USER_DISABLE, adminId(c, con), user.ID, true, req.Reason)
+	t.RespondWithJSON(c, http.StatusOK, &user)
+}
+
+func (con *authController) enableUserHandler(c *gin.Context) {
+	user, ok := con.getTargetUser(c, con.DataWrap.DB)
+	if !ok {
+		return
+	}
+	if err := con.DataWrap.DB.Model(&user).Updates(map[string]interface{}{"disabled": false, "disabled_reason": ""}).Error; err != nil {
+		t.RespondError(err, http.StatusBadRequest, c)
+		return
+	}
+	unblockUser(user.ID)
+	pour.LogColor(false, pour.ColorCyan, "AUTH -> User '"+user.Username+"' enabled")
+	audit(c, auditbundle.ACTION_USER_ENABLE, adminId(c, con), user.ID, true, "")
+	t.RespondWithJSON(c, http.StatusOK, &user)
+}
+
+// Soft-deletes a user, the record can be brought back with the restore handler
+func (con *authController) deleteUserHandler(c *gin.Context) {
+	user, ok := con.getTargetUser(c, con.DataWrap.DB)
+	if !ok {
+		return
+	}
+	if err := con.DataWrap.DB.Delete(&user).Error; err != nil {
+		t.RespondError(err, http.StatusBadRequest, c)
+		return
+	}
+	blockUser(user.ID)
+	pour.LogColor(false, pour.ColorCyan, "AUTH -> User '"+user.Username+"' deleted")
+	audit(c, auditbundle.ACTION_USER_DELETE, adminId(c, con), user.ID, true, "")
+	t.RespondWithJSON(c, http.StatusOK, &user)
+}
+
+func (con *authController) restoreUserHandler(c *gin.Context) {
+	user, ok := con.getTargetUser(c, con.DataWrap.DB.Unscoped())
+	if !ok {
+		return
+	}
+	if err := con.DataWrap.DB.Unscoped().Model(&user).Update("deleted_at", nil).Error; err != nil {
+		t.RespondError(err, http.StatusBadRequest, c)
+		return
+	}
+	if !user.Disabled {
+		unblockUser(user.ID)
+	}
+	pour.LogColor(false, pour.ColorCyan, "AUTH -> User '"+user.Username+"' restored")
+	audit(c, auditbundle.ACTION_USER_RESTORE, adminId(c, con), user.ID, true, "")
+	t.RespondWithJSON(c, http.StatusOK, &user)
+}
+
+// Sets a new password for a user, if no password is given a random one is generated and returned once
+func (con *authController) resetPasswordHandler(c *gin.Context) {
+	if rejectImpersonation(c) {
+		return
+	}
+	req := passwordRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil && c.Request.ContentLength > 0 {
+		t.RespondError(err, http.StatusBadRequest, c)
+		return
+	}
+	user, ok := con.getTargetUser(c, con.DataWrap.DB)
+	if !ok {
+		return
+	}
+	generated := len(req.Password) == 0
+	if generated {
+		req.Password = randomHex(9)
+	}
+	if err := con.DataWrap.DB.Model(&user).Update("password", t.GetMD5(req.Password)).Error; err != nil {
+		t.RespondError(err, http.StatusBadRequest, c)
+		return
+	}
+	pour.LogColor(false, pour.ColorCyan, "AUTH -> Password of User '"+user.Username+"' was reset")
+	audit(c, auditbundle.ACTION_PASSWORD_CHANGE, adminId(c, con), user.ID, true, "admin_reset")
+	if generated {
+		t.RespondWithJSON(c, http.StatusOK, &passwordResponse{Password: req.Password})
+		return
+	}
+	t.RespondWithJSON(c, http.StatusOK, &passwordResponse{})
+}
+
+func (con *authController) toggleAdminHandler(c *gin.Context) {
+	user, ok := con.getTargetUser(c, con.DataWrap.DB)
+	if !ok {
+		return
+	}
+	admin := adminId(c, con)
+	if admin == user.ID {
+		t.RespondError(errors.New("err_self_action"), http.StatusBadRequest, c)
+		return
+	}
+	if err := con.DataWrap.DB.Model(&user).Update("system_admin", !user.SystemAdmin).Error; err != nil {
+		t.RespondError(err, http.StatusBadRequest, c)
+		return
+	}
+	pour.LogColor(false, pour.ColorCyan, "AUTH -> User '"+user.Username+"' admin:", user.SystemAdmin)
+	audit(c, auditbundle.ACTION_ROLE_CHANGE, admin, user.ID, true, fmt.Sprint("system_admin=", user.SystemAdmin))
+	t.RespondWithJSON(c, http.StatusOK, &user)
+}
+
+// Loads the user addressed by the "hid" route param, responds with not_found if it doesn't exist
+func (con *authController) getTargetUser(c *gin.Context, db *gorm.DB) (AuthUser, bool) {
+	user, err := t.GetSingleById[AuthUser](c, db.Where("user_type=?", USERTYPE_USER))
+	if err != nil {
+		t.RespondError(errors.New("not_found"), http.StatusNotFound, c)
+		return user, false
+	}
+	return user, true
+}
+
+func adminId(c *gin.Context, con *authController) t.ModelID {
+	_, id := GetIsAdminFromRequest(c, con.DataWrap.DB)
+	return id
+}
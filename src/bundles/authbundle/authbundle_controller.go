@@ -3,6 +3,7 @@ package authbundle
 import (
 	"github.com/sc-js/backend_core/src/bundles/cachebundle"
 	"github.com/sc-js/backend_core/src/bundles/deepcorebundle"
+	"github.com/sc-js/backend_core/src/bundles/jobbundle"
 	"github.com/sc-js/backend_core/src/tools"
 	"github.com/sc-js/pour"
 )
@@ -18,7 +19,15 @@ func initialize(wrap *tools.DataWrap, settings map[string]string) *authControlle
 	handleSettings(settings, wrap)
 	ReloadVClients(wrap)
 
-	deepcorebundle.RegisterModel(AuthUser{}, []string{"first_name"})
+	deepcorebundle.RegisterModel(AuthUser{}, []string{"first_name", "last_name", "username", "email", "created_at"})
+	deepcorebundle.RegisterModel(ServiceClient{}, []string{"name", "created_at"})
+	deepcorebundle.RegisterModel(Invitation{}, []string{"email", "revoked", "admin", "created_at"})
+
+	registerDefaultUserDataHandlers()
+	tools.UserLocaleCallback = userLocale(wrap.DB)
+	if err := jobbundle.Schedule("user_erasure", "@hourly", c.processScheduledErasures); err != nil {
+		pour.LogErr(err)
+	}
 	return c
 }
 
@@ -27,7 +36,19 @@ func handleSettings(settings map[string]string, warp *tools.DataWrap) {
 		return
 	} else {
 		signSecret = settings["jwt_secret"]
-		refreshSecret = settings["refresh_secret"]
+		// The key used to be refresh_secret, which initbundle never set (refresh tokens were signed with an empty secret).
+		// Settings passed with the old key keep working, sessions whose refresh token was signed with the empty secret have to log in again.
+		refreshSecret = settings["jwt_refresh_secret"]
+		if len(refreshSecret) == 0 && len(settings["refresh_secret"]) > 0 {
+			refreshSecret = settings["refresh_secret"]
+			pour.LogColor(false, pour.ColorYellow, "AUTH -> The refresh_secret setting is deprecated, use jwt_refresh_secret")
+		}
+		handleSessionSettings(settings)
+		handleServiceSettings(settings)
+		handleImpersonationSettings(settings)
+		handleGDPRSettings(settings)
+		handlePasswordlessSettings(settings)
+		handleRegistrationSettings(settings)
 	}
 }
 
@@ -0,0 +1,312 @@
+package authbundle
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sc-js/backend_core/src/bundles/auditbundle"
+	t "github.com/sc-js/backend_core/src/tools"
+	"github.com/sc-js/pour"
+)
+
+const (
+	ERASURE_MODE_ANONYMIZE = "anonymize"
+	ERASURE_MODE_ERASE     = "erase"
+)
+
+// Returns everything a bundle stores about a user, it is written as <name>.json into the data export
+type UserDataExporter func(userId t.ModelID, wrap *t.DataWrap) (interface{}, error)
+
+// Removes or anonymizes everything a bundle stores about a user
+type UserDataEraser func(userId t.ModelID, wrap *t.DataWrap, anonymize bool) error
+
+type userDataHandler struct {
+	Exporter UserDataExporter
+	Eraser   UserDataEraser
+}
+
+var userDataHandlers map[string]userDataHandler = make(map[string]userDataHandler)
+
+var erasureGracePeriod = time.Hour * 24 * 30
+
+// Register the exporter and eraser of a bundle, so its models are part of data exports and account erasure.
+// Either of both may be nil.
+func RegisterUserDataHandler(name string, exporter UserDataExporter, eraser UserDataEraser) {
+	userDataHandlers[name] = userDataHandler{Exporter: exporter, Eraser: eraser}
+}
+
+func handleGDPRSettings(settings map[string]string) {
+	if grace, err := time.ParseDuration(settings["erasure_grace_period"]); err == nil && grace >= 0 {
+		erasureGracePeriod = grace
+	}
+}
+
+func registerDefaultUserDataHandlers() {
+	RegisterUserDataHandler("audit_events", func(userId t.ModelID, wrap *t.DataWrap) (interface{}, error) {
+		return auditbundle.GetEventsForAccount(userId)
+	}, func(userId t.ModelID, wrap *t.DataWrap, anonymize bool) error {
+		return auditbundle.AnonymizeAccount(userId)
+	})
+}
+
+// Builds a zip with the user record, the data of all registered bundles and the user's files
+func (con *authController) buildUserExport(user AuthUser) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	archive := zip.NewWriter(buf)
+
+	if err := writeZipJSON(archive, "user.json", &user); err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for name := range userDataHandlers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		handler := userDataHandlers[name]
+		if handler.Exporter == nil {
+			continue
+		}
+		data, err := handler.Exporter(user.ID, con.DataWrap)
+		if err != nil {
+			return nil, err
+		}
+		if err := writeZipJSON(archive, name+".json", data); err != nil {
+			return nil, err
+		}
+	}
+
+	userDir := userDirectory(user.ID)
+	if t.Exists(userDir) {
+		err := filepath.WalkDir(userDir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			rel, err := filepath.Rel(userDir, path)
+			if err != nil {
+				return err
+			}
+			return writeZipFile(archive, "files/"+filepath.ToSlash(rel), path)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Erases or anonymizes a user right away. Bundle erasers run first, if one fails the user record is kept so the erasure can be retried.
+func (con *authController) eraseUser(user AuthUser, mode string) error {
+	anonymize := mode != ERASURE_MODE_ERASE
+	for name, handler := range userDataHandlers {
+		if handler.Eraser == nil {
+			continue
+		}
+		if err := handler.Eraser(user.ID, con.DataWrap, anonymize); err != nil {
+			pour.LogColor(false, pour.ColorRed, "AUTH -> Erasure handler", name, "failed for User", t.Encode(user.ID), err)
+			return err
+		}
+	}
+
+	if err := os.RemoveAll(userDirectory(user.ID)); err != nil {
+		return err
+	}
+	blockUser(user.ID)
+
+	if !anonymize {
+		pour.LogColor(false, pour.ColorCyan, "AUTH -> Erasing User", t.Encode(user.ID))
+		return con.DataWrap.DB.Unscoped().Delete(&user).Error
+	}
+
+	pour.LogColor(false, pour.ColorCyan, "AUTH -> Anonymizing User", t.Encode(user.ID))
+	err := con.DataWrap.DB.Unscoped().Model(&user).Updates(map[string]interface{}{
+		"first_name":           "",
+		"last_name":            "",
+		"email":                "",
+		"username":             "deleted_" + t.Encode(user.ID),
+		"password":             "",
+		"disabled":             true,
+		"disabled_reason":      "erased",
+		"erasure_scheduled_at": nil,
+		"erasure_mode":         "",
+	}).Error
+	if err != nil {
+		return err
+	}
+	return con.DataWrap.DB.Delete(&user).Error
+}
+
+// Erases all users whose grace period has passed, runs as the scheduled "user_erasure" task
+func (con *authController) processScheduledErasures(ctx context.Context) error {
+	users := []AuthUser{}
+	if err := con.DataWrap.DB.Unscoped().Where("erasure_scheduled_at IS NOT NULL AND erasure_scheduled_at <= ?", time.Now()).Find(&users).Error; err != nil {
+		return err
+	}
+	var errs []error
+	for _, user := range users {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err := con.eraseUser(user, user.ErasureMode); err != nil {
+			pour.LogErr(err)
+			errs = append(errs, err)
+			continue
+		}
+		auditbundle.Record(nil, auditbundle.AuditEvent{Action: auditbundle.ACTION_USER_ERASED, TargetID: user.ID, Success: true, Details: user.ErasureMode})
+	}
+	return errors.Join(errs...)
+}
+
+func (con *authController) exportOwnUserHandler(c *gin.Context) {
+	if rejectImpersonation(c) {
+		return
+	}
+	user, err := GetUserFromRequest(c, con.DataWrap.DB)
+	if err != nil {
+		t.RespondError(errors.New("not_authorized"), http.StatusForbidden, c)
+		return
+	}
+	con.sendUserExport(c, user)
+}
+
+func (con *authController) exportUserHandler(c *gin.Context) {
+	user, ok := con.getTargetUser(c, con.DataWrap.DB.Unscoped())
+	if !ok {
+		return
+	}
+	con.sendUserExport(c, user)
+}
+
+func (con *authController) sendUserExport(c *gin.Context, user AuthUser) {
+	data, err := con.buildUserExport(user)
+	if err != nil {
+		pour.LogErr(err)
+		t.RespondError(err, http.StatusInternalServerError, c, "internal_error")
+		return
+	}
+	userId, _ := GetUserIdFromRequest(c)
+	audit(c, auditbundle.ACTION_USER_EXPORT, userId, user.ID, true, "")
+	c.Header("Content-Disposition", "attachment; filename=export_"+t.Encode(user.ID)+".zip")
+	c.Data(http.StatusOK, "application/zip", data)
+}
+
+// Schedules the erasure of the requesting user after the grace period, it can be cancelled until then
+func (con *authController) requestErasureHandler(c *gin.Context) {
+	if rejectImpersonation(c) {
+		return
+	}
+	req := erasureRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil && c.Request.ContentLength > 0 {
+		t.RespondError(err, http.StatusBadRequest, c)
+		return
+	}
+	user, err := GetUserFromRequest(c, con.DataWrap.DB)
+	if err != nil {
+		t.RespondError(errors.New("not_authorized"), http.StatusForbidden, c)
+		return
+	}
+	scheduled := time.Now().Add(erasureGracePeriod)
+	if err := con.DataWrap.DB.Model(&user).Updates(map[string]interface{}{"erasure_scheduled_at": scheduled, "erasure_mode": erasureMode(req.Mode)}).Error; err != nil {
+		t.RespondError(err, http.StatusBadRequest, c)
+		return
+	}
+	audit(c, auditbundle.ACTION_ERASURE_REQUEST, user.ID, user.ID, true, erasureMode(req.Mode))
+	t.RespondWithJSON(c, http.StatusOK, &user)
+}
+
+func (con *authController) cancelErasureHandler(c *gin.Context) {
+	if rejectImpersonation(c) {
+		return
+	}
+	user, err := GetUserFromRequest(c, con.DataWrap.DB)
+	if err != nil {
+		t.RespondError(errors.New("not_authorized"), http.StatusForbidden, c)
+		return
+	}
+	if err := con.DataWrap.DB.Model(&user).Updates(map[string]interface{}{"erasure_scheduled_at": nil, "erasure_mode": ""}).Error; err != nil {
+		t.RespondError(err, http.StatusBadRequest, c)
+		return
+	}
+	audit(c, auditbundle.ACTION_ERASURE_CANCEL, user.ID, user.ID, true, "")
+	t.RespondWithJSON(c, http.StatusOK, &user)
+}
+
+// Admin variant, either schedules the erasure like the user would or erases right away with "immediate"
+func (con *authController) eraseUserHandler(c *gin.Context) {
+	req := erasureRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil && c.Request.ContentLength > 0 {
+		t.RespondError(err, http.StatusBadRequest, c)
+		return
+	}
+	user, ok := con.getTargetUser(c, con.DataWrap.DB.Unscoped())
+	if !ok {
+		return
+	}
+	mode := erasureMode(req.Mode)
+	if !req.Immediate {
+		if err := con.DataWrap.DB.Unscoped().Model(&user).Updates(map[string]interface{}{"erasure_scheduled_at": time.Now().Add(erasureGracePeriod), "erasure_mode": mode}).Error; err != nil {
+			t.RespondError(err, http.StatusBadRequest, c)
+			return
+		}
+		audit(c, auditbundle.ACTION_ERASURE_REQUEST, adminId(c, con), user.ID, true, mode)
+		t.RespondWithJSON(c, http.StatusOK, &user)
+		return
+	}
+	if err := con.eraseUser(user, mode); err != nil {
+		t.RespondError(err, http.StatusInternalServerError, c, "internal_error")
+		return
+	}
+	audit(c, auditbundle.ACTION_USER_ERASED, adminId(c, con), user.ID, true, mode)
+	t.RespondWithJSON(c, http.StatusOK, &user)
+}
+
+func erasureMode(mode string) string {
+	if mode == ERASURE_MODE_ERASE {
+		return ERASURE_MODE_ERASE
+	}
+	return ERASURE_MODE_ANONYMIZE
+}
+
+func userDirectory(id t.ModelID) string {
+	return t.DOCKER_PATH + "/users/" + t.Encode(id)
+}
+
+func writeZipJSON(archive *zip.Writer, name string, data interface{}) error {
+	w, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(data)
+}
+
+func writeZipFile(archive *zip.Writer, name string, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, f)
+	return err
+}
@@ -10,6 +10,8 @@ import (
 
 	"github.com/dgrijalva/jwt-go"
 	"github.com/gin-gonic/gin"
+	"github.com/sc-js/backend_core/src/bundles/auditbundle"
+	"github.com/sc-js/backend_core/src/bundles/cachebundle"
 	t "github.com/sc-js/backend_core/src/tools"
 	"github.com/sc-js/pour"
 )
@@ -26,23 +28,11 @@ func (con *authController) logoutHandler(c *gin.Context) {
 		t.RespondError(err, http.StatusUnauthorized, c, "not_authorized")
 		return
 	}
-	t.RespondWithJSON(c, http.StatusOK, "Successfully logged out")
-}
-
-func (con *authController) registerHandler(c *gin.Context) {
-	user := AuthUser{}
-	if err := c.BindJSON(&user); err != nil {
-		t.RespondError(err, http.StatusForbidden, c)
-		return
+	audit(c, auditbundle.ACTION_LOGOUT, t.ModelID(tokenAuth.UserId), t.ModelID(tokenAuth.UserId), true, "")
+	if usesCookieSession(c.Request) {
+		clearSessionCookies(c)
 	}
-	user.Password = t.GetMD5(user.Password)
-	user.UserType = USERTYPE_USER
-	con.DataWrap.DB.Create(&user)
-	t.RespondWithJSON(c, http.StatusOK, &user)
-}
-
-func (con *authController) updateUserHandler(c *gin.Context) {
-	t.Update[AuthUser](AuthUser{}, con.DataWrap.DB, c)
+	t.RespondWithJSON(c, http.StatusOK, "Successfully logged out")
 }
 
 func (con *authController) uploadUserByIdImageHandler(c *gin.Context) {
@@ -51,41 +41,48 @@ func (con *authController) uploadUserByIdImageHandler(c *gin.Context) {
 		t.RespondError(errors.New("not_found"), http.StatusNotFound, c)
 		return
 	}
-	userId, _ := GetUserIdFromRequest(c)
-	if user.ID == userId {
-		t.SaveUploadedFile(c, "users/"+t.Encode(userId)+"/images", user.ID, "avatar.jpg", true)
+	userId, clientType := GetUserIdFromRequest(c)
+	if clientType != CLIENT_TYPE_USER {
+		t.RespondError(errors.New("only_user"), http.StatusNotImplemented, c)
 		return
 	}
 	ad, _ := GetIsAdminFromRequest(c, con.DataWrap.DB)
-	if ad {
-		t.SaveUploadedFile(c, "users/"+t.Encode(userId)+"/images", user.ID, "avatar.jpg", true)
+	if user.ID == userId || ad {
+		t.SaveUploadedFile(c, "users/"+t.Encode(user.ID)+"/images", userId, "avatar.jpg", true)
 		return
 	}
 	t.RespondError(errors.New("not_authorized"), http.StatusUnauthorized, c)
 }
 
-// TODO
 func (con *authController) deleteUserByIdImageHandler(c *gin.Context) {
 	user, err := t.GetSingleById[AuthUser](c, con.DataWrap.DB)
 	if err != nil {
 		t.RespondError(errors.New("not_found"), http.StatusNotFound, c)
 		return
 	}
-	userId, _ := GetUserIdFromRequest(c)
-	if user.ID == userId {
-		t.SaveUploadedFile(c, "users/"+t.Encode(userId)+"/images", user.ID, "avatar.jpg", true)
+	userId, clientType := GetUserIdFromRequest(c)
+	if clientType != CLIENT_TYPE_USER {
+		t.RespondError(errors.New("only_user"), http.StatusNotImplemented, c)
 		return
 	}
 	ad, _ := GetIsAdminFromRequest(c, con.DataWrap.DB)
-	if ad {
-		t.SaveUploadedFile(c, "users/"+t.Encode(userId)+"/images", user.ID, "avatar.jpg", true)
+	if user.ID != userId && !ad {
+		t.RespondError(errors.New("not_authorized"), http.StatusUnauthorized, c)
 		return
 	}
-	t.RespondError(errors.New("not_authorized"), http.StatusUnauthorized, c)
+	if err := os.Remove(t.DOCKER_PATH + "/users/" + t.Encode(user.ID) + "/images/avatar.jpg"); err != nil && !os.IsNotExist(err) {
+		t.RespondError(err, http.StatusInternalServerError, c, "internal_error")
+		return
+	}
+	t.RespondWithJSON(c, http.StatusOK, &user)
 }
 
 func (con *authController) getUserImageHandler(c *gin.Context) {
-	userId, _ := GetUserIdFromRequest(c)
+	userId, clientType := GetUserIdFromRequest(c)
+	if clientType != CLIENT_TYPE_USER {
+		t.RespondError(errors.New("only_user"), http.StatusNotImplemented, c)
+		return
+	}
 	t.ServeFile(c, "users/"+t.Encode(userId)+"/images/avatar.jpg", userId)
 }
 
@@ -112,10 +109,6 @@ func (con *authController) uploadUserImageHandler(c *gin.Context) {
 	t.SaveUploadedFile(c, "users/"+t.Encode(userId)+"/images", userId, "avatar.jpg", true)
 }
 
-func (con *authController) getUserByIdHandler(c *gin.Context) {
-	t.GetSingleByIdAndSend[AuthUser](c, con.DataWrap.DB)
-}
-
 func (con *authController) getUserHandler(c *gin.Context) {
 	user := AuthUser{}
 	details, err := ExtractTokenMetadata(c.Request)
@@ -123,9 +116,16 @@ func (con *authController) getUserHandler(c *gin.Context) {
 		t.RespondError(errors.New("not_authorized"), http.StatusForbidden, c)
 		return
 	}
+	// Service tokens carry the id of the service client, which must not be read as a user id
+	if details.Service {
+		t.RespondError(errors.New("only_user"), http.StatusNotImplemented, c)
+		return
+	}
 	uid, err := FetchAuth(details)
-
-	if err != nil {
+	if cachebundle.IsUnavailable(err) {
+		t.RespondError(errors.New("cache_unavailable"), http.StatusServiceUnavailable, c)
+		return
+	} else if err != nil {
 		t.RespondError(errors.New("not_authorized"), http.StatusForbidden, c)
 		return
 	}
@@ -147,18 +147,32 @@ func (con *authController) loginHandler(c *gin.Context) {
 	cryptPassword := t.GetMD5(user.Password)
 	var u AuthUser
 	if err := con.DataWrap.DB.Where("username = ? AND password = ?", user.Username, cryptPassword).First(&u).Error; err != nil {
+		audit(c, auditbundle.ACTION_LOGIN_FAILED, 0, 0, false, user.Username)
 		t.RespondError(errors.New("not_found"), http.StatusForbidden, c)
 		return
 	}
 
 	if user.Username != u.Username || cryptPassword != u.Password {
+		audit(c, auditbundle.ACTION_LOGIN_FAILED, 0, u.ID, false, user.Username)
 		t.RespondError(errors.New("bad_login"), http.StatusUnauthorized, c)
 		return
 	}
 
+	if u.Disabled {
+		audit(c, auditbundle.ACTION_LOGIN_FAILED, u.ID, u.ID, false, "account_disabled")
+		t.RespondError(errors.New("account_disabled"), http.StatusForbidden, c)
+		return
+	}
+
+	con.issueLogin(c, u, "")
+}
+
+// Creates a new token pair for the user and responds with the user and its tokens, or
+// sets the session cookies if the client asked for a cookie session
+func (con *authController) issueLogin(c *gin.Context, u AuthUser, method string) {
 	token, err := CreateToken(uint64(u.ID))
 	if err != nil {
-		log.Println("1")
+		log.Println(err)
 		t.RespondError(errors.New("auth_error"), http.StatusUnauthorized, c)
 		return
 	}
@@ -173,6 +187,9 @@ func (con *authController) loginHandler(c *gin.Context) {
 		"access_token":  token.AccessToken,
 		"refresh_token": token.RefreshToken,
 	}
+	if wantsCookieSession(c) {
+		tokens = map[string]string{"csrf_token": setSessionCookies(c, token)}
+	}
 
 	sendToken := UserLogin{
 		User:   u,
@@ -180,24 +197,24 @@ func (con *authController) loginHandler(c *gin.Context) {
 	}
 
 	t.RespondWithJSON(c, http.StatusOK, sendToken)
-	pour.LogColor(false, pour.ColorCyan, "AUTH -> User '"+user.Username+"' logged in")
+	audit(c, auditbundle.ACTION_LOGIN, u.ID, u.ID, true, method)
+	pour.LogColor(false, pour.ColorCyan, "AUTH -> User '"+u.Username+"' logged in")
 }
 
 func (con *authController) refreshHandler(c *gin.Context) {
 	mapToken := map[string]string{}
-	if err := c.BindJSON(&mapToken); err != nil {
+	if err := c.ShouldBindJSON(&mapToken); err != nil && c.Request.ContentLength > 0 {
 		t.RespondError(errors.New("internal_error"), http.StatusUnprocessableEntity, c)
 		return
 	}
 	refreshToken := mapToken["refresh_token"]
+	cookieSession := false
+	if len(refreshToken) == 0 {
+		refreshToken = sessionCookie(c.Request, COOKIE_REFRESH_TOKEN)
+		cookieSession = len(refreshToken) > 0
+	}
 
-	os.Setenv("REFRESH_SECRET", refreshSecret) //this should be in an env file
-	token, err := jwt.Parse(refreshToken, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(refreshSecret), nil
-	})
+	token, err := ExtractJWTRefreshToken(refreshToken)
 	if err != nil {
 		t.RespondError(errors.New("auth_error"), http.StatusUnprocessableEntity, c)
 		return
@@ -219,7 +236,10 @@ func (con *authController) refreshHandler(c *gin.Context) {
 			return
 		}
 		deleted, delErr := DeleteAuth(refreshUuid, con)
-		if delErr != nil || deleted == 0 {
+		if cachebundle.IsUnavailable(delErr) {
+			t.RespondError(errors.New("cache_unavailable"), http.StatusServiceUnavailable, c)
+			return
+		} else if delErr != nil || deleted == 0 {
 			t.RespondError(errors.New("not_authorized"), http.StatusUnauthorized, c)
 			return
 		}
@@ -233,11 +253,12 @@ func (con *authController) refreshHandler(c *gin.Context) {
 			t.RespondError(errors.New("not_authorized"), http.StatusUnprocessableEntity, c)
 			return
 		}
-		tokens := map[string]string{
-			"access_token":  ts.AccessToken,
-			"refresh_token": ts.RefreshToken,
+		tokens := tokenResponse{AccessToken: ts.AccessToken, RefreshToken: ts.RefreshToken}
+		if cookieSession || wantsCookieSession(c) {
+			tokens = tokenResponse{CSRFToken: setSessionCookies(c, ts)}
 		}
 		t.RespondWithJSON(c, http.StatusOK, &tokens)
+		audit(c, auditbundle.ACTION_TOKEN_REFRESH, t.ModelID(userId), t.ModelID(userId), true, "")
 	} else {
 		t.RespondError(errors.New("auth_error"), http.StatusUnprocessableEntity, c)
 	}
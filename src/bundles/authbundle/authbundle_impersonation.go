@@ -0,0 +1,105 @@
+package authbundle
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gin-gonic/gin"
+	"github.com/sc-js/backend_core/src/bundles/auditbundle"
+	"github.com/sc-js/backend_core/src/bundles/cachebundle"
+	t "github.com/sc-js/backend_core/src/tools"
+	"github.com/sc-js/pour"
+	"github.com/twinj/uuid"
+)
+
+var impersonationTTL = time.Hour
+
+func handleImpersonationSettings(settings map[string]string) {
+	if ttl, err := time.ParseDuration(settings["impersonation_ttl"]); err == nil && ttl > 0 {
+		impersonationTTL = ttl
+	}
+}
+
+// Create a short-lived access token for the target user which also carries the impersonating admin.
+// There is no refresh token, the session simply ends when the token expires.
+func CreateImpersonationToken(adminId t.ModelID, targetId t.ModelID) (*TokenDetails, error) {
+	td := &TokenDetails{}
+	td.AtExpires = time.Now().Add(impersonationTTL).Unix()
+	td.AccessUuid = uuid.NewV4().String()
+
+	atClaims := jwt.MapClaims{}
+	atClaims["authorized"] = true
+	atClaims["access_uuid"] = td.AccessUuid
+	atClaims["user_id"] = uint64(targetId)
+	atClaims["impersonator_id"] = uint64(adminId)
+	atClaims["exp"] = td.AtExpires
+	at := jwt.NewWithClaims(jwt.SigningMethodHS256, atClaims)
+
+	var err error
+	td.AccessToken, err = at.SignedString([]byte(signSecret))
+	if err != nil {
+		return nil, err
+	}
+	if err := cachebundle.PutExpire("user_session", td.AccessUuid, int(targetId), impersonationTTL); err != nil {
+		return nil, err
+	}
+	// Indexed with the target's sessions, so revoking them ends the impersonation as well
+	indexSessions(uint64(targetId), impersonationTTL, td.AccessUuid)
+	return td, nil
+}
+
+// Impersonated sessions act as the user, but must not export or erase the account or change its password.
+// Responds and returns true for such sessions.
+func rejectImpersonation(c *gin.Context) bool {
+	if tokenAuth, err := ExtractTokenMetadata(c.Request); err == nil && tokenAuth.ImpersonatorId > 0 {
+		t.RespondError(errors.New("err_impersonation_not_allowed"), http.StatusForbidden, c)
+		return true
+	}
+	return false
+}
+
+func (con *authController) impersonateHandler(c *gin.Context) {
+	tokenAuth, err := ExtractTokenMetadata(c.Request)
+	if err != nil || tokenAuth.Service || tokenAuth.ImpersonatorId > 0 {
+		t.RespondError(errors.New("err_impersonation_not_allowed"), http.StatusForbidden, c)
+		return
+	}
+	admin := t.ModelID(tokenAuth.UserId)
+
+	target, ok := con.getTargetUser(c, con.DataWrap.DB)
+	if !ok {
+		return
+	}
+	if target.ID == admin || target.SystemAdmin || target.Disabled {
+		t.RespondError(errors.New("err_impersonation_not_allowed"), http.StatusForbidden, c)
+		return
+	}
+
+	td, err := CreateImpersonationToken(admin, target.ID)
+	if err != nil {
+		pour.LogColor(true, pour.ColorRed, err)
+		t.RespondError(errors.New("auth_error"), http.StatusInternalServerError, c)
+		return
+	}
+	pour.LogColor(false, pour.ColorCyan, "AUTH -> Admin", t.Encode(admin), "impersonating User '"+target.Username+"'")
+	audit(c, auditbundle.ACTION_IMPERSONATE_START, admin, target.ID, true, "")
+
+	t.RespondWithJSON(c, http.StatusOK, &UserLogin{User: target, Tokens: map[string]string{"access_token": td.AccessToken}})
+}
+
+// Ends an impersonated session by revoking its token, the admin's own session is untouched
+func (con *authController) endImpersonationHandler(c *gin.Context) {
+	tokenAuth, err := ExtractTokenMetadata(c.Request)
+	if err != nil || tokenAuth.ImpersonatorId == 0 {
+		t.RespondError(errors.New("err_not_impersonating"), http.StatusBadRequest, c)
+		return
+	}
+	if _, err := DeleteAuth(tokenAuth.AccessUuid, con); err != nil {
+		t.RespondError(errors.New("not_authorized"), http.StatusUnauthorized, c)
+		return
+	}
+	audit(c, auditbundle.ACTION_IMPERSONATE_END, t.ModelID(tokenAuth.ImpersonatorId), t.ModelID(tokenAuth.UserId), true, "")
+	t.RespondWithJSON(c, http.StatusOK, "Impersonation ended")
+}
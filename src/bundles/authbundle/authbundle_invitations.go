@@ -0,0 +1,187 @@
+package authbundle
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/sc-js/backend_core/src/bundles/auditbundle"
+	"github.com/sc-js/backend_core/src/bundles/mailbundle"
+	t "github.com/sc-js/backend_core/src/tools"
+	"github.com/sc-js/pour"
+	"gorm.io/gorm"
+)
+
+const (
+	REGISTRATION_OPEN   = "open"
+	REGISTRATION_INVITE = "invite"
+	REGISTRATION_CLOSED = "closed"
+)
+
+var registrationMode = REGISTRATION_OPEN
+var inviteURL = ""
+
+func handleRegistrationSettings(settings map[string]string) {
+	switch strings.ToLower(settings["registration"]) {
+	case REGISTRATION_INVITE:
+		registrationMode = REGISTRATION_INVITE
+	case REGISTRATION_CLOSED:
+		registrationMode = REGISTRATION_CLOSED
+	default:
+		registrationMode = REGISTRATION_OPEN
+	}
+	inviteURL = settings["invite_url"]
+}
+
+func (con *authController) registerHandler(c *gin.Context) {
+	req := registerRequest{}
+	user := AuthUser{}
+	if err := c.ShouldBindBodyWith(&user, binding.JSON); err != nil {
+		t.RespondError(err, http.StatusForbidden, c)
+		return
+	}
+	if err := c.ShouldBindBodyWith(&req, binding.JSON); err != nil {
+		t.RespondError(err, http.StatusForbidden, c)
+		return
+	}
+	user.ID = 0
+	user.Password = t.GetMD5(user.Password)
+	user.UserType = USERTYPE_USER
+	user.SystemAdmin = false
+	user.Disabled = false
+	user.DisabledReason = ""
+
+	if registrationMode != REGISTRATION_INVITE {
+		if err := con.DataWrap.DB.Create(&user).Error; err != nil {
+			t.RespondError(err, http.StatusBadRequest, c)
+			return
+		}
+		audit(c, auditbundle.ACTION_REGISTER, user.ID, user.ID, true, "")
+		t.RespondWithJSON(c, http.StatusOK, &user)
+		return
+	}
+
+	if len(req.Invite) == 0 {
+		t.RespondError(errors.New("err_invite_required"), http.StatusForbidden, c)
+		return
+	}
+	invite := Invitation{}
+	err := con.DataWrap.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		invite, err = consumeInvitation(tx, req.Invite, user.Email)
+		if err != nil {
+			return err
+		}
+		user.SystemAdmin = invite.Admin
+		return tx.Create(&user).Error
+	})
+	if err != nil {
+		audit(c, auditbundle.ACTION_REGISTER, 0, 0, false, err.Error())
+		if err.Error() == "err_invite_invalid" {
+			t.RespondError(err, http.StatusForbidden, c)
+			return
+		}
+		t.RespondError(err, http.StatusBadRequest, c)
+		return
+	}
+	audit(c, auditbundle.ACTION_REGISTER, user.ID, user.ID, true, "invite="+t.Encode(invite.ID))
+	t.RespondWithJSON(c, http.StatusOK, &user)
+}
+
+// Takes one use of the invitation, the conditions are part of the update so concurrent registrations can't overuse it
+func consumeInvitation(tx *gorm.DB, code string, email string) (Invitation, error) {
+	invite := Invitation{}
+	res := tx.Model(&Invitation{}).
+		Where("code = ? AND revoked = ?", code, false).
+		Where("max_uses = 0 OR uses < max_uses").
+		Where("expires_at IS NULL OR expires_at > ?", time.Now()).
+		Where("email = '' OR LOWER(email) = ?", normalizeEmail(email)).
+		UpdateColumn("uses", gorm.Expr("uses + 1"))
+	if res.Error != nil {
+		return invite, res.Error
+	}
+	if res.RowsAffected != 1 {
+		return invite, errors.New("err_invite_invalid")
+	}
+	if err := tx.Where("code = ?", code).First(&invite).Error; err != nil {
+		return invite, err
+	}
+	return invite, nil
+}
+
+func (con *authController) getInvitationsHandler(c *gin.Context) {
+	t.GetPagedAndSend[Invitation](c, con.DataWrap.DB)
+}
+
+// Creates an invitation, if it is bound to an email and send_mail is set the code is mailed to the invitee
+func (con *authController) createInvitationHandler(c *gin.Context) {
+	req := invitationRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil && c.Request.ContentLength > 0 {
+		t.RespondError(err, http.StatusBadRequest, c)
+		return
+	}
+	invite := Invitation{
+		Code:      randomHex(16),
+		Email:     normalizeEmail(req.Email),
+		MaxUses:   req.MaxUses,
+		Admin:     req.Admin,
+		CreatedBy: adminId(c, con),
+	}
+	if req.MaxUses < 0 {
+		invite.MaxUses = 0
+	} else if req.MaxUses == 0 {
+		invite.MaxUses = 1
+	}
+	if len(req.ExpiresIn) > 0 {
+		ttl, err := time.ParseDuration(req.ExpiresIn)
+		if err != nil || ttl <= 0 {
+			t.RespondError(errors.New("err_invalid_duration"), http.StatusBadRequest, c)
+			return
+		}
+		expires := time.Now().Add(ttl)
+		invite.ExpiresAt = &expires
+	}
+	if err := con.DataWrap.DB.Create(&invite).Error; err != nil {
+		t.RespondError(err, http.StatusBadRequest, c)
+		return
+	}
+	pour.LogColor(false, pour.ColorCyan, "AUTH -> Invitation", t.Encode(invite.ID), "created")
+	audit(c, auditbundle.ACTION_INVITE_CREATE, invite.CreatedBy, 0, true, t.Encode(invite.ID))
+
+	if req.SendMail && len(invite.Email) > 0 {
+		link := buildInviteLink(invite.Code)
+		data := map[string]string{"Code": invite.Code, "Link": link, "HasLink": fmt.Sprint(len(link) > 0)}
+		if err := mailbundle.QueueTemplate(invite.Email, t.GetLocale(c), "mail_invitation", data); err != nil {
+			pour.LogColor(false, pour.ColorRed, "AUTH -> Invitation mail could not be queued:", err)
+		}
+	}
+	t.RespondWithJSON(c, http.StatusOK, &invite)
+}
+
+func (con *authController) revokeInvitationHandler(c *gin.Context) {
+	invite, err := t.GetSingleById[Invitation](c, con.DataWrap.DB)
+	if err != nil {
+		t.RespondError(errors.New("not_found"), http.StatusNotFound, c)
+		return
+	}
+	if err := con.DataWrap.DB.Model(&invite).Update("revoked", true).Error; err != nil {
+		t.RespondError(err, http.StatusBadRequest, c)
+		return
+	}
+	audit(c, auditbundle.ACTION_INVITE_REVOKE, adminId(c, con), 0, true, t.Encode(invite.ID))
+	t.RespondWithJSON(c, http.StatusOK, &invite)
+}
+
+func buildInviteLink(code string) string {
+	if len(inviteURL) == 0 {
+		return ""
+	}
+	if strings.Contains(inviteURL, "?") {
+		return inviteURL + "&invite=" + code
+	}
+	return inviteURL + "?invite=" + code
+}
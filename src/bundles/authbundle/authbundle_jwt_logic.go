@@ -12,6 +12,7 @@ import (
 
 	"github.com/dgrijalva/jwt-go"
 	"github.com/gin-gonic/gin"
+	"github.com/sc-js/backend_core/src/bundles/auditbundle"
 	"github.com/sc-js/backend_core/src/bundles/cachebundle"
 	"github.com/sc-js/backend_core/src/tools"
 	"github.com/sc-js/pour"
@@ -68,12 +69,49 @@ func CreateAuth(userid uint64, td *TokenDetails) error {
 		pour.LogColor(true, pour.ColorRed, err)
 		return err
 	}
-	return cachebundle.PutExpire("user_session", td.RefreshUuid, int(userid), rt.Sub(now))
+	if err := cachebundle.PutExpire("user_session", td.RefreshUuid, int(userid), rt.Sub(now)); err != nil {
+		return err
+	}
+	indexSessions(userid, rt.Sub(now), td.AccessUuid, td.RefreshUuid)
+	return nil
+}
+
+// Remembers the session ids of a user, so all of them can be revoked at once.
+// The index lives as long as the newest refresh token.
+func indexSessions(userid uint64, ttl time.Duration, uuids ...string) {
+	key := fmt.Sprint(userid)
+	if err := cachebundle.SAdd("user_sessions", key, uuids...); err != nil {
+		pour.LogColor(true, pour.ColorRed, err)
+		return
+	}
+	if current, err := cachebundle.TTL("user_sessions", key); err == nil && current < ttl {
+		cachebundle.Expire("user_sessions", key, ttl)
+	}
+}
+
+// Revokes every session of a user, returns how many were still active
+func RevokeUserSessions(id tools.ModelID) (int, error) {
+	key := fmt.Sprint(id)
+	uuids, err := cachebundle.SMembers("user_sessions", key)
+	if err != nil {
+		return 0, err
+	}
+	revoked := 0
+	for _, element := range uuids {
+		if _, err := cachebundle.Get[int]("user_session", element); err == nil {
+			revoked++
+		}
+		cachebundle.Del("user_session", element)
+	}
+	return revoked, cachebundle.Del("user_sessions", key)
 }
 
-// Extract the JWT from an incoming request
+// Extract the JWT from an incoming request, from the Authorization header or, if there is none, the session cookie
 func ExtractToken(r *http.Request) string {
 	bearToken := r.Header.Get("Authorization")
+	if len(bearToken) == 0 {
+		return sessionCookie(r, COOKIE_ACCESS_TOKEN)
+	}
 	strArr := strings.Split(bearToken, " ")
 	if len(strArr) == 2 {
 		return strArr[1]
@@ -110,6 +148,16 @@ func ExtractJWTTokenFromToken(tokenString string) (*jwt.Token, error) {
 	return token, nil
 }
 
+// Decrypt and return a refresh token, which is signed with the refresh secret
+func ExtractJWTRefreshToken(tokenString string) (*jwt.Token, error) {
+	return jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return []byte(refreshSecret), nil
+	})
+}
+
 // Wrapper function to simplify token checking
 func TokenValid(r *http.Request) error {
 	token, err := VerifyToken(r)
@@ -130,23 +178,7 @@ func ExtractTokenMetadataWS(r *http.Request) (*AccessDetails, error) {
 	if err != nil {
 		return nil, err
 	}
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if ok && token.Valid {
-		accessUuid, ok := claims["access_uuid"].(string)
-		if !ok {
-			return nil, err
-		}
-
-		userId, err := strconv.ParseUint(fmt.Sprintf("%.f", claims["user_id"]), 10, 64)
-		if err != nil {
-			return nil, err
-		}
-		return &AccessDetails{
-			AccessUuid: accessUuid,
-			UserId:     userId,
-		}, nil
-	}
-	return nil, err
+	return accessDetailsFromToken(token)
 }
 
 // Extract the whole Metadata information (UUID and UserID) from an incoming request
@@ -156,28 +188,51 @@ func ExtractTokenMetadata(r *http.Request) (*AccessDetails, error) {
 	if err != nil {
 		return nil, err
 	}
+	return accessDetailsFromToken(token)
+}
+
+// Reads the access claims of a verified token, service tokens carry a client id and scopes instead of a user id
+func accessDetailsFromToken(token *jwt.Token) (*AccessDetails, error) {
 	claims, ok := token.Claims.(jwt.MapClaims)
-	if ok && token.Valid {
-		accessUuid, ok := claims["access_uuid"].(string)
-		if !ok {
-			return nil, err
-		}
+	if !ok || !token.Valid {
+		return nil, errors.New("not_authorized")
+	}
+	accessUuid, ok := claims["access_uuid"].(string)
+	if !ok {
+		return nil, errors.New("not_authorized")
+	}
 
-		userId, err := strconv.ParseUint(fmt.Sprintf("%.f", claims["user_id"]), 10, 64)
-		if err != nil {
-			return nil, err
-		}
+	if clientId, ok := claims["client_id"].(string); ok {
+		scope, _ := claims["scope"].(string)
 		return &AccessDetails{
 			AccessUuid: accessUuid,
-			UserId:     userId,
+			ClientId:   clientId,
+			Scopes:     strings.Fields(scope),
+			Service:    true,
 		}, nil
 	}
-	return nil, err
+
+	userId, err := strconv.ParseUint(fmt.Sprintf("%.f", claims["user_id"]), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	details := &AccessDetails{
+		AccessUuid: accessUuid,
+		UserId:     userId,
+	}
+	if impersonator, ok := claims["impersonator_id"]; ok {
+		details.ImpersonatorId, _ = strconv.ParseUint(fmt.Sprintf("%.f", impersonator), 10, 64)
+	}
+	return details, nil
 }
 
 // Check the cache if the auth is still valid
 func FetchAuth(authD *AccessDetails) (int, error) {
-	userid, err := cachebundle.Get[int]("user_session", authD.AccessUuid)
+	name := "user_session"
+	if authD.Service {
+		name = "service_session"
+	}
+	userid, err := cachebundle.Get[int](name, authD.AccessUuid)
 	if err != nil {
 		return 0, err
 	}
@@ -201,6 +256,8 @@ func DeleteAuth(givenUuid string, c *authController) (int, error) {
 	if err != nil {
 		return 0, err
 	}
+	cachebundle.SRem("user_sessions", fmt.Sprint(userid), givenUuid)
+	publishRevocation(Revocation{UserID: user.ID, Session: givenUuid})
 	return userid, nil
 }
 
@@ -208,22 +265,82 @@ func DeleteAuth(givenUuid string, c *authController) (int, error) {
 func CheckAuth(c *gin.Context) error {
 	tokenAuth, err := ExtractTokenMetadata(c.Request)
 	if err != nil {
-		_, err := extractClient(c)
+		clientId, err := extractClient(c)
 		if err == nil {
+			audit(c, auditbundle.ACTION_VCLIENT, clientId, 0, true, c.GetHeader("X-CLIENT"))
+			c.Set(tools.CTX_SUBJECT, "vclient:"+fmt.Sprint(clientId))
 			return nil
 		}
 		tools.RespondWithError(c, http.StatusUnauthorized, "not_authorized")
 		return errors.New("not_authorized")
 	}
-	_, err = FetchAuth(tokenAuth)
-	if err != nil {
+	userid, err := FetchAuth(tokenAuth)
+	if cachebundle.IsUnavailable(err) {
+		// The session may well be valid, the client should retry instead of logging out
+		tools.RespondWithError(c, http.StatusServiceUnavailable, "cache_unavailable")
+		return errors.New("cache_unavailable")
+	} else if err != nil {
 		tools.RespondWithError(c, http.StatusUnauthorized, "not_authorized")
 		return errors.New("not_authorized")
 	}
+	if (tokenAuth.Service && isServiceClientBlocked(tools.ModelID(userid))) || (!tokenAuth.Service && isUserBlocked(tools.ModelID(userid))) {
+		tools.RespondWithError(c, http.StatusUnauthorized, "account_disabled")
+		return errors.New("account_disabled")
+	}
+	c.Set(CTX_ACCESS_DETAILS, tokenAuth)
+	if tokenAuth.Service {
+		c.Set(tools.CTX_SUBJECT, "service:"+tokenAuth.ClientId)
+	} else {
+		c.Set(tools.CTX_SUBJECT, "user:"+fmt.Sprint(userid))
+	}
+	if tokenAuth.ImpersonatorId > 0 {
+		c.Set(tools.CTX_IMPERSONATOR, tools.ModelID(tokenAuth.ImpersonatorId))
+	}
 
 	return nil
 }
 
+// Marks a user as blocked in the cache, which invalidates all of their sessions without having to know the session uuids
+func blockUser(id tools.ModelID) {
+	if err := cachebundle.Put("user_blocked", fmt.Sprint(id), true); err != nil {
+		pour.LogColor(true, pour.ColorRed, err)
+	}
+	if _, err := RevokeUserSessions(id); err != nil {
+		pour.LogColor(true, pour.ColorRed, err)
+	}
+	publishRevocation(Revocation{UserID: id})
+}
+
+func unblockUser(id tools.ModelID) {
+	if err := cachebundle.Del("user_blocked", fmt.Sprint(id)); err != nil {
+		pour.LogColor(true, pour.ColorRed, err)
+	}
+}
+
+func isUserBlocked(id tools.ModelID) bool {
+	blocked, err := cachebundle.Get[bool]("user_blocked", fmt.Sprint(id))
+	return err == nil && blocked
+}
+
+func blockServiceClient(id tools.ModelID) {
+	if err := cachebundle.Put("service_blocked", fmt.Sprint(id), true); err != nil {
+		pour.LogColor(true, pour.ColorRed, err)
+	}
+	publishRevocation(Revocation{ClientID: id})
+}
+
+// Tells every node about a revocation, so state that isn't in the cache (e.g. open websockets) can be dropped
+func publishRevocation(r Revocation) {
+	if err := cachebundle.Publish(TOPIC_REVOCATION, r); err != nil {
+		pour.LogColor(true, pour.ColorRed, err)
+	}
+}
+
+func isServiceClientBlocked(id tools.ModelID) bool {
+	blocked, err := cachebundle.Get[bool]("service_blocked", fmt.Sprint(id))
+	return err == nil && blocked
+}
+
 // Get the users ID from an incoming Gin request
 func extractClient(c *gin.Context) (tools.ModelID, error) {
 	xclient := c.GetHeader("X-CLIENT")
@@ -2,9 +2,12 @@ package authbundle
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/sc-js/backend_core/src/bundles/auditbundle"
 	"github.com/sc-js/backend_core/src/bundles/cachebundle"
 	t "github.com/sc-js/backend_core/src/tools"
 	"gorm.io/gorm"
@@ -14,17 +17,52 @@ const (
 	CLIENT_TYPE_USER    = 0
 	CLIENT_TYPE_VCLIENT = 1
 	CLIENT_TYPE_DEFAULT = 2
+	CLIENT_TYPE_SERVICE = 3
 )
 
+const (
+	SCOPE_ADMIN      = "admin"
+	SCOPE_INTROSPECT = "introspect"
+)
+
+const CTX_ACCESS_DETAILS = "access_details"
+
+// Preferred locale of the authenticated user, empty for anonymous requests and service clients.
+// Only the locale is cached, under its own name, so the rest of the user row never ends up in the cache.
+func userLocale(db *gorm.DB) func(c *gin.Context) string {
+	return func(c *gin.Context) string {
+		details, ok := c.Get(CTX_ACCESS_DETAILS)
+		if !ok || details.(*AccessDetails).Service {
+			return ""
+		}
+		id := t.ModelID(details.(*AccessDetails).UserId)
+		locale, err := cachebundle.GetOrLoad("user_locale", fmt.Sprint(id), time.Minute, func() (string, error) {
+			locale := ""
+			return locale, db.Model(&AuthUser{}).Where("id=?", id).Select("locale").Scan(&locale).Error
+		})
+		if err != nil {
+			return ""
+		}
+		return locale
+	}
+}
+
+func invalidateUserLocale(id t.ModelID) {
+	cachebundle.Invalidate("user_locale", fmt.Sprint(id))
+}
+
 func GetUserIdFromRequest(c *gin.Context) (t.ModelID, int) {
 	tokenAuth, err := ExtractTokenMetadata(c.Request)
 	if err != nil {
 		return 0, CLIENT_TYPE_VCLIENT
 	}
-	userid, err := cachebundle.Get[int]("user_session", tokenAuth.AccessUuid)
+	userid, err := FetchAuth(tokenAuth)
 	if err != nil {
 		return 0, CLIENT_TYPE_DEFAULT
 	}
+	if tokenAuth.Service {
+		return t.ModelID(userid), CLIENT_TYPE_SERVICE
+	}
 	return t.ModelID(userid), CLIENT_TYPE_USER
 }
 
@@ -47,6 +85,9 @@ func GetUserFromRequest(c *gin.Context, db *gorm.DB) (AuthUser, error) {
 	if err != nil {
 		return AuthUser{}, err
 	}
+	if tokenAuth.Service {
+		return AuthUser{}, errors.New("only_user")
+	}
 	userid, err := cachebundle.Get[int]("user_session", tokenAuth.AccessUuid)
 	if err != nil {
 		return AuthUser{}, err
@@ -62,16 +103,32 @@ func AuthMiddleware(db *gorm.DB) gin.HandlerFunc {
 			c.Next()
 			return
 		}
-		url := c.Request.URL.String()
+		// Permissions are registered per route pattern, so resolve the matched route instead of the raw url
+		url := c.FullPath()
+		if len(url) == 0 {
+			url = c.Request.URL.Path
+		}
 		if t.CheckRouteNeedsAuth(url) {
 			err := CheckAuth(c)
 			if err != nil {
 				c.Abort()
 				return
 			}
+			if impersonator := t.GetImpersonator(c); impersonator > 0 {
+				userId, _ := GetUserIdFromRequest(c)
+				audit(c, auditbundle.ACTION_IMPERSONATED_REQUEST, userId, userId, true, c.Request.Method+" "+url)
+			}
+			if scope := t.GetRouteScope(url); len(scope) > 0 {
+				if details, ok := c.Get(CTX_ACCESS_DETAILS); ok && details.(*AccessDetails).Service && !details.(*AccessDetails).HasScope(scope) {
+					t.RespondError(errors.New("insufficient_scope"), http.StatusForbidden, c)
+					c.Abort()
+					return
+				}
+			}
 			if t.CheckRouteNeedsAdmin(url) {
-				isAdmin, _ := GetIsAdminFromRequest(c, db)
+				isAdmin, adminId := GetIsAdminFromRequest(c, db)
 				if isAdmin {
+					audit(c, auditbundle.ACTION_ADMIN_CALL, adminId, t.Decode(c.Param("hid")), true, c.Request.Method+" "+url)
 					c.Next()
 				} else {
 					t.RespondError(errors.New("not_authorized"), http.StatusUnauthorized, c)
@@ -89,7 +146,7 @@ func CORS(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Add("Access-Control-Allow-Origin", "*")
 		w.Header().Add("Access-Control-Allow-Credentials", "true")
-		w.Header().Add("Access-Control-Allow-Headers", "Access-Control-Request-Headers, Access-Control-Allow-Headers, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, Accept, Origin, Accept-Language, Cache-Control, X-Requested-With, X-LOCALE")
+		w.Header().Add("Access-Control-Allow-Headers", "Access-Control-Request-Headers, Access-Control-Allow-Headers, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, Accept, Origin, Accept-Language, Cache-Control, X-Requested-With, X-LOCALE, X-SESSION-MODE")
 		w.Header().Add("Access-Control-Allow-Methods", "POST, GET, HEAD, OPTIONS, PUT, DELETE, PATCH")
 
 		if r.Method == "OPTIONS" {
@@ -101,7 +158,19 @@ func CORS(next http.HandlerFunc) http.HandlerFunc {
 }
 
 func GetIsAdminFromRequest(c *gin.Context, db *gorm.DB) (bool, t.ModelID) {
+	// Impersonated sessions never carry admin rights, neither the admin's nor the target's
+	if tokenAuth, err := ExtractTokenMetadata(c.Request); err == nil && tokenAuth.ImpersonatorId > 0 {
+		return false, 0
+	}
 	uid, userType := GetUserIdFromRequest(c)
+	if userType == CLIENT_TYPE_SERVICE {
+		tokenAuth, err := ExtractTokenMetadata(c.Request)
+		if err != nil {
+			return false, 0
+		}
+		// uid is the id of the ServiceClient, not of a user. The client is recorded as the audit subject instead.
+		return tokenAuth.HasScope(SCOPE_ADMIN), 0
+	}
 	if userType == CLIENT_TYPE_VCLIENT {
 		isAdmin, err := cachebundle.Get[bool]("client_admin", c.GetHeader("X-CLIENT")+c.GetHeader("Authorization"))
 		if err != nil {
@@ -110,7 +179,7 @@ func GetIsAdminFromRequest(c *gin.Context, db *gorm.DB) (bool, t.ModelID) {
 		return isAdmin, uid
 	}
 	var user AuthUser
-	err := db.Select("system_admin").First(&user, uid).Error
+	err := db.Select("id", "system_admin").First(&user, uid).Error
 	if err == nil {
 		return user.SystemAdmin, user.ID
 	}
@@ -119,9 +188,14 @@ func GetIsAdminFromRequest(c *gin.Context, db *gorm.DB) (bool, t.ModelID) {
 
 func GetIsAdminFromUser(user AuthUser, db *gorm.DB) (bool, t.ModelID) {
 	u := AuthUser{}
-	err := db.Select("system_admin").First(&u, user.ID).Error
+	err := db.Select("id", "system_admin").First(&u, user.ID).Error
 	if err == nil {
-		return user.SystemAdmin, user.ID
+		return u.SystemAdmin, u.ID
 	}
 	return false, 0
 }
+
+// Shorthand to record an auth related audit event for the current request
+func audit(c *gin.Context, action string, actor t.ModelID, target t.ModelID, success bool, details string) {
+	auditbundle.Record(c, auditbundle.AuditEvent{Action: action, ActorID: actor, TargetID: target, Success: success, Details: details})
+}
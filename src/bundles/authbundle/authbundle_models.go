@@ -2,6 +2,7 @@ package authbundle
 
 import (
 	"encoding/json"
+	"time"
 
 	"github.com/sc-js/backend_core/src/tools"
 )
@@ -11,17 +12,27 @@ const (
 	USERTYPE_CLIENT = 1
 )
 
+// Cache bus topic of Revocation messages
+const TOPIC_REVOCATION = "auth_revocation"
+
 type AuthUser struct {
 	tools.Model
-	FirstName   string `json:"first_name"`
-	LastName    string `json:"last_name"`
-	Email       string `json:"email"`
-	Username    string `json:"username"`
-	Password    string `json:"password,omitempty" update:"false"`
-	SystemAdmin bool   `json:"-" update:"false"`
-	UserType    int    `json:"-" update:"false"`
-	VClientName string `json:"-" update:"false"`
-	VClientHash string `json:"-" update:"false"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Email     string `json:"email"`
+	Username  string `json:"username"`
+	// Preferred locale, used for responses unless the request asks for another one with X-LOCALE
+	Locale         string `json:"locale"`
+	Password       string `json:"password,omitempty" update:"false"`
+	SystemAdmin    bool   `json:"system_admin" update:"false"`
+	Disabled       bool   `json:"disabled" update:"false"`
+	DisabledReason string `json:"disabled_reason,omitempty" update:"false"`
+	UserType       int    `json:"-" update:"false"`
+	VClientName    string `json:"-" update:"false"`
+	VClientHash    string `json:"-" update:"false"`
+	// Set once the user or an admin requested the account to be erased, the erasure runs after the grace period
+	ErasureScheduledAt *time.Time `json:"erasure_scheduled_at,omitempty" update:"false"`
+	ErasureMode        string     `json:"-" update:"false"`
 }
 
 type UserLogin struct {
@@ -42,6 +53,12 @@ func (u AuthUser) MarshalJSON() ([]byte, error) {
 	})
 }
 
+type tokenResponse struct {
+	AccessToken  string `json:"access_token,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	CSRFToken    string `json:"csrf_token,omitempty"`
+}
+
 type TokenDetails struct {
 	AccessToken  string
 	RefreshToken string
@@ -55,7 +72,118 @@ func (u *AuthUser) GetFromId(id tools.ModelID, c *authController) error {
 	return c.DataWrap.DB.Where("id=?", id).First(&u).Error
 }
 
+type disableRequest struct {
+	Reason string `json:"reason"`
+}
+
+// Locale field of a profile update, checked before the update binds the body
+type localeUpdate struct {
+	Locale string `json:"locale"`
+}
+
+type passwordRequest struct {
+	Password string `json:"password"`
+}
+
+type erasureRequest struct {
+	Mode      string `json:"mode"`
+	Immediate bool   `json:"immediate"`
+}
+
+// An invitation code for invite-only registration. MaxUses 0 means unlimited, Admin pre-assigns the admin role
+// and a non-empty Email binds the invitation to that address.
+type Invitation struct {
+	tools.Model
+	Code      string        `json:"code" gorm:"uniqueIndex" update:"false"`
+	Email     string        `json:"email"`
+	MaxUses   int           `json:"max_uses"`
+	Uses      int           `json:"uses" update:"false"`
+	ExpiresAt *time.Time    `json:"expires_at"`
+	Admin     bool          `json:"admin"`
+	CreatedBy tools.ModelID `json:"created_by" update:"false"`
+	Revoked   bool          `json:"revoked" update:"false"`
+}
+
+// MaxUses defaults to a single use, -1 makes it unlimited. ExpiresIn is a duration like "72h".
+type invitationRequest struct {
+	Email     string `json:"email"`
+	MaxUses   int    `json:"max_uses"`
+	ExpiresIn string `json:"expires_in"`
+	Admin     bool   `json:"admin"`
+	SendMail  bool   `json:"send_mail"`
+}
+
+type registerRequest struct {
+	Invite string `json:"invite"`
+}
+
+type passwordlessRequest struct {
+	Email string `json:"email"`
+	Mode  string `json:"mode"`
+}
+
+// Either the magic link token or the email together with the login code
+type passwordlessVerify struct {
+	Token string `json:"token"`
+	Email string `json:"email"`
+	Code  string `json:"code"`
+}
+
+type passwordResponse struct {
+	Password string `json:"password"`
+}
+
 type AccessDetails struct {
-	AccessUuid string
-	UserId     uint64
+	AccessUuid     string
+	UserId         uint64
+	ImpersonatorId uint64
+	ClientId       string
+	Scopes         []string
+	Service        bool
+}
+
+func (a *AccessDetails) HasScope(scope string) bool {
+	return tools.Contains(a.Scopes, scope)
+}
+
+// A registered machine client, which can obtain service tokens through the client_credentials grant.
+// Scopes is a space separated list, the secret is only shown once on creation.
+type ServiceClient struct {
+	tools.Model
+	Name       string `json:"name"`
+	ClientId   string `json:"client_id" gorm:"uniqueIndex" update:"false"`
+	SecretHash string `json:"-" update:"false"`
+	Scopes     string `json:"scopes"`
+	Disabled   bool   `json:"disabled"`
+}
+
+type serviceClientCreated struct {
+	Client       ServiceClient `json:"client"`
+	ClientSecret string        `json:"client_secret"`
+}
+
+// Published when a user is blocked, a session is revoked or a service client is deleted.
+// Session is only set if a single session was revoked, otherwise all of the users sessions are gone.
+type Revocation struct {
+	UserID   tools.ModelID `json:"user_id,omitempty"`
+	ClientID tools.ModelID `json:"client_id,omitempty"`
+	Session  string        `json:"session,omitempty"`
+}
+
+type serviceTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+	Scope       string `json:"scope,omitempty"`
+}
+
+// RFC 7662 introspection response, only "active" is set for invalid tokens
+type introspectionResponse struct {
+	Active    bool   `json:"active"`
+	Scope     string `json:"scope,omitempty"`
+	ClientId  string `json:"client_id,omitempty"`
+	Username  string `json:"username,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	Exp       int64  `json:"exp,omitempty"`
+	Sub       string `json:"sub,omitempty"`
 }
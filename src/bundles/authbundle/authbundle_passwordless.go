@@ -0,0 +1,214 @@
+package authbundle
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sc-js/backend_core/src/bundles/auditbundle"
+	"github.com/sc-js/backend_core/src/bundles/cachebundle"
+	"github.com/sc-js/backend_core/src/bundles/mailbundle"
+	t "github.com/sc-js/backend_core/src/tools"
+	"github.com/sc-js/pour"
+)
+
+const (
+	PASSWORDLESS_LINK = "link"
+	PASSWORDLESS_CODE = "code"
+)
+
+var passwordlessEnabled = false
+var magicLinkURL = ""
+var passwordlessTTL = time.Minute * 15
+
+// Limits per window, requests beyond them are silently dropped
+var passwordlessWindow = time.Minute * 15
+var passwordlessEmailLimit = 5
+var passwordlessIPLimit = 20
+var passwordlessMaxAttempts = 5
+
+func handlePasswordlessSettings(settings map[string]string) {
+	passwordlessEnabled = settings["passwordless"] == "true"
+	magicLinkURL = settings["magic_link_url"]
+	if ttl, err := time.ParseDuration(settings["passwordless_ttl"]); err == nil && ttl > 0 {
+		passwordlessTTL = ttl
+	}
+}
+
+// Sends a magic link or a 6-digit login code to the given email. The response is always the same,
+// so it can't be used to find out which emails are registered.
+func (con *authController) passwordlessRequestHandler(c *gin.Context) {
+	req := passwordlessRequest{}
+	if err := c.BindJSON(&req); err != nil {
+		t.RespondError(err, http.StatusBadRequest, c)
+		return
+	}
+	email := normalizeEmail(req.Email)
+	if len(email) == 0 {
+		t.RespondError(errors.New("err_email_empty"), http.StatusBadRequest, c)
+		return
+	}
+	if req.Mode != PASSWORDLESS_CODE {
+		req.Mode = PASSWORDLESS_LINK
+	}
+	if req.Mode == PASSWORDLESS_LINK && len(magicLinkURL) == 0 {
+		t.RespondError(errors.New("err_magic_link_disabled"), http.StatusNotImplemented, c)
+		return
+	}
+
+	defer t.RespondWithJSON(c, http.StatusOK, "passwordless_sent")
+
+	if !rateLimit("passwordless_rate_ip", c.ClientIP(), passwordlessIPLimit) || !rateLimit("passwordless_rate_email", email, passwordlessEmailLimit) {
+		audit(c, auditbundle.ACTION_LOGIN_FAILED, 0, 0, false, "passwordless_rate_limited")
+		return
+	}
+
+	user := AuthUser{}
+	if err := con.DataWrap.DB.Where("LOWER(email) = ? AND user_type = ?", email, USERTYPE_USER).First(&user).Error; err != nil || user.Disabled {
+		return
+	}
+
+	locale := t.GetLocale(c)
+	minutes := fmt.Sprint(int(passwordlessTTL / time.Minute))
+	if req.Mode == PASSWORDLESS_CODE {
+		code, err := randomCode()
+		if err != nil {
+			pour.LogErr(err)
+			return
+		}
+		cachebundle.Del("passwordless_attempts", email)
+		if err := cachebundle.PutExpire("passwordless_code", email, code, passwordlessTTL); err != nil {
+			pour.LogErr(err)
+			return
+		}
+		if err := mailbundle.QueueTemplate(user.Email, locale, "mail_login_code", map[string]string{"Code": fmt.Sprintf("%06d", code), "Minutes": minutes, "Username": user.Username}); err != nil {
+			pour.LogColor(false, pour.ColorRed, "AUTH -> Passwordless mail could not be queued:", err)
+		}
+		return
+	}
+
+	token := randomHex(32)
+	if err := cachebundle.PutExpire("passwordless_link", token, int(user.ID), passwordlessTTL); err != nil {
+		pour.LogErr(err)
+		return
+	}
+	if err := mailbundle.QueueTemplate(user.Email, locale, "mail_magic_link", map[string]string{"Link": buildMagicLink(token), "Minutes": minutes, "Username": user.Username}); err != nil {
+		pour.LogColor(false, pour.ColorRed, "AUTH -> Passwordless mail could not be queued:", err)
+	}
+}
+
+// Exchanges a magic link token or an email/code pair for a regular session. Both are single-use.
+func (con *authController) passwordlessVerifyHandler(c *gin.Context) {
+	req := passwordlessVerify{}
+	if err := c.BindJSON(&req); err != nil {
+		t.RespondError(err, http.StatusBadRequest, c)
+		return
+	}
+
+	var userId t.ModelID
+	method := PASSWORDLESS_LINK
+	if len(req.Token) > 0 {
+		// Taken atomically, of two requests with the same token only one gets a session
+		id, err := cachebundle.Take[int]("passwordless_link", req.Token)
+		if err != nil {
+			audit(c, auditbundle.ACTION_LOGIN_FAILED, 0, 0, false, "passwordless_link")
+			t.RespondError(errors.New("err_passwordless_invalid"), http.StatusUnauthorized, c)
+			return
+		}
+		userId = t.ModelID(id)
+	} else {
+		method = PASSWORDLESS_CODE
+		email := normalizeEmail(req.Email)
+		if !con.verifyLoginCode(email, req.Code) {
+			audit(c, auditbundle.ACTION_LOGIN_FAILED, 0, 0, false, "passwordless_code")
+			t.RespondError(errors.New("err_passwordless_invalid"), http.StatusUnauthorized, c)
+			return
+		}
+		user := AuthUser{}
+		if err := con.DataWrap.DB.Where("LOWER(email) = ? AND user_type = ?", email, USERTYPE_USER).First(&user).Error; err != nil {
+			t.RespondError(errors.New("err_passwordless_invalid"), http.StatusUnauthorized, c)
+			return
+		}
+		userId = user.ID
+	}
+
+	user := AuthUser{}
+	if err := con.DataWrap.DB.Where("id = ?", userId).First(&user).Error; err != nil {
+		t.RespondError(errors.New("err_passwordless_invalid"), http.StatusUnauthorized, c)
+		return
+	}
+	if user.Disabled {
+		audit(c, auditbundle.ACTION_LOGIN_FAILED, user.ID, user.ID, false, "account_disabled")
+		t.RespondError(errors.New("account_disabled"), http.StatusForbidden, c)
+		return
+	}
+	con.issueLogin(c, user, "passwordless_"+method)
+}
+
+// Checks a login code, after too many wrong attempts the code is invalidated
+func (con *authController) verifyLoginCode(email string, code string) bool {
+	if len(email) == 0 || len(code) == 0 {
+		return false
+	}
+	expected, err := cachebundle.Get[int]("passwordless_code", email)
+	if err != nil {
+		return false
+	}
+	if fmt.Sprintf("%06d", expected) != strings.TrimSpace(code) {
+		// Counted atomically, so parallel guesses can't get past the limit
+		attempts, err := cachebundle.Incr("passwordless_attempts", email, passwordlessTTL)
+		if err != nil || attempts >= int64(passwordlessMaxAttempts) {
+			cachebundle.Del("passwordless_code", email)
+			cachebundle.Del("passwordless_attempts", email)
+		}
+		return false
+	}
+	// Only the caller whose delete removed the code logs in, a new code sent in between doesn't count
+	taken, err := cachebundle.Take[int]("passwordless_code", email)
+	if err != nil || taken != expected {
+		return false
+	}
+	cachebundle.Del("passwordless_attempts", email)
+	return true
+}
+
+// Counts a request for the key within the current window, returns false once the limit is exceeded or the count fails.
+// The window starts with the first request.
+func rateLimit(name string, key string, limit int) bool {
+	count, err := cachebundle.Incr(name, key, passwordlessWindow)
+	if err != nil {
+		// Fails closed, an unreachable cache must not lift the limit of the login endpoints
+		pour.LogColor(false, pour.ColorRed, "AUTH -> Rate limit failed:", err)
+		return false
+	}
+	return count <= int64(limit)
+}
+
+func buildMagicLink(token string) string {
+	link, err := url.Parse(magicLinkURL)
+	if err != nil {
+		return magicLinkURL + "?token=" + token
+	}
+	query := link.Query()
+	query.Set("token", token)
+	link.RawQuery = query.Encode()
+	return link.String()
+}
+
+func randomCode() (int, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return 0, err
+	}
+	return int(n.Int64()), nil
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
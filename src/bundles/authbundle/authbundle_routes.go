@@ -17,21 +17,58 @@ func InitBundle(r *gin.RouterGroup, wrap *t.DataWrap, withRegister bool, setting
 		{Method: http.MethodPost, Endpoint: "/auth/refresh", Handler: controller.refreshHandler},
 		{Method: http.MethodPost, Endpoint: "/auth/logout", Handler: controller.logoutHandler},
 		{Method: http.MethodGet, Endpoint: "/auth/user", Handler: controller.getUserHandler},
-		//{Method: http.MethodGet, Endpoint: "/auth/user/:hid", Handler: controller.getUserByIdHandler},
-		//{Method: http.MethodPatch, Endpoint: "/auth/user/:hid", Handler: controller.updateUserHandler},
+		{Method: http.MethodPatch, Endpoint: "/auth/user", Handler: controller.updateOwnUserHandler},
+		{Method: http.MethodDelete, Endpoint: "/auth/user", Handler: controller.requestErasureHandler},
+		{Method: http.MethodDelete, Endpoint: "/auth/user/erasure", Handler: controller.cancelErasureHandler},
+		{Method: http.MethodGet, Endpoint: "/auth/user/export", Handler: controller.exportOwnUserHandler},
 
 		//Images
-		//{Method: http.MethodGet, Endpoint: "/auth/user/image", Handler: controller.getUserImageHandler},
-		//{Method: http.MethodPost, Endpoint: "/auth/user/image", Handler: controller.uploadUserImageHandler},
+		{Method: http.MethodGet, Endpoint: "/auth/user/image", Handler: controller.getUserImageHandler},
+		{Method: http.MethodPost, Endpoint: "/auth/user/image", Handler: controller.uploadUserImageHandler},
 
-		//{Method: http.MethodPost, Endpoint: "/auth/user/:hid/image", Handler: controller.uploadUserByIdImageHandler},
-		//{Method: http.MethodGet, Endpoint: "/auth/user/:hid/image", Handler: controller.getUserImageByIdHandler},
-		//{Method: http.MethodDelete, Endpoint: "/auth/user/:hid/image", Handler: controller.deleteUserByIdImageHandler},
+		{Method: http.MethodPost, Endpoint: "/auth/user/:hid/image", Handler: controller.uploadUserByIdImageHandler},
+		{Method: http.MethodGet, Endpoint: "/auth/user/:hid/image", Handler: controller.getUserImageByIdHandler},
+		{Method: http.MethodDelete, Endpoint: "/auth/user/:hid/image", Handler: controller.deleteUserByIdImageHandler},
+
+		//Admin
+		{Method: http.MethodGet, Endpoint: "/auth/users", Handler: controller.getUsersHandler, Permission: t.PERM_ADMIN},
+		{Method: http.MethodGet, Endpoint: "/auth/user/:hid", Handler: controller.getUserByIdHandler, Permission: t.PERM_ADMIN},
+		{Method: http.MethodPatch, Endpoint: "/auth/user/:hid", Handler: controller.updateUserHandler, Permission: t.PERM_ADMIN},
+		{Method: http.MethodDelete, Endpoint: "/auth/user/:hid", Handler: controller.deleteUserHandler, Permission: t.PERM_ADMIN},
+		{Method: http.MethodPost, Endpoint: "/auth/user/:hid/restore", Handler: controller.restoreUserHandler, Permission: t.PERM_ADMIN},
+		{Method: http.MethodPost, Endpoint: "/auth/user/:hid/disable", Handler: controller.disableUserHandler, Permission: t.PERM_ADMIN},
+		{Method: http.MethodPost, Endpoint: "/auth/user/:hid/enable", Handler: controller.enableUserHandler, Permission: t.PERM_ADMIN},
+		{Method: http.MethodPost, Endpoint: "/auth/user/:hid/password", Handler: controller.resetPasswordHandler, Permission: t.PERM_ADMIN},
+		{Method: http.MethodPost, Endpoint: "/auth/user/:hid/admin", Handler: controller.toggleAdminHandler, Permission: t.PERM_ADMIN},
+		{Method: http.MethodGet, Endpoint: "/auth/user/:hid/export", Handler: controller.exportUserHandler, Permission: t.PERM_ADMIN},
+		{Method: http.MethodPost, Endpoint: "/auth/user/:hid/erase", Handler: controller.eraseUserHandler, Permission: t.PERM_ADMIN},
+		{Method: http.MethodPost, Endpoint: "/auth/impersonate/:hid", Handler: controller.impersonateHandler, Permission: t.PERM_ADMIN},
+		{Method: http.MethodDelete, Endpoint: "/auth/impersonate", Handler: controller.endImpersonationHandler},
+
+		//Service clients
+		{Method: http.MethodPost, Endpoint: "/auth/token", Handler: controller.tokenHandler, Permission: t.PERM_ZERO},
+		{Method: http.MethodPost, Endpoint: "/auth/introspect", Handler: controller.introspectHandler, Permission: t.PERM_ZERO},
+		{Method: http.MethodGet, Endpoint: "/auth/clients", Handler: controller.getServiceClientsHandler, Permission: t.PERM_ADMIN},
+		{Method: http.MethodPost, Endpoint: "/auth/clients", Handler: controller.createServiceClientHandler, Permission: t.PERM_ADMIN},
+		{Method: http.MethodDelete, Endpoint: "/auth/clients/:hid", Handler: controller.deleteServiceClientHandler, Permission: t.PERM_ADMIN},
+
+		//Invitations
+		{Method: http.MethodGet, Endpoint: "/auth/invitations", Handler: controller.getInvitationsHandler, Permission: t.PERM_ADMIN},
+		{Method: http.MethodPost, Endpoint: "/auth/invitations", Handler: controller.createInvitationHandler, Permission: t.PERM_ADMIN},
+		{Method: http.MethodDelete, Endpoint: "/auth/invitations/:hid", Handler: controller.revokeInvitationHandler, Permission: t.PERM_ADMIN},
 	}
 
-	if withRegister {
+	// The configured registration mode can only restrict what the caller allows
+	if withRegister && registrationMode != REGISTRATION_CLOSED {
 		routes = append(routes, t.GinRoute{Method: http.MethodPost, Endpoint: "/auth/register", Handler: controller.registerHandler, Permission: t.PERM_ZERO})
 	}
 
+	if passwordlessEnabled {
+		routes = append(routes,
+			t.GinRoute{Method: http.MethodPost, Endpoint: "/auth/passwordless/request", Handler: controller.passwordlessRequestHandler, Permission: t.PERM_ZERO},
+			t.GinRoute{Method: http.MethodPost, Endpoint: "/auth/passwordless/verify", Handler: controller.passwordlessVerifyHandler, Permission: t.PERM_ZERO},
+		)
+	}
+
 	t.InitHandlers(r, routes)
 }
@@ -0,0 +1,264 @@
+package authbundle
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gin-gonic/gin"
+	"github.com/sc-js/backend_core/src/bundles/auditbundle"
+	"github.com/sc-js/backend_core/src/bundles/cachebundle"
+	t "github.com/sc-js/backend_core/src/tools"
+	"github.com/sc-js/pour"
+	"github.com/twinj/uuid"
+)
+
+const GRANT_CLIENT_CREDENTIALS = "client_credentials"
+
+var serviceTokenTTL = time.Minute * 15
+
+func handleServiceSettings(settings map[string]string) {
+	if ttl, err := time.ParseDuration(settings["service_token_ttl"]); err == nil && ttl > 0 {
+		serviceTokenTTL = ttl
+	}
+}
+
+// Create a short-lived, scoped JWT for a registered service client. Like user tokens, it is only valid as long
+// as its uuid is present in the cache.
+func CreateServiceToken(client ServiceClient, scopes []string) (*TokenDetails, error) {
+	td := &TokenDetails{}
+	td.AtExpires = time.Now().Add(serviceTokenTTL).Unix()
+	td.AccessUuid = uuid.NewV4().String()
+
+	atClaims := jwt.MapClaims{}
+	atClaims["authorized"] = true
+	atClaims["access_uuid"] = td.AccessUuid
+	atClaims["client_id"] = client.ClientId
+	atClaims["scope"] = strings.Join(scopes, " ")
+	atClaims["exp"] = td.AtExpires
+	atClaims["iat"] = time.Now().Unix()
+	at := jwt.NewWithClaims(jwt.SigningMethodHS256, atClaims)
+
+	var err error
+	td.AccessToken, err = at.SignedString([]byte(signSecret))
+	if err != nil {
+		return nil, err
+	}
+	if err := cachebundle.PutExpire("service_session", td.AccessUuid, int(client.ID), serviceTokenTTL); err != nil {
+		return nil, err
+	}
+	return td, nil
+}
+
+// OAuth2 token endpoint, only the client_credentials grant is supported
+func (con *authController) tokenHandler(c *gin.Context) {
+	if c.PostForm("grant_type") != GRANT_CLIENT_CREDENTIALS {
+		respondOAuthError(c, http.StatusBadRequest, "unsupported_grant_type")
+		return
+	}
+	client, err := con.authenticateServiceClient(c)
+	if err != nil {
+		audit(c, auditbundle.ACTION_SERVICE_TOKEN_FAILED, 0, 0, false, err.Error())
+		respondOAuthError(c, http.StatusUnauthorized, "invalid_client")
+		return
+	}
+
+	scopes := strings.Fields(client.Scopes)
+	if requested := strings.Fields(c.PostForm("scope")); len(requested) > 0 {
+		for _, scope := range requested {
+			if !t.Contains(scopes, scope) {
+				respondOAuthError(c, http.StatusBadRequest, "invalid_scope")
+				return
+			}
+		}
+		scopes = requested
+	}
+
+	td, err := CreateServiceToken(client, scopes)
+	if err != nil {
+		pour.LogColor(true, pour.ColorRed, err)
+		respondOAuthError(c, http.StatusInternalServerError, "server_error")
+		return
+	}
+	audit(c, auditbundle.ACTION_SERVICE_TOKEN, 0, 0, true, client.ClientId)
+	c.Header("Cache-Control", "no-store")
+	t.RespondWithJSON(c, http.StatusOK, &serviceTokenResponse{AccessToken: td.AccessToken, TokenType: "Bearer", ExpiresIn: int64(serviceTokenTTL / time.Second), Scope: strings.Join(scopes, " ")})
+}
+
+// RFC 7662 token introspection, callers authenticate with the credentials of a service client holding the introspect scope
+func (con *authController) introspectHandler(c *gin.Context) {
+	client, err := con.authenticateServiceClient(c)
+	if err != nil || !t.Contains(strings.Fields(client.Scopes), SCOPE_INTROSPECT) {
+		respondOAuthError(c, http.StatusUnauthorized, "invalid_client")
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+
+	introspectors := []func(string) (*introspectionResponse, bool){con.introspectAccessToken, con.introspectRefreshToken}
+	if c.PostForm("token_type_hint") == "refresh_token" {
+		introspectors = []func(string) (*introspectionResponse, bool){con.introspectRefreshToken, con.introspectAccessToken}
+	}
+	for _, introspect := range introspectors {
+		if res, ok := introspect(c.PostForm("token")); ok {
+			t.RespondWithJSON(c, http.StatusOK, res)
+			return
+		}
+	}
+	t.RespondWithJSON(c, http.StatusOK, &introspectionResponse{Active: false})
+}
+
+func (con *authController) introspectAccessToken(tokenString string) (*introspectionResponse, bool) {
+	token, err := ExtractJWTTokenFromToken(tokenString)
+	if err != nil {
+		return nil, false
+	}
+	details, err := accessDetailsFromToken(token)
+	if err != nil {
+		return nil, false
+	}
+	id, err := FetchAuth(details)
+	if err != nil {
+		return nil, false
+	}
+	exp := expiryFromToken(token)
+
+	if details.Service {
+		if isServiceClientBlocked(t.ModelID(id)) {
+			return nil, false
+		}
+		return &introspectionResponse{Active: true, Scope: strings.Join(details.Scopes, " "), ClientId: details.ClientId, TokenType: "Bearer", Exp: exp}, true
+	}
+	user := AuthUser{}
+	if isUserBlocked(t.ModelID(id)) || user.GetFromId(t.ModelID(id), con) != nil {
+		return nil, false
+	}
+	return &introspectionResponse{Active: true, Username: user.Username, Sub: t.Encode(user.ID), TokenType: "Bearer", Exp: exp}, true
+}
+
+func (con *authController) introspectRefreshToken(tokenString string) (*introspectionResponse, bool) {
+	token, err := ExtractJWTRefreshToken(tokenString)
+	if err != nil || !token.Valid {
+		return nil, false
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, false
+	}
+	refreshUuid, ok := claims["refresh_uuid"].(string)
+	if !ok {
+		return nil, false
+	}
+	id, err := cachebundle.Get[int]("user_session", refreshUuid)
+	if err != nil {
+		return nil, false
+	}
+	user := AuthUser{}
+	if isUserBlocked(t.ModelID(id)) || user.GetFromId(t.ModelID(id), con) != nil {
+		return nil, false
+	}
+	return &introspectionResponse{Active: true, Username: user.Username, Sub: t.Encode(user.ID), TokenType: "refresh_token", Exp: expiryFromToken(token)}, true
+}
+
+// Service clients authenticate with HTTP Basic auth or with client_id/client_secret form fields
+func (con *authController) authenticateServiceClient(c *gin.Context) (ServiceClient, error) {
+	clientId, secret, ok := c.Request.BasicAuth()
+	if !ok {
+		clientId = c.PostForm("client_id")
+		secret = c.PostForm("client_secret")
+	}
+	client := ServiceClient{}
+	if len(clientId) == 0 || len(secret) == 0 {
+		return client, errors.New("no_client")
+	}
+	if err := con.DataWrap.DB.Where("client_id=? AND disabled=?", clientId, false).First(&client).Error; err != nil {
+		return client, errors.New("no_client")
+	}
+	if subtle.ConstantTimeCompare([]byte(hashSecret(secret)), []byte(client.SecretHash)) != 1 {
+		return client, errors.New("bad_client_secret")
+	}
+	return client, nil
+}
+
+func (con *authController) getServiceClientsHandler(c *gin.Context) {
+	t.GetPagedAndSend[ServiceClient](c, con.DataWrap.DB)
+}
+
+// Registers a new service client, the generated secret is only returned in this response
+func (con *authController) createServiceClientHandler(c *gin.Context) {
+	client := ServiceClient{}
+	if err := c.BindJSON(&client); err != nil {
+		t.RespondError(err, http.StatusBadRequest, c)
+		return
+	}
+	if len(client.Name) == 0 {
+		t.RespondError(errors.New("err_name_empty"), http.StatusBadRequest, c)
+		return
+	}
+	secret := randomHex(32)
+	client.ID = 0
+	client.ClientId = randomHex(16)
+	client.SecretHash = hashSecret(secret)
+	client.Scopes = strings.Join(strings.Fields(client.Scopes), " ")
+	if err := con.DataWrap.DB.Create(&client).Error; err != nil {
+		t.RespondError(err, http.StatusBadRequest, c)
+		return
+	}
+	pour.LogColor(false, pour.ColorCyan, "AUTH -> Service client '"+client.Name+"' registered with scopes:", client.Scopes)
+	t.RespondWithJSON(c, http.StatusOK, &serviceClientCreated{Client: client, ClientSecret: secret})
+}
+
+func (con *authController) deleteServiceClientHandler(c *gin.Context) {
+	client, err := t.GetSingleById[ServiceClient](c, con.DataWrap.DB)
+	if err != nil {
+		t.RespondError(errors.New("not_found"), http.StatusNotFound, c)
+		return
+	}
+	if err := con.DataWrap.DB.Delete(&client).Error; err != nil {
+		t.RespondError(err, http.StatusBadRequest, c)
+		return
+	}
+	blockServiceClient(client.ID)
+	pour.LogColor(false, pour.ColorCyan, "AUTH -> Service client '"+client.Name+"' removed")
+	t.RespondWithJSON(c, http.StatusOK, &client)
+}
+
+// OAuth2 clients expect the RFC 6749 error format instead of the localized error message
+func respondOAuthError(c *gin.Context, code int, oauthErr string) {
+	c.Header("Cache-Control", "no-store")
+	if code == http.StatusUnauthorized {
+		c.Header("WWW-Authenticate", "Basic")
+	}
+	c.JSON(code, map[string]string{"error": oauthErr})
+	c.Error(errors.New(oauthErr))
+}
+
+func expiryFromToken(token *jwt.Token) int64 {
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return 0
+	}
+	exp, err := strconv.ParseInt(fmt.Sprintf("%.f", claims["exp"]), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return exp
+}
+
+func hashSecret(secret string) string {
+	hash := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(hash[:])
+}
+
+func randomHex(length int) string {
+	b := make([]byte, length)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,112 @@
+package authbundle
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	t "github.com/sc-js/backend_core/src/tools"
+)
+
+const (
+	COOKIE_ACCESS_TOKEN  = "access_token"
+	COOKIE_REFRESH_TOKEN = "refresh_token"
+	COOKIE_CSRF_TOKEN    = "csrf_token"
+	HEADER_CSRF_TOKEN    = "X-CSRF-Token"
+	HEADER_SESSION_MODE  = "X-SESSION-MODE"
+	SESSION_MODE_COOKIE  = "cookie"
+)
+
+var cookieSessions = false
+var cookieDomain = ""
+var cookieSameSite = http.SameSiteStrictMode
+
+func handleSessionSettings(settings map[string]string) {
+	cookieSessions = settings["session_cookies"] == "true"
+	cookieDomain = settings["cookie_domain"]
+	switch strings.ToLower(settings["cookie_same_site"]) {
+	case "lax":
+		cookieSameSite = http.SameSiteLaxMode
+	case "none":
+		cookieSameSite = http.SameSiteNoneMode
+	default:
+		cookieSameSite = http.SameSiteStrictMode
+	}
+}
+
+// Browser clients opt into cookie sessions with the X-SESSION-MODE header, everyone else keeps getting bearer tokens
+func wantsCookieSession(c *gin.Context) bool {
+	return cookieSessions && strings.EqualFold(c.GetHeader(HEADER_SESSION_MODE), SESSION_MODE_COOKIE)
+}
+
+// A request is cookie authenticated if it carries session cookies but no Authorization header
+func usesCookieSession(r *http.Request) bool {
+	for _, name := range []string{COOKIE_ACCESS_TOKEN, COOKIE_REFRESH_TOKEN} {
+		if len(sessionCookie(r, name)) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Value of a session cookie, only read if the request has no Authorization header.
+// Tokens are taken from cookies exactly when usesCookieSession applies the CSRF check.
+func sessionCookie(r *http.Request, name string) string {
+	if !cookieSessions || len(r.Header.Get("Authorization")) > 0 {
+		return ""
+	}
+	if cookie, err := r.Cookie(name); err == nil {
+		return cookie.Value
+	}
+	return ""
+}
+
+// Puts the token pair into HttpOnly cookies and issues a fresh CSRF token, which is returned for the double-submit check
+func setSessionCookies(c *gin.Context, td *TokenDetails) string {
+	csrf := randomHex(32)
+	setCookie(c, COOKIE_ACCESS_TOKEN, td.AccessToken, "/", time.Unix(td.AtExpires, 0), true)
+	setCookie(c, COOKIE_REFRESH_TOKEN, td.RefreshToken, "/auth/refresh", time.Unix(td.RtExpires, 0), true)
+	setCookie(c, COOKIE_CSRF_TOKEN, csrf, "/", time.Unix(td.RtExpires, 0), false)
+	return csrf
+}
+
+func clearSessionCookies(c *gin.Context) {
+	setCookie(c, COOKIE_ACCESS_TOKEN, "", "/", time.Unix(0, 0), true)
+	setCookie(c, COOKIE_REFRESH_TOKEN, "", "/auth/refresh", time.Unix(0, 0), true)
+	setCookie(c, COOKIE_CSRF_TOKEN, "", "/", time.Unix(0, 0), false)
+}
+
+func setCookie(c *gin.Context, name string, value string, path string, expires time.Time, httpOnly bool) {
+	cookie := &http.Cookie{Name: name, Value: value, Path: path, Domain: cookieDomain, Expires: expires, Secure: true, HttpOnly: httpOnly, SameSite: cookieSameSite}
+	if len(value) == 0 {
+		cookie.MaxAge = -1
+	}
+	http.SetCookie(c.Writer, cookie)
+}
+
+// Double-submit CSRF protection for cookie authenticated requests, mutating requests must echo the
+// csrf_token cookie in the X-CSRF-Token header. Bearer authenticated requests are not affected.
+func CSRFMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+		if !usesCookieSession(c.Request) {
+			c.Next()
+			return
+		}
+		cookie, err := c.Request.Cookie(COOKIE_CSRF_TOKEN)
+		header := c.GetHeader(HEADER_CSRF_TOKEN)
+		if err != nil || len(cookie.Value) == 0 || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(header)) != 1 {
+			t.RespondError(errors.New("csrf_failed"), http.StatusForbidden, c)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
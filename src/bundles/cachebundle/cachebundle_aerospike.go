@@ -0,0 +1,509 @@
+package cachebundle
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aerospike/aerospike-client-go"
+	"github.com/aerospike/aerospike-client-go/types"
+	"github.com/sc-js/pour"
+)
+
+const AerospikeDefaultWorkspace = "aero_default"
+
+type aerospikeEngine struct {
+	client    *aerospike.Client
+	workspace string
+}
+
+func (e *aerospikeEngine) Connect(config EngineConfig) error {
+	port := 3000
+	if config.PortOverride > 0 {
+		port = int(config.PortOverride)
+	}
+	e.workspace = config.Workspace
+	if len(e.workspace) == 0 {
+		e.workspace = AerospikeDefaultWorkspace
+	}
+	var err error
+	e.client, err = aerospike.NewClient(config.Address, port)
+	if err != nil {
+		e.client = nil
+		return err
+	}
+	pour.LogColor(false, pour.ColorPurple, "AeroSpike connected at", config.Address+":"+fmt.Sprint(port))
+	return nil
+}
+
+func (e *aerospikeEngine) Put(name string, key string, val []byte, expiration time.Duration) error {
+	internalKey, err := aerospike.NewKey(e.workspace, name, key)
+	if err != nil {
+		return err
+	}
+	// SendKey stores the user key with the record, otherwise scans only return digests
+	policy := aerospike.NewWritePolicy(0, ttlSeconds(expiration))
+	policy.SendKey = true
+	return e.client.Put(policy, internalKey, aerospike.BinMap{"a": val})
+}
+
+func (e *aerospikeEngine) Get(name string, key string) ([]byte, error) {
+	internalKey, err := aerospike.NewKey(e.workspace, name, key)
+	if err != nil {
+		return nil, err
+	}
+	rec, err := e.client.Get(nil, internalKey)
+	if err != nil {
+		if isResultCode(err, types.KEY_NOT_FOUND_ERROR) {
+			return nil, errNf
+		}
+		return nil, err
+	}
+	return binBytes(rec)
+}
+
+// Reads all keys with a single batch request
+func (e *aerospikeEngine) MGet(name string, keys []string) (map[string][]byte, error) {
+	result := map[string][]byte{}
+	if len(keys) == 0 {
+		return result, nil
+	}
+	internalKeys := make([]*aerospike.Key, len(keys))
+	for i, key := range keys {
+		internalKey, err := aerospike.NewKey(e.workspace, name, key)
+		if err != nil {
+			return nil, err
+		}
+		internalKeys[i] = internalKey
+	}
+	records, err := e.client.BatchGet(nil, internalKeys, "a")
+	if err != nil {
+		return nil, err
+	}
+	for i, rec := range records {
+		if data, err := binBytes(rec); err == nil {
+			result[keys[i]] = data
+		}
+	}
+	return result, nil
+}
+
+// Scans the set of the name, only records written with SendKey (everything written by this engine) are returned
+func (e *aerospikeEngine) Scan(name string) (map[string][]byte, error) {
+	recordset, err := e.client.ScanAll(nil, e.workspace, name, "a")
+	if err != nil {
+		return nil, err
+	}
+	defer recordset.Close()
+	result := map[string][]byte{}
+	for res := range recordset.Results() {
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		if res.Record.Key.Value() == nil {
+			continue
+		}
+		if data, err := binBytes(res.Record); err == nil {
+			result[res.Record.Key.Value().String()] = data
+		}
+	}
+	return result, nil
+}
+
+func (e *aerospikeEngine) PutIfAbsent(name string, key string, val []byte, expiration time.Duration) (bool, error) {
+	internalKey, err := aerospike.NewKey(e.workspace, name, key)
+	if err != nil {
+		return false, err
+	}
+	policy := aerospike.NewWritePolicy(0, ttlSeconds(expiration))
+	policy.SendKey = true
+	policy.RecordExistsAction = aerospike.CREATE_ONLY
+	err = e.client.Put(policy, internalKey, aerospike.BinMap{"a": val})
+	if isResultCode(err, types.KEY_EXISTS_ERROR) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Compare and swap via the record generation, the write fails if the record changed after it was read
+func (e *aerospikeEngine) CompareAndExpire(name string, key string, val []byte, expiration time.Duration) (bool, error) {
+	internalKey, policy, ok, err := e.expectValue(name, key, val)
+	if !ok || err != nil {
+		return false, err
+	}
+	policy.Expiration = ttlSeconds(expiration)
+	err = e.client.Touch(policy, internalKey)
+	if isResultCode(err, types.GENERATION_ERROR) || isResultCode(err, types.KEY_NOT_FOUND_ERROR) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (e *aerospikeEngine) CompareAndDel(name string, key string, val []byte) (bool, error) {
+	internalKey, policy, ok, err := e.expectValue(name, key, val)
+	if !ok || err != nil {
+		return false, err
+	}
+	deleted, err := e.client.Delete(policy, internalKey)
+	if isResultCode(err, types.GENERATION_ERROR) {
+		return false, nil
+	}
+	return deleted, err
+}
+
+// Reads the record and returns a write policy expecting its current generation, ok is false if the value differs
+func (e *aerospikeEngine) expectValue(name string, key string, val []byte) (*aerospike.Key, *aerospike.WritePolicy, bool, error) {
+	internalKey, err := aerospike.NewKey(e.workspace, name, key)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	rec, err := e.client.Get(nil, internalKey)
+	if isResultCode(err, types.KEY_NOT_FOUND_ERROR) {
+		return nil, nil, false, nil
+	} else if err != nil {
+		return nil, nil, false, err
+	}
+	current, err := binBytes(rec)
+	if err != nil || !bytes.Equal(current, val) {
+		return nil, nil, false, nil
+	}
+	policy := aerospike.NewWritePolicy(rec.Generation, 0)
+	policy.GenerationPolicy = aerospike.EXPECT_GEN_EQUAL
+	policy.SendKey = true
+	return internalKey, policy, true, nil
+}
+
+// Counters live in the integer bin "n", so they can be incremented atomically on the server.
+// The record is created first, so the expiration is only set once, later increments keep the TTL.
+func (e *aerospikeEngine) IncrBy(name string, key string, delta int64, expiration time.Duration) (int64, error) {
+	internalKey, err := aerospike.NewKey(e.workspace, name, key)
+	if err != nil {
+		return 0, err
+	}
+	ops := []*aerospike.Operation{aerospike.AddOp(aerospike.NewBin("n", delta)), aerospike.GetOpForBin("n")}
+	// Counters without expiration (lock fences) must never reset
+	rec, err := e.operateCreating(internalKey, ttlSeconds(expiration), ops...)
+	if err != nil {
+		return 0, err
+	}
+	return binInt(rec)
+}
+
+func (e *aerospikeEngine) Counter(name string, key string) (int64, error) {
+	internalKey, err := aerospike.NewKey(e.workspace, name, key)
+	if err != nil {
+		return 0, err
+	}
+	rec, err := e.client.Get(nil, internalKey, "n")
+	if isResultCode(err, types.KEY_NOT_FOUND_ERROR) {
+		return 0, errNf
+	} else if err != nil {
+		return 0, err
+	}
+	return binInt(rec)
+}
+
+// There is no batch write, the records are written one by one
+func (e *aerospikeEngine) MPut(name string, entries map[string][]byte, expiration time.Duration) error {
+	for key, val := range entries {
+		if err := e.Put(name, key, val, expiration); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Scans the keys of the set without bin data and deletes the matching records.
+// Only records written with SendKey carry their key, records written by versions before it (or by other clients)
+// are identified by their digest alone. They can't be matched against a prefix and are kept, an empty prefix deletes them as well.
+func (e *aerospikeEngine) DelPrefix(name string, prefix string) (int, error) {
+	policy := aerospike.NewScanPolicy()
+	policy.IncludeBinData = false
+	recordset, err := e.client.ScanAll(policy, e.workspace, name)
+	if err != nil {
+		return 0, err
+	}
+	defer recordset.Close()
+	deleted, keyless := 0, 0
+	defer func() {
+		if keyless > 0 {
+			pour.LogColor(false, pour.ColorYellow, "Kept", keyless, "record(s) of", name, "without a stored key, they can only be deleted by key or with an empty prefix")
+		}
+	}()
+	for res := range recordset.Results() {
+		if res.Err != nil {
+			return deleted, res.Err
+		}
+		if len(prefix) > 0 {
+			userKey := res.Record.Key.Value()
+			if userKey == nil {
+				keyless++
+				continue
+			}
+			if !strings.HasPrefix(userKey.String(), prefix) {
+				continue
+			}
+		}
+		existed, err := e.client.Delete(nil, res.Record.Key)
+		if err != nil {
+			return deleted, err
+		} else if existed {
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (e *aerospikeEngine) TTL(name string, key string) (time.Duration, error) {
+	internalKey, err := aerospike.NewKey(e.workspace, name, key)
+	if err != nil {
+		return 0, err
+	}
+	rec, err := e.client.GetHeader(nil, internalKey)
+	if isResultCode(err, types.KEY_NOT_FOUND_ERROR) {
+		return 0, errNf
+	} else if err != nil {
+		return 0, err
+	}
+	if rec.Expiration == 0 || rec.Expiration == aerospike.TTLDontExpire {
+		return 0, nil
+	}
+	return time.Duration(rec.Expiration) * time.Second, nil
+}
+
+func (e *aerospikeEngine) Expire(name string, key string, expiration time.Duration) error {
+	return e.touch(name, key, ttlSeconds(expiration))
+}
+
+func (e *aerospikeEngine) Persist(name string, key string) error {
+	return e.touch(name, key, aerospike.TTLDontExpire)
+}
+
+func (e *aerospikeEngine) touch(name string, key string, expiration uint32) error {
+	internalKey, err := aerospike.NewKey(e.workspace, name, key)
+	if err != nil {
+		return err
+	}
+	err = e.client.Touch(aerospike.NewWritePolicy(0, expiration), internalKey)
+	if isResultCode(err, types.KEY_NOT_FOUND_ERROR) {
+		return errNf
+	}
+	return err
+}
+
+// Sets are stored as a map bin "s" with the members as keys, hashes as a map bin "h" of fields to blobs
+func (e *aerospikeEngine) SAdd(name string, key string, members []string) error {
+	items := make(map[interface{}]interface{}, len(members))
+	for _, member := range members {
+		items[member] = 1
+	}
+	return e.mapPut(name, key, "s", items)
+}
+
+func (e *aerospikeEngine) SRem(name string, key string, members []string) error {
+	return e.mapRemove(name, key, "s", members)
+}
+
+func (e *aerospikeEngine) SMembers(name string, key string) ([]string, error) {
+	items, err := e.mapGetAll(name, key, "s")
+	members := make([]string, 0, len(items))
+	for member := range items {
+		if str, ok := member.(string); ok {
+			members = append(members, str)
+		}
+	}
+	return members, err
+}
+
+func (e *aerospikeEngine) SIsMember(name string, key string, member string) (bool, error) {
+	val, err := e.mapGet(name, key, "s", member)
+	if err == errNf {
+		return false, nil
+	}
+	return val != nil, err
+}
+
+func (e *aerospikeEngine) HSet(name string, key string, fields map[string][]byte) error {
+	items := make(map[interface{}]interface{}, len(fields))
+	for field, val := range fields {
+		items[field] = val
+	}
+	return e.mapPut(name, key, "h", items)
+}
+
+func (e *aerospikeEngine) HGet(name string, key string, field string) ([]byte, error) {
+	val, err := e.mapGet(name, key, "h", field)
+	if err != nil {
+		return nil, err
+	}
+	data, ok := val.([]byte)
+	if !ok {
+		return nil, errNf
+	}
+	return data, nil
+}
+
+func (e *aerospikeEngine) HDel(name string, key string, fields []string) error {
+	return e.mapRemove(name, key, "h", fields)
+}
+
+func (e *aerospikeEngine) HGetAll(name string, key string) (map[string][]byte, error) {
+	items, err := e.mapGetAll(name, key, "h")
+	result := make(map[string][]byte, len(items))
+	for field, val := range items {
+		str, okField := field.(string)
+		data, okVal := val.([]byte)
+		if okField && okVal {
+			result[str] = data
+		}
+	}
+	return result, err
+}
+
+func (e *aerospikeEngine) mapPut(name string, key string, bin string, items map[interface{}]interface{}) error {
+	if len(items) == 0 {
+		return nil
+	}
+	internalKey, err := aerospike.NewKey(e.workspace, name, key)
+	if err != nil {
+		return err
+	}
+	// Sets and hashes don't expire, like on the other engines
+	_, err = e.operateCreating(internalKey, aerospike.TTLDontExpire, aerospike.MapPutItemsOp(aerospike.DefaultMapPolicy(), bin, items))
+	return err
+}
+
+func (e *aerospikeEngine) mapRemove(name string, key string, bin string, keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	internalKey, err := aerospike.NewKey(e.workspace, name, key)
+	if err != nil {
+		return err
+	}
+	list := make([]interface{}, len(keys))
+	for i, element := range keys {
+		list[i] = element
+	}
+	_, err = e.client.Operate(keepTTLPolicy(), internalKey, aerospike.MapRemoveByKeyListOp(bin, list, aerospike.MapReturnType.NONE))
+	if isResultCode(err, types.KEY_NOT_FOUND_ERROR) || isResultCode(err, types.BIN_NOT_FOUND) {
+		return nil
+	}
+	return err
+}
+
+func (e *aerospikeEngine) mapGet(name string, key string, bin string, mapKey string) (interface{}, error) {
+	internalKey, err := aerospike.NewKey(e.workspace, name, key)
+	if err != nil {
+		return nil, err
+	}
+	rec, err := e.client.Operate(nil, internalKey, aerospike.MapGetByKeyOp(bin, mapKey, aerospike.MapReturnType.VALUE))
+	if isResultCode(err, types.KEY_NOT_FOUND_ERROR) {
+		return nil, errNf
+	} else if err != nil {
+		return nil, err
+	}
+	if rec.Bins[bin] == nil {
+		return nil, errNf
+	}
+	return rec.Bins[bin], nil
+}
+
+func (e *aerospikeEngine) mapGetAll(name string, key string, bin string) (map[interface{}]interface{}, error) {
+	internalKey, err := aerospike.NewKey(e.workspace, name, key)
+	if err != nil {
+		return nil, err
+	}
+	rec, err := e.client.Get(nil, internalKey, bin)
+	if isResultCode(err, types.KEY_NOT_FOUND_ERROR) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	items, _ := rec.Bins[bin].(map[interface{}]interface{})
+	return items, nil
+}
+
+// Expiration 0 means "never" for the cache, for Aerospike it would be the namespace default TTL
+func ttlSeconds(expiration time.Duration) uint32 {
+	if expiration <= 0 {
+		return aerospike.TTLDontExpire
+	}
+	return uint32(expiration / time.Second)
+}
+
+// Runs the operations on a new record with the given TTL, or with the TTL kept if the record exists
+func (e *aerospikeEngine) operateCreating(internalKey *aerospike.Key, ttl uint32, ops ...*aerospike.Operation) (*aerospike.Record, error) {
+	create := aerospike.NewWritePolicy(0, ttl)
+	create.SendKey = true
+	create.RecordExistsAction = aerospike.CREATE_ONLY
+	rec, err := e.client.Operate(create, internalKey, ops...)
+	if isResultCode(err, types.KEY_EXISTS_ERROR) {
+		rec, err = e.client.Operate(keepTTLPolicy(), internalKey, ops...)
+	}
+	return rec, err
+}
+
+// Write policy for modifying existing records without touching their TTL
+func keepTTLPolicy() *aerospike.WritePolicy {
+	policy := aerospike.NewWritePolicy(0, aerospike.TTLDontUpdate)
+	policy.SendKey = true
+	return policy
+}
+
+func binInt(rec *aerospike.Record) (int64, error) {
+	switch n := rec.Bins["n"].(type) {
+	case int:
+		return int64(n), nil
+	case int64:
+		return n, nil
+	}
+	return 0, errors.New("counter is not an integer")
+}
+
+// Client side timeouts and unreachable nodes, errors the server answered with don't count
+func (e *aerospikeEngine) IsConnectionError(err error) bool {
+	ae, ok := err.(types.AerospikeError)
+	if !ok {
+		return false
+	}
+	switch ae.ResultCode() {
+	case types.TIMEOUT, types.SERVER_NOT_AVAILABLE, types.NO_AVAILABLE_CONNECTIONS_TO_NODE, types.INVALID_NODE_ERROR,
+		types.MAX_RETRIES_EXCEEDED, types.PARTITION_UNAVAILABLE, types.DEVICE_OVERLOAD:
+		return true
+	}
+	return false
+}
+
+func isResultCode(err error, code types.ResultCode) bool {
+	ae, ok := err.(types.AerospikeError)
+	return ok && ae.ResultCode() == code
+}
+
+// Values are always written as a blob into the bin "a"
+func binBytes(rec *aerospike.Record) ([]byte, error) {
+	if rec == nil {
+		return nil, errNf
+	}
+	data, ok := rec.Bins["a"].([]byte)
+	if !ok {
+		return nil, errors.New("cached value is not a blob, it was written before the codec layer")
+	}
+	return data, nil
+}
+
+func (e *aerospikeEngine) Del(name string, key string) error {
+	internalKey, err := aerospike.NewKey(e.workspace, name, key)
+	if err != nil {
+		return err
+	}
+	_, err = e.client.Delete(nil, internalKey)
+	return err
+}
+
+func (e *aerospikeEngine) Close() error {
+	e.client.Close()
+	return nil
+}
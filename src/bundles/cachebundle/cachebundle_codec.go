@@ -0,0 +1,97 @@
+package cachebundle
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/ugorji/go/codec"
+)
+
+// Turns values into the bytes stored by the engines and back. Every engine stores the output unchanged,
+// so a value written through one engine reads back identically through any other.
+type Codec interface {
+	Marshal(val interface{}) ([]byte, error)
+	Unmarshal(data []byte, target interface{}) error
+}
+
+const (
+	CodecJSON    = "json"
+	CodecMsgpack = "msgpack"
+	CodecGob     = "gob"
+)
+
+var (
+	codecLock   sync.RWMutex
+	activeCodec Codec = jsonCodec{}
+	codecs            = map[string]Codec{
+		CodecJSON:    jsonCodec{},
+		CodecMsgpack: msgpackCodec{handle: &codec.MsgpackHandle{WriteExt: true}},
+		CodecGob:     gobCodec{},
+	}
+)
+
+// Makes a custom codec selectable by name
+func RegisterCodec(name string, c Codec) {
+	codecLock.Lock()
+	defer codecLock.Unlock()
+	codecs[strings.ToLower(name)] = c
+}
+
+// Selects the codec used by Put and Get, JSON is used by default. Switching codecs makes existing entries unreadable.
+func SetCodec(name string) error {
+	codecLock.Lock()
+	defer codecLock.Unlock()
+	c, ok := codecs[strings.ToLower(name)]
+	if !ok {
+		return errors.New("unknown cache codec: " + name)
+	}
+	activeCodec = c
+	return nil
+}
+
+func getCodec() Codec {
+	codecLock.RLock()
+	defer codecLock.RUnlock()
+	return activeCodec
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(val interface{}) ([]byte, error) {
+	return json.Marshal(val)
+}
+
+func (jsonCodec) Unmarshal(data []byte, target interface{}) error {
+	return json.Unmarshal(data, target)
+}
+
+type msgpackCodec struct {
+	handle *codec.MsgpackHandle
+}
+
+func (m msgpackCodec) Marshal(val interface{}) ([]byte, error) {
+	var out []byte
+	err := codec.NewEncoderBytes(&out, m.handle).Encode(val)
+	return out, err
+}
+
+func (m msgpackCodec) Unmarshal(data []byte, target interface{}) error {
+	return codec.NewDecoderBytes(data, m.handle).Decode(target)
+}
+
+// Interface values inside gob encoded structs need to be registered with gob.Register
+type gobCodec struct{}
+
+func (gobCodec) Marshal(val interface{}) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	err := gob.NewEncoder(buf).Encode(val)
+	return buf.Bytes(), err
+}
+
+func (gobCodec) Unmarshal(data []byte, target interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(target)
+}
@@ -0,0 +1,255 @@
+package cachebundle
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sc-js/backend_core/src/tools"
+)
+
+const conformanceName = "conformance_"
+
+type conformanceNested struct {
+	Label string
+	Score float64
+}
+
+type conformanceStruct struct {
+	ID      tools.ModelID
+	Name    string
+	Count   int
+	Enabled bool
+	Tags    []string
+	Values  map[string]int
+	Nested  conformanceNested
+	Ptr     *conformanceNested
+}
+
+func TestMain(m *testing.M) {
+	tools.Init("conformance")
+	os.Exit(m.Run())
+}
+
+func TestMemoryConformance(t *testing.T) {
+	engine := newMemoryEngine()
+	if err := engine.Connect(EngineConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	runConformance(t, engine)
+}
+
+// Checks that an engine behaves like the built-in ones together with every codec: all supported types
+// round-trip unchanged, missing keys return ErrNotFound, deletes and expirations work. Optional interfaces the engine implements are checked as well.
+// The checks use keys prefixed with "conformance_".
+func runConformance(t *testing.T, engine CacheEngine) {
+	for name, c := range codecs {
+		t.Run("codec_"+name, func(t *testing.T) {
+			if err := conformCodec(engine, c); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+	t.Run("keys", func(t *testing.T) {
+		if err := conformKeys(engine); err != nil {
+			t.Error(err)
+		}
+	})
+	t.Run("optional", func(t *testing.T) {
+		if err := conformOptional(engine); err != nil {
+			t.Error(err)
+		}
+	})
+}
+
+func conformCodec(engine CacheEngine, c Codec) error {
+	checks := []error{
+		roundTrip(engine, c, "string", "Hello, 世界"),
+		roundTrip(engine, c, "empty_string", ""),
+		roundTrip(engine, c, "int", -42),
+		roundTrip(engine, c, "int64", int64(1)<<40),
+		roundTrip(engine, c, "uint64", uint64(1)<<63),
+		roundTrip(engine, c, "float64", 3.25),
+		roundTrip(engine, c, "bool", true),
+		roundTrip(engine, c, "bool_false", false),
+		roundTrip(engine, c, "bytes", []byte{0, 1, 2, 255}),
+		roundTrip(engine, c, "model_id", tools.ModelID(1337)),
+		roundTrip(engine, c, "slice", []string{"a", "b"}),
+		roundTrip(engine, c, "map", map[string]int{"a": 1, "b": 2}),
+		roundTrip(engine, c, "struct", conformanceStruct{ID: 7, Name: "x", Count: 3, Enabled: true, Tags: []string{"t"}, Values: map[string]int{"v": 1}, Nested: conformanceNested{Label: "n", Score: 0.5}, Ptr: &conformanceNested{Label: "p"}}),
+		roundTrip(engine, c, "struct_pointer", &conformanceNested{Label: "p", Score: 1}),
+	}
+	return errors.Join(checks...)
+}
+
+// Missing keys, deletes and expirations
+func conformKeys(engine CacheEngine) error {
+	if _, err := engine.Get(conformanceName, "missing"); !IsNotFound(err) {
+		return fmt.Errorf("missing key: expected ErrNotFound, got %v", err)
+	}
+
+	// Name and key must not simply be concatenated, locales like de and de_DE would share entries otherwise
+	engine.Put(conformanceName+"de_", "DE_x", []byte("de"), time.Minute)
+	engine.Put(conformanceName+"de_DE_", "x", []byte("de_DE"), time.Minute)
+	val, err := engine.Get(conformanceName+"de_", "DE_x")
+	engine.Del(conformanceName+"de_", "DE_x")
+	engine.Del(conformanceName+"de_DE_", "x")
+	if err != nil || string(val) != "de" {
+		return fmt.Errorf("names: entries of different names collide, got %q (%v)", val, err)
+	}
+
+	if err := engine.Put(conformanceName, "delete", []byte("x"), 0); err != nil {
+		return fmt.Errorf("delete: %w", err)
+	}
+	if err := engine.Del(conformanceName, "delete"); err != nil {
+		return fmt.Errorf("delete: %w", err)
+	}
+	if _, err := engine.Get(conformanceName, "delete"); !IsNotFound(err) {
+		return fmt.Errorf("delete: key still readable (%v)", err)
+	}
+	if err := engine.Del(conformanceName, "delete"); err != nil && !IsNotFound(err) {
+		return fmt.Errorf("delete of missing key: %w", err)
+	}
+
+	if err := engine.Put(conformanceName, "expire", []byte("x"), time.Second); err != nil {
+		return fmt.Errorf("expire: %w", err)
+	}
+	if _, err := engine.Get(conformanceName, "expire"); err != nil {
+		return fmt.Errorf("expire: key gone before its ttl (%v)", err)
+	}
+	time.Sleep(time.Millisecond * 2100)
+	if _, err := engine.Get(conformanceName, "expire"); !IsNotFound(err) {
+		return errors.New("expire: key still readable after its ttl")
+	}
+	return nil
+}
+
+// Checks the optional interfaces the engine implements
+func conformOptional(engine CacheEngine) error {
+	if batch, ok := engine.(BatchEngine); ok {
+		if err := batch.MPut(conformanceName, map[string][]byte{"batch_a": []byte("a"), "batch_b": []byte("b")}, time.Minute); err != nil {
+			return fmt.Errorf("mput: %w", err)
+		}
+		values, err := batch.MGet(conformanceName, []string{"batch_a", "batch_b", "batch_missing"})
+		if err != nil || len(values) != 2 || string(values["batch_b"]) != "b" {
+			return fmt.Errorf("mget: expected 2 values, got %v (%v)", values, err)
+		}
+	}
+
+	if scanner, ok := engine.(ScanEngine); ok {
+		engine.Put(conformanceName, "prefix_other", []byte("x"), time.Minute)
+		deleted, err := scanner.DelPrefix(conformanceName, "batch_")
+		if err != nil || deleted != 2 {
+			return fmt.Errorf("delprefix: expected 2 deleted keys, got %d (%v)", deleted, err)
+		}
+		if _, err := engine.Get(conformanceName, "prefix_other"); err != nil {
+			return fmt.Errorf("delprefix: deleted a key without the prefix (%v)", err)
+		}
+		engine.Del(conformanceName, "prefix_other")
+	}
+
+	if ttl, ok := engine.(TTLEngine); ok {
+		engine.Put(conformanceName, "ttl", []byte("x"), 0)
+		defer engine.Del(conformanceName, "ttl")
+		if d, err := ttl.TTL(conformanceName, "ttl"); err != nil || d != 0 {
+			return fmt.Errorf("ttl: expected no expiration, got %v (%v)", d, err)
+		}
+		if err := ttl.Expire(conformanceName, "ttl", time.Minute); err != nil {
+			return fmt.Errorf("expire: %w", err)
+		}
+		if d, err := ttl.TTL(conformanceName, "ttl"); err != nil || d <= 0 || d > time.Minute {
+			return fmt.Errorf("ttl: expected up to a minute, got %v (%v)", d, err)
+		}
+		if err := ttl.Persist(conformanceName, "ttl"); err != nil {
+			return fmt.Errorf("persist: %w", err)
+		}
+		if d, err := ttl.TTL(conformanceName, "ttl"); err != nil || d != 0 {
+			return fmt.Errorf("persist: expected no expiration, got %v (%v)", d, err)
+		}
+		if _, err := ttl.TTL(conformanceName, "missing"); !IsNotFound(err) {
+			return fmt.Errorf("ttl of missing key: expected ErrNotFound, got %v", err)
+		}
+		if err := ttl.Expire(conformanceName, "missing", time.Minute); !IsNotFound(err) {
+			return fmt.Errorf("expire of missing key: expected ErrNotFound, got %v", err)
+		}
+	}
+
+	if counter, ok := engine.(CounterEngine); ok {
+		defer engine.Del(conformanceName, "counter")
+		counter.IncrBy(conformanceName, "counter", 5, time.Minute)
+		n, err := counter.IncrBy(conformanceName, "counter", -2, 0)
+		if err != nil || n != 3 {
+			return fmt.Errorf("incrby: expected 3, got %d (%v)", n, err)
+		}
+		if n, err := counter.Counter(conformanceName, "counter"); err != nil || n != 3 {
+			return fmt.Errorf("counter: expected 3, got %d (%v)", n, err)
+		}
+		if ttl, ok := engine.(TTLEngine); ok {
+			if d, err := ttl.TTL(conformanceName, "counter"); err != nil || d <= 0 {
+				return fmt.Errorf("incrby: expected the expiration of the first call to be kept, got %v (%v)", d, err)
+			}
+		}
+		if _, err := counter.Counter(conformanceName, "counter_missing"); !IsNotFound(err) {
+			return fmt.Errorf("missing counter: expected ErrNotFound, got %v", err)
+		}
+	}
+
+	if coll, ok := engine.(CollectionEngine); ok {
+		defer engine.Del(conformanceName, "set")
+		defer engine.Del(conformanceName, "hash")
+		coll.SAdd(conformanceName, "set", []string{"a", "b", "c"})
+		coll.SRem(conformanceName, "set", []string{"b"})
+		members, err := coll.SMembers(conformanceName, "set")
+		if err != nil || len(members) != 2 {
+			return fmt.Errorf("smembers: expected 2 members, got %v (%v)", members, err)
+		}
+		if is, err := coll.SIsMember(conformanceName, "set", "b"); err != nil || is {
+			return fmt.Errorf("sismember: removed member still in the set (%v)", err)
+		}
+		if is, err := coll.SIsMember(conformanceName, "set", "a"); err != nil || !is {
+			return fmt.Errorf("sismember: member missing (%v)", err)
+		}
+		if members, err := coll.SMembers(conformanceName, "set_missing"); err != nil || len(members) != 0 {
+			return fmt.Errorf("smembers of missing key: expected empty set, got %v (%v)", members, err)
+		}
+
+		coll.HSet(conformanceName, "hash", map[string][]byte{"f1": []byte("1"), "f2": []byte("2")})
+		coll.HDel(conformanceName, "hash", []string{"f1"})
+		if val, err := coll.HGet(conformanceName, "hash", "f2"); err != nil || string(val) != "2" {
+			return fmt.Errorf("hget: expected 2, got %q (%v)", val, err)
+		}
+		if _, err := coll.HGet(conformanceName, "hash", "f1"); !IsNotFound(err) {
+			return fmt.Errorf("hget of deleted field: expected ErrNotFound, got %v", err)
+		}
+		if all, err := coll.HGetAll(conformanceName, "hash"); err != nil || len(all) != 1 {
+			return fmt.Errorf("hgetall: expected 1 field, got %v (%v)", all, err)
+		}
+	}
+	return nil
+}
+
+func roundTrip[T any](engine CacheEngine, c Codec, key string, val T) error {
+	data, err := c.Marshal(val)
+	if err != nil {
+		return fmt.Errorf("%s: encode: %w", key, err)
+	}
+	if err := engine.Put(conformanceName, key, data, time.Minute); err != nil {
+		return fmt.Errorf("%s: put: %w", key, err)
+	}
+	defer engine.Del(conformanceName, key)
+	stored, err := engine.Get(conformanceName, key)
+	if err != nil {
+		return fmt.Errorf("%s: get: %w", key, err)
+	}
+	var result T
+	if err := c.Unmarshal(stored, &result); err != nil {
+		return fmt.Errorf("%s: decode: %w", key, err)
+	}
+	if !reflect.DeepEqual(val, result) {
+		return fmt.Errorf("%s: expected %#v, got %#v", key, val, result)
+	}
+	return nil
+}
@@ -1,258 +1,191 @@
 package cachebundle
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
-	"reflect"
 	"time"
 
-	"github.com/aerospike/aerospike-client-go"
-	"github.com/go-redis/redis"
 	"github.com/sc-js/backend_core/src/tools"
 	"github.com/sc-js/pour"
-	"golang.org/x/net/context"
 )
 
+// Names of the built-in engines, additional ones can be added with RegisterEngine
 const (
-	AerospikeDefaultWorkspace = "aero_default"
-	AeroSpike                 = 0
-	Redis                     = 1
+	ENGINE_AEROSPIKE = "aerospike"
+	ENGINE_REDIS     = "redis"
+	// Per process, entries aren't shared between replicas. Only for single node setups and development
+	ENGINE_MEMORY = "memory"
 )
 
+// Engine ids of InitCache, kept for existing callers
+const (
+	AeroSpike = 0
+	Redis     = 1
+)
+
+var legacyEngineNames = map[int]string{AeroSpike: ENGINE_AEROSPIKE, Redis: ENGINE_REDIS}
+
 var (
-	connectedModule = -1
-	redisClient     *redis.Client
-	aeroClient      *aerospike.Client
-	errNf           = errors.New("key not found")
-	workspace       = ""
+	activeEngine     CacheEngine
+	activeEngineName string
+	// Returned by Get and by engines for missing keys
+	ErrNotFound = errors.New("key not found")
+	errNf       = ErrNotFound
+	errNoEngine = errors.New("no module connected")
 )
 
 // This function initializes the cache bundle, allowing the user to choose between Aerospike or Redis.
 // It also initializes the necessary callbacks for the application.
 // Reads Translations for locales form a given path and stores it into the cache.
 func InitCache(val int, address string, portOverride uint, username string, password string, space string) {
-	workspace = space
-
-	ctx, ctxFunc := context.WithTimeout(context.TODO(), time.Second*5)
-
-	defer ctxFunc()
-	for {
-		select {
-		case <-ctx.Done():
-			if redisClient == nil && aeroClient == nil {
-				pour.LogErr(errors.New("connection to cache timed out"))
-			}
-			return
-		default:
-			if val == AeroSpike {
-				initAero(address, portOverride, username, password, ctxFunc)
-			}
-			if val == Redis {
-				initRedis(address, portOverride, username, password, ctxFunc)
-			}
-			connectedModule = val
-			ReadTSJson(translation_path, true)
-			tools.TranslationCallback = TranslateStruct
-			tools.ValidatorCallback = validateLocale
-			tools.SingleTranslationCallback = GetTS
-			return
-		}
+	engineName, ok := legacyEngineNames[val]
+	if !ok {
+		pour.LogPanicKill(1, errors.New("unknown cache engine id: "+fmt.Sprint(val)))
 	}
+	InitCacheWithConfig(engineName, EngineConfig{Address: address, PortOverride: portOverride, Username: username, Password: password, Workspace: space})
 }
 
-// Initializes AeroSpike as Cache Engine
-func initAero(address string, portOverride uint, username string, password string, ctxFunc context.CancelFunc) {
-	var err error
-	port := 3000
-	if portOverride > 0 {
-		port = int(portOverride)
-	}
-	aeroClient, err = aerospike.NewClient(address, port)
+// Initializes the cache bundle with the engine registered under the given name (aerospike, redis, memory or a custom one)
+func InitCacheWithConfig(engineName string, config EngineConfig) {
+	engine, err := newEngine(engineName)
 	if err != nil {
-		aeroClient = nil
 		pour.LogPanicKill(1, err)
 	}
-	pour.LogColor(false, pour.ColorPurple, "AeroSpike connected at", address+":"+fmt.Sprint(port))
-	ctxFunc()
-}
-
-// Initializes Redis as Cache Engine
-func initRedis(address string, portOverride uint, username string, password string, ctxFunc context.CancelFunc) {
-
-	port := 6379
-	if portOverride > 0 {
-		port = int(portOverride)
+	if len(config.Codec) > 0 {
+		if err := SetCodec(config.Codec); err != nil {
+			pour.LogPanicKill(1, err)
+		}
+	}
+	if config.BreakerThreshold > 0 {
+		breakerThreshold = int32(config.BreakerThreshold)
 	}
+	if config.BreakerCooldown > 0 {
+		breakerCooldown = config.BreakerCooldown
+	}
+	activeEngine = engine
+	activeEngineName = engineName
+	engineConfig = config
+	initL1(config)
+
+	tools.TranslationCallback = TranslateStruct
+	tools.ValidatorCallback = validateLocale
+	tools.SingleTranslationCallback = GetTS
+	tools.ResponseCacheCallback = ResponseCacheMiddleware
+
+	if !connectWithPolicy(engineName, engine, config) {
+		go recoverEngine(false)
+		return
+	}
+	available.Store(true)
+	onConnected()
+}
 
-	redisClient = redis.NewClient(&redis.Options{
-		Addr:     address + ":" + fmt.Sprint(port), //redis port
-		Password: password,
-	})
-	_, err := redisClient.Ping().Result()
-	if err != nil {
-		redisClient = nil
-		pour.LogPanicKill(1, err)
-	} else {
-		pour.LogColor(false, pour.ColorPurple, "Redis connected at", address+":"+fmt.Sprint(port))
-		ctxFunc()
+// Everything that needs a reachable engine, after a degraded start it runs once the engine connects
+func onConnected() {
+	if len(engineConfig.LegacyNames) > 0 && activeEngineName == ENGINE_REDIS {
+		if _, err := MigrateRedisKeys(engineConfig.LegacyNames, nil); err != nil {
+			pour.LogColor(false, pour.ColorRed, "Migrating legacy cache keys failed:", err)
+		}
 	}
+	attachBus()
+	ReadTSJson(translation_path, !engineConfig.ScheduledTranslationRefresh)
 }
 
 // This method works like th Put Method, but it also takes in an expiration time,
 // after which the record will be automatically removed from the cache
 func PutExpire[T any](name string, key string, val T, expiration time.Duration) error {
-
-	switch connectedModule {
-	case AeroSpike:
-		internalKey, err := aerospike.NewKey(workspace, name, key)
-		if err != nil {
-			return err
-		}
-		bin := aerospike.BinMap{"a": val}
-		policy := aerospike.NewWritePolicy(0, uint32(expiration/time.Second))
-		return aeroClient.Put(policy, internalKey, bin)
-	case Redis:
-		return redisClient.Set(name+key, val, expiration).Err()
-	}
-
-	return errors.New("no module connected")
+	return put(name, key, val, expiration)
 }
 
-// This method takes in a string, a key, and a value of any type. It then checks which connected module is in use.
-// If the module is AeroSpike, it will create an internal key from the workspace, name, and key values and store the value in a bin.
-// If the module is Redis, it will set the name, key, and value and return the result of the Set method.
-// If none of the modules are connected, it will return an error.
+// Stores a value of any type under the name/key pair in the connected engine, without an expiration.
+// If no engine is connected, it will return an error.
 func Put[T any](name string, key string, val T) error {
-
-	switch connectedModule {
-	case AeroSpike:
-		internalKey, err := aerospike.NewKey(workspace, name, key)
-		if err != nil {
-			return err
-		}
-		bin := aerospike.BinMap{"a": val}
-		return aeroClient.Put(nil, internalKey, bin)
-	case Redis:
-		return redisClient.Set(name+key, val, 0).Err()
-	}
-
-	return errors.New("no module connected")
+	return put(name, key, val, 0)
 }
 
-// This method retrieves a value of a specified type (T) from either an AeroSpike or Redis database, depending on the connected module.
-// It uses the workspace name and key provided to search for the value and returns the value or an error if the value is not found.
-// It also handles different types of values and can convert them to the desired type.
+// This method retrieves a value of a specified type (T) from the connected engine.
+// It uses the name and key provided to search for the value and returns the value or ErrNotFound if the value is not found.
+// Values round-trip through the active codec, so every T reads back the same on every engine.
 func Get[T any](name string, key string) (T, error) {
-
 	var result T
-	switch connectedModule {
-	case AeroSpike:
-		internalKey, err := aerospike.NewKey(workspace, name, key)
-		if err != nil {
-			return result, err
+	data, err := getRaw(name, key)
+	if err != nil {
+		return result, err
+	}
+	err = getCodec().Unmarshal(data, &result)
+	return result, err
+}
+
+// Reads many keys of a name at once, engines without batch support are queried key by key.
+// Missing keys and values that can't be decoded are left out.
+func getMany[T any](name string, keys []string) (map[string]T, error) {
+	result := map[string]T{}
+	raw := map[string][]byte{}
+	var generation uint64
+	if l1 != nil {
+		generation = l1.generation.Load()
+		missing := make([]string, 0, len(keys))
+		for _, key := range keys {
+			if data, ok := l1.get(name, key); ok {
+				raw[key] = data
+			} else {
+				missing = append(missing, key)
+			}
 		}
-		rec, err := aeroClient.Get(nil, internalKey)
-		if err != nil {
-			return result, err
+		keys = missing
+	}
+	fetched, err := guarded(func() (map[string][]byte, error) {
+		if len(keys) == 0 {
+			return nil, nil
 		}
-		for _, v := range rec.Bins {
-			result = v.(T)
-			return result, nil
+		if batch, ok := activeEngine.(BatchEngine); ok {
+			return batch.MGet(name, keys)
 		}
-	case Redis:
-		v := reflect.ValueOf(new(T))
-		switch reflect.TypeOf(result).Name() {
-		case "float64":
-			data, err := redisClient.Get(name + key).Float64()
-			if err != nil {
-				return result, errNf
-			}
-			v.Elem().Set(reflect.ValueOf(data))
-			return v.Elem().Interface().(T), nil
-		case "int":
-			data, err := redisClient.Get(name + key).Int()
-			if err != nil {
-				return result, errNf
-			}
-			v.Elem().Set(reflect.ValueOf(data))
-			return v.Elem().Interface().(T), nil
-		case "int64":
-			data, err := redisClient.Get(name + key).Int64()
-			if err != nil {
-				return result, errNf
-			}
-			v.Elem().Set(reflect.ValueOf(data))
-			return v.Elem().Interface().(T), nil
-		case "string":
-			err := redisClient.Get(name + key).Err()
+		raw := map[string][]byte{}
+		for _, key := range keys {
+			data, err := activeEngine.Get(name, key)
 			if err == nil {
-				return result, errNf
-			}
-			data := redisClient.Get(name + key).String()
-			v.Elem().Set(reflect.ValueOf(data))
-			return v.Elem().Interface().(T), nil
-		case "uint64":
-			data, err := redisClient.Get(name + key).Uint64()
-			if err != nil {
-				return result, errNf
-			}
-			v.Elem().Set(reflect.ValueOf(data))
-			return v.Elem().Interface().(T), nil
-		case "float32":
-			data, err := redisClient.Get(name + key).Float32()
-			if err != nil {
-				return result, errNf
-			}
-			v.Elem().Set(reflect.ValueOf(data))
-			return v.Elem().Interface().(T), nil
-		case "bool":
-			data, err := redisClient.Get(name + key).Int()
-			if err != nil {
-				return result, errNf
+				raw[key] = data
+			} else if !IsNotFound(err) {
+				return raw, err
 			}
-			v.Elem().Set(reflect.ValueOf(data != 0))
-			return v.Elem().Interface().(T), nil
-
-		default:
-			data, err := redisClient.Get(name + key).Bytes()
-			if err != nil {
-				return result, errNf
-			}
-			refValue := reflect.ValueOf(result)
-			if refValue.Kind() == reflect.Slice {
-				if refValue.Type().Elem().Kind() == reflect.Uint8 {
-					v.Elem().Set(reflect.ValueOf(data))
-					return v.Elem().Interface().(T), nil
-				}
-			}
-			var v T
-			err = json.Unmarshal(data, &v)
-			return v, err
+		}
+		return raw, nil
+	})
+	if err != nil {
+		return result, err
+	}
+	for key, data := range fetched {
+		raw[key] = data
+		if l1 != nil {
+			l1.put(name, key, data, generation)
+		}
+	}
+	c := getCodec()
+	for key, data := range raw {
+		var val T
+		if err := c.Unmarshal(data, &val); err == nil {
+			result[key] = val
 		}
 	}
-	pour.LogPanicKill(1, errors.New("no module connected"))
-	return result, errors.New("no module connected")
+	return result, nil
 }
 
-// This method deletes a given workspace/key from the cache, depending on the connected module.
-func Del(name string, key string) error {
-
-	switch connectedModule {
-	case AeroSpike:
-		{
-			internalKey, err := aerospike.NewKey(workspace, name, key)
-			if err != nil {
-				return err
-			}
-			_, err = aeroClient.Delete(nil, internalKey)
-			return err
-		}
-	case Redis:
-		{
-			_, err := redisClient.Del(name + key).Result()
-			return err
-		}
+func put(name string, key string, val interface{}, expiration time.Duration) error {
+	data, err := getCodec().Marshal(val)
+	if err != nil {
+		return err
 	}
-	return errors.New("no module connected")
+	defer invalidateL1(name, key, false)
+	return guard(func() error {
+		return activeEngine.Put(name, key, data, expiration)
+	})
+}
+
+// This method deletes a given name/key from the cache
+func Del(name string, key string) error {
+	defer invalidateL1(name, key, false)
+	return guard(func() error {
+		return activeEngine.Del(name, key)
+	})
 }
@@ -0,0 +1,160 @@
+package cachebundle
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A storage backend of the cache bundle. Values are addressed by a name (the "table" or set, e.G. user_session)
+// and a key within it. Engines only store bytes, encoding is done by the active Codec.
+// Get has to return errNf (see IsNotFound) for missing or expired keys.
+type CacheEngine interface {
+	Connect(config EngineConfig) error
+	Put(name string, key string, val []byte, expiration time.Duration) error
+	Get(name string, key string) ([]byte, error)
+	Del(name string, key string) error
+	Close() error
+}
+
+// Optional, engines which can read many keys in one round-trip. Missing keys are left out of the result.
+type BatchEngine interface {
+	MGet(name string, keys []string) (map[string][]byte, error)
+	MPut(name string, entries map[string][]byte, expiration time.Duration) error
+}
+
+// Optional, engines which can list every key/value stored under a name
+type ScanEngine interface {
+	Scan(name string) (map[string][]byte, error)
+	// Deletes every key under the name starting with prefix and returns how many were deleted
+	DelPrefix(name string, prefix string) (int, error)
+}
+
+// Optional, expirations of existing keys. Missing keys return ErrNotFound.
+type TTLEngine interface {
+	// Remaining lifetime of the key, 0 if it doesn't expire
+	TTL(name string, key string) (time.Duration, error)
+	Expire(name string, key string, expiration time.Duration) error
+	Persist(name string, key string) error
+}
+
+// Optional, atomic counters. Counters are stored natively by the engine, so they are read with Counter instead of Get.
+type CounterEngine interface {
+	// Adds delta and returns the new value, missing counters start at 0.
+	// The expiration only applies when the counter is created, later calls keep it. 0 doesn't expire.
+	IncrBy(name string, key string, delta int64, expiration time.Duration) (int64, error)
+	Counter(name string, key string) (int64, error)
+}
+
+// Optional, sets of strings and hashes of fields stored under a single key. Writes keep the key's expiration.
+type CollectionEngine interface {
+	SAdd(name string, key string, members []string) error
+	SRem(name string, key string, members []string) error
+	// Members of the set, empty if the key doesn't exist
+	SMembers(name string, key string) ([]string, error)
+	SIsMember(name string, key string, member string) (bool, error)
+	HSet(name string, key string, fields map[string][]byte) error
+	// Returns ErrNotFound if the key or field doesn't exist
+	HGet(name string, key string, field string) ([]byte, error)
+	HDel(name string, key string, fields []string) error
+	// Fields of the hash, empty if the key doesn't exist
+	HGetAll(name string, key string) (map[string][]byte, error)
+}
+
+// Optional, atomic operations needed for distributed locks
+type LockEngine interface {
+	// Stores the value only if the key doesn't exist, reports whether it was stored
+	PutIfAbsent(name string, key string, val []byte, expiration time.Duration) (bool, error)
+	// Resets the expiration if the key still holds val
+	CompareAndExpire(name string, key string, val []byte, expiration time.Duration) (bool, error)
+	// Deletes the key if it still holds val
+	CompareAndDel(name string, key string, val []byte) (bool, error)
+}
+
+// Optional, engines implementing it broadcast published messages to every node.
+// Without it messages are only delivered inside the process.
+type PubSubEngine interface {
+	Publish(topic string, payload []byte) error
+	// Calls receive for every message published on topic until the engine is closed
+	Subscribe(topic string, receive func(payload []byte)) error
+}
+
+// Optional, tells connection failures apart from errors the engine answered with.
+// Engines without it only count network errors and timeouts as failures.
+type FailureEngine interface {
+	IsConnectionError(err error) bool
+}
+
+type EngineConfig struct {
+	Address      string
+	PortOverride uint
+	Username     string
+	Password     string
+	// Namespace of all keys. Aerospike uses AerospikeDefaultWorkspace if empty, Redis keys then stay unprefixed.
+	Workspace string
+	// Seed list of host:port addresses for Sentinel and Cluster, Address and PortOverride are used otherwise
+	Addresses []string
+	// Redis only: database index (not available on clusters), sentinel master name and TLS
+	DB            int
+	MasterName    string
+	Cluster       bool
+	TLS           bool
+	TLSSkipVerify bool
+	// Redis only: names whose keys are moved from the layout before namespacing (name+key) on connect, see MigrateRedisKeys
+	LegacyNames []string
+	// Upper bound of entries, only used by engines which keep their data in process
+	MaxEntries int
+	// json (default), msgpack, gob or a codec registered with RegisterCodec
+	Codec string
+	// STARTUP_FAIL (default), STARTUP_DEGRADE or STARTUP_WAIT, connecting is retried with backoff for StartupTimeout (default 30s)
+	StartupPolicy  string
+	StartupTimeout time.Duration
+	// Failed calls in a row which open the circuit (default 5), while open the engine is probed every BreakerCooldown (default 5s)
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+	// Entries of the in-process tier in front of remote engines, 0 disables it
+	L1MaxEntries int
+	// How long values of a name stay in L1, a trailing * matches name prefixes. DefaultL1Policies if nil.
+	L1Policies map[string]time.Duration
+	// Skips the built-in translation refresh loop, RefreshTranslations is then called by a scheduled task
+	ScheduledTranslationRefresh bool
+}
+
+type EngineFactory func() CacheEngine
+
+var (
+	engineLock sync.RWMutex
+	engines    = map[string]EngineFactory{
+		ENGINE_AEROSPIKE: func() CacheEngine { return &aerospikeEngine{} },
+		ENGINE_REDIS:     func() CacheEngine { return &redisEngine{} },
+		ENGINE_MEMORY:    func() CacheEngine { return newMemoryEngine() },
+	}
+)
+
+// Makes a custom engine selectable by name, has to be called before InitCache
+func RegisterEngine(name string, factory EngineFactory) {
+	engineLock.Lock()
+	defer engineLock.Unlock()
+	engines[strings.ToLower(name)] = factory
+}
+
+func newEngine(name string) (CacheEngine, error) {
+	engineLock.RLock()
+	defer engineLock.RUnlock()
+	factory, ok := engines[strings.ToLower(name)]
+	if !ok {
+		return nil, errors.New("unknown cache engine: " + name)
+	}
+	return factory(), nil
+}
+
+// Reports whether err means the key doesn't exist, custom engines return ErrNotFound for this
+func IsNotFound(err error) bool {
+	return err == errNf
+}
+
+// Returns the connected engine, nil if InitCache wasn't called yet
+func GetEngine() CacheEngine {
+	return activeEngine
+}
@@ -0,0 +1,146 @@
+package cachebundle
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sc-js/backend_core/src/tools"
+	"github.com/sc-js/pour"
+	"gorm.io/gorm"
+)
+
+// Tables opted in with CacheModel, writes to other tables skip the invalidation round-trips
+var (
+	cachedTables     = map[string]bool{}
+	cachedTablesLock sync.RWMutex
+)
+
+// Opts a model into GetByIdCached. Its records are dropped from the cache whenever they are saved or deleted,
+// as long as RegisterGormInvalidation was called for the database. Every node has to opt in the same models.
+func CacheModel[T any](db *gorm.DB) error {
+	table, err := tableName[T](db)
+	if err != nil {
+		return err
+	}
+	cachedTablesLock.Lock()
+	cachedTables[table] = true
+	cachedTablesLock.Unlock()
+	return nil
+}
+
+func isCachedTable(table string) bool {
+	cachedTablesLock.RLock()
+	defer cachedTablesLock.RUnlock()
+	return cachedTables[table]
+}
+
+// Cached variant of tools.GetSingleById, models which weren't opted in with CacheModel are read from the database.
+// Only the id is part of the cache key, so don't pass a db with extra conditions (scopes, Unscoped, ...).
+func GetSingleByIdCached[T any](c *gin.Context, db *gorm.DB, ttl time.Duration) (T, error) {
+	return GetByIdCached[T](tools.Decode(c.Param("hid")), db, ttl)
+}
+
+func GetByIdCached[T any](id tools.ModelID, db *gorm.DB, ttl time.Duration) (T, error) {
+	table, err := tableName[T](db)
+	if err != nil {
+		return *new(T), err
+	}
+	load := func() (T, error) {
+		t := new(T)
+		err := db.Where("id=?", id).First(t).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return *t, ErrNotFound
+		}
+		return *t, err
+	}
+	if !isCachedTable(table) {
+		return load()
+	}
+	opts := DefaultLoadOptions
+	opts.NegativeTTL = ttl / 10
+	return GetOrLoadWithOptions(modelCacheName(table), modelCacheKey(table, id), ttl, opts, load)
+}
+
+// Registers gorm callbacks which invalidate GetByIdCached entries after creates, updates and deletes of models opted in with CacheModel.
+// Statements without a primary key (e.G. batch updates with Where) invalidate the whole model.
+func RegisterGormInvalidation(db *gorm.DB) error {
+	callbacks := db.Callback()
+	if err := callbacks.Create().After("gorm:create").Register("cachebundle:invalidate", invalidateModel); err != nil {
+		return err
+	}
+	if err := callbacks.Update().After("gorm:update").Register("cachebundle:invalidate", invalidateModel); err != nil {
+		return err
+	}
+	return callbacks.Delete().After("gorm:delete").Register("cachebundle:invalidate", invalidateModel)
+}
+
+func invalidateModel(db *gorm.DB) {
+	if db.Error != nil || db.Statement.Schema == nil {
+		return
+	}
+	table := db.Statement.Schema.Table
+	if !isCachedTable(table) {
+		return
+	}
+	ids, ok := primaryKeys(db)
+	if !ok {
+		// Atomic, concurrent invalidations must not collapse into one bump
+		if _, err := Incr("model_generation", table, 0); err != nil {
+			pour.LogColor(false, pour.ColorYellow, "Could not invalidate cached", table+":", err)
+		}
+		return
+	}
+	for _, id := range ids {
+		Invalidate(modelCacheName(table), modelCacheKey(table, id))
+	}
+}
+
+// Collects the primary keys of the statement's model, false if any of them is unknown
+func primaryKeys(db *gorm.DB) ([]interface{}, bool) {
+	field := db.Statement.Schema.PrioritizedPrimaryField
+	if field == nil {
+		return nil, false
+	}
+	value := reflect.Indirect(db.Statement.ReflectValue)
+	values := []reflect.Value{value}
+	if value.Kind() == reflect.Slice || value.Kind() == reflect.Array {
+		values = values[:0]
+		for i := 0; i < value.Len(); i++ {
+			values = append(values, reflect.Indirect(value.Index(i)))
+		}
+	}
+	ids := []interface{}{}
+	for _, v := range values {
+		if v.Kind() != reflect.Struct {
+			return nil, false
+		}
+		id, zero := field.ValueOf(db.Statement.Context, v)
+		if zero {
+			return nil, false
+		}
+		ids = append(ids, id)
+	}
+	return ids, len(ids) > 0
+}
+
+func tableName[T any](db *gorm.DB) (string, error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(new(T)); err != nil {
+		return "", err
+	}
+	return stmt.Schema.Table, nil
+}
+
+func modelCacheName(table string) string {
+	return "model_" + table
+}
+
+// The generation is bumped when a statement can't tell which records it changed
+func modelCacheKey(table string, id interface{}) string {
+	generation, _ := GetCounter("model_generation", table)
+	return fmt.Sprint(generation, ":", id)
+}
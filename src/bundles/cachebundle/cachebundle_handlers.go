@@ -0,0 +1,163 @@
+package cachebundle
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sc-js/backend_core/src/tools"
+)
+
+// Lists the locales with their completion
+func getLocalesHandler(c *gin.Context) {
+	stats, err := TranslationStats()
+	if err != nil {
+		tools.RespondError(err, http.StatusInternalServerError, c)
+		return
+	}
+	tools.RespondWithJSON(c, http.StatusOK, &stats)
+}
+
+func addLocaleHandler(c *gin.Context) {
+	req := localeRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		tools.RespondError(err, http.StatusBadRequest, c)
+		return
+	}
+	if err := AddLocale(req.Locale); err != nil {
+		respondLocaleError(err, c)
+		return
+	}
+	respondLocaleStats(req.Locale, c)
+}
+
+func removeLocaleHandler(c *gin.Context) {
+	if err := RemoveLocale(c.Param("locale")); err != nil {
+		respondLocaleError(err, c)
+		return
+	}
+	tools.RespondWithJSON(c, http.StatusOK, "locale_removed")
+}
+
+// Keys of a locale sorted by name, "untranslated=true" only returns the ones without a value, "search" filters keys and values
+func getTranslationsHandler(c *gin.Context) {
+	locale := c.Param("locale")
+	entries, err := TranslationEntries(locale)
+	if err != nil {
+		respondLocaleError(err, c)
+		return
+	}
+	stats, err := TranslationStatsOf(locale)
+	if err != nil {
+		respondLocaleError(err, c)
+		return
+	}
+	untranslated := c.Query("untranslated") == "true"
+	search := strings.ToLower(c.Query("search"))
+	result := localeEntries{LocaleStats: stats, Entries: []translationEntry{}}
+	for key, val := range entries {
+		if untranslated && len(strings.TrimSpace(val)) > 0 {
+			continue
+		}
+		if len(search) > 0 && !strings.Contains(strings.ToLower(key), search) && !strings.Contains(strings.ToLower(val), search) {
+			continue
+		}
+		result.Entries = append(result.Entries, translationEntry{Key: key, Value: val})
+	}
+	sort.Slice(result.Entries, func(i, j int) bool { return result.Entries[i].Key < result.Entries[j].Key })
+	tools.RespondWithJSON(c, http.StatusOK, &result)
+}
+
+// Downloads the translation file of a locale, it can be imported again as is
+func exportTranslationsHandler(c *gin.Context) {
+	locale := c.Param("locale")
+	if !validateLocale(locale) {
+		respondLocaleError(ErrLocaleNotFound, c)
+		return
+	}
+	entries, err := ReadTSFile(locale)
+	if err != nil {
+		tools.RespondError(err, http.StatusInternalServerError, c)
+		return
+	}
+	data, err := json.MarshalIndent(&entries, "", "  ")
+	if err != nil {
+		tools.RespondError(err, http.StatusInternalServerError, c)
+		return
+	}
+	c.Header("Content-Disposition", "attachment; filename="+locale+".json")
+	c.Data(http.StatusOK, "application/json", data)
+}
+
+// Imports a JSON object of keys and values, "mode=replace" drops keys that are not part of it, the default merges
+func importTranslationsHandler(c *gin.Context) {
+	entries := map[string]string{}
+	if err := c.ShouldBindJSON(&entries); err != nil {
+		tools.RespondError(err, http.StatusBadRequest, c)
+		return
+	}
+	mode := c.DefaultQuery("mode", "merge")
+	if mode != "merge" && mode != "replace" {
+		tools.RespondError(errors.New("err_import_mode"), http.StatusBadRequest, c)
+		return
+	}
+	locale := c.Param("locale")
+	if _, err := ImportTranslations(locale, entries, mode == "replace"); err != nil {
+		respondLocaleError(err, c)
+		return
+	}
+	respondLocaleStats(locale, c)
+}
+
+func setTranslationHandler(c *gin.Context) {
+	req := translationRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		tools.RespondError(err, http.StatusBadRequest, c)
+		return
+	}
+	entry := translationEntry{Key: c.Param("key"), Value: req.Value}
+	if err := SetTranslation(c.Param("locale"), entry.Key, entry.Value); err != nil {
+		respondLocaleError(err, c)
+		return
+	}
+	tools.RespondWithJSON(c, http.StatusOK, &entry)
+}
+
+func deleteTranslationHandler(c *gin.Context) {
+	if err := DeleteTranslation(c.Param("locale"), c.Param("key")); err != nil {
+		respondLocaleError(err, c)
+		return
+	}
+	tools.RespondWithJSON(c, http.StatusOK, "translation_removed")
+}
+
+func respondLocaleStats(locale string, c *gin.Context) {
+	stats, err := TranslationStatsOf(locale)
+	if err != nil {
+		respondLocaleError(err, c)
+		return
+	}
+	tools.RespondWithJSON(c, http.StatusOK, &stats)
+}
+
+func respondLocaleError(err error, c *gin.Context) {
+	switch err {
+	case ErrLocaleNotFound:
+		tools.RespondError(err, http.StatusNotFound, c)
+	case ErrNotFound:
+		tools.RespondError(errors.New("not_found"), http.StatusNotFound, c)
+	case ErrLocaleExists, ErrLocaleDefault:
+		tools.RespondError(err, http.StatusConflict, c)
+	case ErrLocaleInvalid:
+		tools.RespondError(err, http.StatusBadRequest, c)
+	default:
+		if IsUnavailable(err) {
+			tools.RespondError(errors.New("cache_unavailable"), http.StatusServiceUnavailable, c)
+			return
+		}
+		tools.RespondError(err, http.StatusInternalServerError, c)
+	}
+}
@@ -0,0 +1,34 @@
+//go:build integration
+
+package cachebundle
+
+import (
+	"os"
+	"testing"
+)
+
+// Runs the conformance suite against real servers: go test -tags integration ./src/bundles/cachebundle/
+// Addresses default to localhost and can be set with CACHE_TEST_REDIS and CACHE_TEST_AEROSPIKE.
+
+func TestRedisConformance(t *testing.T) {
+	engine := &redisEngine{}
+	if err := engine.Connect(EngineConfig{Address: testAddress("CACHE_TEST_REDIS"), Workspace: "conformance"}); err != nil {
+		t.Fatal(err)
+	}
+	runConformance(t, engine)
+}
+
+func TestAerospikeConformance(t *testing.T) {
+	engine := &aerospikeEngine{}
+	if err := engine.Connect(EngineConfig{Address: testAddress("CACHE_TEST_AEROSPIKE"), Workspace: "test"}); err != nil {
+		t.Fatal(err)
+	}
+	runConformance(t, engine)
+}
+
+func testAddress(env string) string {
+	if address := os.Getenv(env); len(address) > 0 {
+		return address
+	}
+	return "localhost"
+}
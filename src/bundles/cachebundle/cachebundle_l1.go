@@ -0,0 +1,204 @@
+package cachebundle
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Cache bus topic, writes on one node drop the L1 entries of all others
+const TOPIC_L1_INVALIDATE = "l1_invalidate"
+
+// Policies used when L1MaxEntries is set without L1Policies, translations rarely change while sessions have to be revoked quickly
+var DefaultL1Policies = map[string]time.Duration{
+	"translation_*":   10 * time.Minute,
+	"user_session":    5 * time.Second,
+	"service_session": 5 * time.Second,
+}
+
+// Bounded in-process tier in front of remote engines. Only names with a policy are kept, values are stored encoded
+// and expire after the policy TTL. Engines without pub/sub can't tell other nodes, their entries are stale for at most the TTL.
+type l1Cache struct {
+	lock       sync.Mutex
+	entries    map[string]*list.Element
+	lru        *list.List
+	maxEntries int
+	policies   map[string]time.Duration
+	prefixes   map[string]time.Duration
+	// Bumped on every invalidation, loads that started before one aren't stored
+	generation atomic.Uint64
+}
+
+type l1Entry struct {
+	id      string
+	value   []byte
+	expires time.Time
+}
+
+type l1Invalidation struct {
+	Node   string `json:"node"`
+	Name   string `json:"name"`
+	Key    string `json:"key"`
+	Prefix bool   `json:"prefix"`
+}
+
+var l1 *l1Cache
+
+func newL1Cache(maxEntries int, policies map[string]time.Duration) *l1Cache {
+	cache := &l1Cache{entries: map[string]*list.Element{}, lru: list.New(), maxEntries: maxEntries, policies: map[string]time.Duration{}, prefixes: map[string]time.Duration{}}
+	if policies == nil {
+		policies = DefaultL1Policies
+	}
+	for name, ttl := range policies {
+		cache.setPolicy(name, ttl)
+	}
+	return cache
+}
+
+// Sets up the L1 tier from the engine config, in-process engines don't get one
+func initL1(config EngineConfig) {
+	if config.L1MaxEntries <= 0 || activeEngineName == ENGINE_MEMORY {
+		return
+	}
+	l1 = newL1Cache(config.L1MaxEntries, config.L1Policies)
+	Subscribe(TOPIC_L1_INVALIDATE, func(msg l1Invalidation) {
+		if msg.Node != NodeID() {
+			l1.invalidate(msg.Name, msg.Key, msg.Prefix)
+		}
+	})
+}
+
+// Keeps values of name in the L1 tier for ttl, a trailing * matches every name with that prefix. A ttl of 0 removes the policy.
+// Has no effect unless L1MaxEntries is configured.
+func SetL1Policy(name string, ttl time.Duration) {
+	if l1 != nil {
+		l1.setPolicy(name, ttl)
+	}
+}
+
+func (c *l1Cache) setPolicy(name string, ttl time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	policies := c.policies
+	if strings.HasSuffix(name, "*") {
+		policies = c.prefixes
+		name = strings.TrimSuffix(name, "*")
+	}
+	if ttl > 0 {
+		policies[name] = ttl
+	} else {
+		delete(policies, name)
+	}
+}
+
+// TTL of name in L1, 0 if it isn't kept there. Has to be called with the lock held.
+func (c *l1Cache) policyLocked(name string) time.Duration {
+	if ttl, ok := c.policies[name]; ok {
+		return ttl
+	}
+	var best string
+	var ttl time.Duration
+	for prefix, prefixTTL := range c.prefixes {
+		if strings.HasPrefix(name, prefix) && len(prefix) >= len(best) {
+			best, ttl = prefix, prefixTTL
+		}
+	}
+	return ttl
+}
+
+func (c *l1Cache) get(name string, key string) ([]byte, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	el, ok := c.entries[name+"\x00"+key]
+	if !ok {
+		return nil, false
+	}
+	entry := el.Value.(*l1Entry)
+	if time.Now().After(entry.expires) {
+		c.remove(el)
+		return nil, false
+	}
+	c.lru.MoveToFront(el)
+	return entry.value, true
+}
+
+// Stores a value read from the engine, unless the name has no policy or an invalidation happened since generation was read
+func (c *l1Cache) put(name string, key string, value []byte, generation uint64) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	ttl := c.policyLocked(name)
+	if ttl <= 0 || c.generation.Load() != generation {
+		return
+	}
+	entry := &l1Entry{id: name + "\x00" + key, value: value, expires: time.Now().Add(ttl)}
+	if el, ok := c.entries[entry.id]; ok {
+		el.Value = entry
+		c.lru.MoveToFront(el)
+		return
+	}
+	c.entries[entry.id] = c.lru.PushFront(entry)
+	for c.lru.Len() > c.maxEntries {
+		c.remove(c.lru.Back())
+	}
+}
+
+// Drops name/key, or every key of name starting with key if prefix is set
+func (c *l1Cache) invalidate(name string, key string, prefix bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.generation.Add(1)
+	if !prefix {
+		if el, ok := c.entries[name+"\x00"+key]; ok {
+			c.remove(el)
+		}
+		return
+	}
+	start := name + "\x00" + key
+	for id, el := range c.entries {
+		if strings.HasPrefix(id, start) {
+			c.remove(el)
+		}
+	}
+}
+
+func (c *l1Cache) remove(el *list.Element) {
+	c.lru.Remove(el)
+	delete(c.entries, el.Value.(*l1Entry).id)
+}
+
+func (c *l1Cache) keeps(name string) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.policyLocked(name) > 0
+}
+
+// Drops the entry on this node and tells the other ones, called after every write to the engine
+func invalidateL1(name string, key string, prefix bool) {
+	if l1 == nil || !l1.keeps(name) {
+		return
+	}
+	l1.invalidate(name, key, prefix)
+	Publish(TOPIC_L1_INVALIDATE, l1Invalidation{Node: NodeID(), Name: name, Key: key, Prefix: prefix})
+}
+
+// Reads an encoded value through the L1 tier, hits are served even while the engine is unavailable
+func getRaw(name string, key string) ([]byte, error) {
+	if l1 == nil {
+		return guarded(func() ([]byte, error) {
+			return activeEngine.Get(name, key)
+		})
+	}
+	if data, ok := l1.get(name, key); ok {
+		return data, nil
+	}
+	generation := l1.generation.Load()
+	data, err := guarded(func() ([]byte, error) {
+		return activeEngine.Get(name, key)
+	})
+	if err == nil {
+		l1.put(name, key, data, generation)
+	}
+	return data, err
+}
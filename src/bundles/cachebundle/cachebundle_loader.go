@@ -0,0 +1,102 @@
+package cachebundle
+
+import (
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/sc-js/pour"
+	"golang.org/x/sync/singleflight"
+)
+
+type LoadOptions struct {
+	// Once the TTL has passed, the old value is still served for this long while it is reloaded in the background
+	StaleWhileRevalidate time.Duration
+	// If set, a loader returning ErrNotFound is cached for this long, so missing records don't hit the database every time
+	NegativeTTL time.Duration
+	// Randomizes TTLs by up to this fraction (0.1 = ±10%), so entries written together don't expire together
+	Jitter float64
+}
+
+var DefaultLoadOptions = LoadOptions{Jitter: 0.1}
+
+var loadGroup singleflight.Group
+
+// What GetOrLoad stores, Found is false for cached misses
+type loadEnvelope[T any] struct {
+	Value      T     `json:"v"`
+	Found      bool  `json:"f"`
+	FreshUntil int64 `json:"u"`
+}
+
+// Read-through helper: returns the cached value or calls loader, stores its result for ttl and returns it.
+// Concurrent misses for the same key only call loader once.
+func GetOrLoad[T any](name string, key string, ttl time.Duration, loader func() (T, error)) (T, error) {
+	return GetOrLoadWithOptions(name, key, ttl, DefaultLoadOptions, loader)
+}
+
+func GetOrLoadWithOptions[T any](name string, key string, ttl time.Duration, opts LoadOptions, loader func() (T, error)) (T, error) {
+	envelope, err := Get[loadEnvelope[T]](name, key)
+	if err == nil {
+		now := time.Now().UnixNano()
+		if now < envelope.FreshUntil {
+			return envelope.result()
+		}
+		if opts.StaleWhileRevalidate > 0 && envelope.Found {
+			go func() {
+				if _, err := load(name, key, ttl, opts, loader); err != nil && !IsNotFound(err) {
+					pour.LogColor(false, pour.ColorYellow, "Background reload of", name+key, "failed:", err)
+				}
+			}()
+			return envelope.Value, nil
+		}
+	}
+	return load(name, key, ttl, opts, loader)
+}
+
+func load[T any](name string, key string, ttl time.Duration, opts LoadOptions, loader func() (T, error)) (T, error) {
+	res, err, _ := loadGroup.Do(name+"\x00"+key, func() (interface{}, error) {
+		val, err := loader()
+		switch {
+		case err == nil:
+			fresh := jitter(ttl, opts.Jitter)
+			envelope := loadEnvelope[T]{Value: val, Found: true, FreshUntil: time.Now().Add(fresh).UnixNano()}
+			if err := PutExpire(name, key, envelope, fresh+opts.StaleWhileRevalidate); err != nil && !IsUnavailable(err) {
+				pour.LogColor(false, pour.ColorYellow, "Could not cache", name+key+":", err)
+			}
+		case errors.Is(err, ErrNotFound) && opts.NegativeTTL > 0:
+			negative := jitter(opts.NegativeTTL, opts.Jitter)
+			envelope := loadEnvelope[T]{FreshUntil: time.Now().Add(negative).UnixNano()}
+			PutExpire(name, key, envelope, negative)
+		}
+		return val, err
+	})
+	if res == nil {
+		return *new(T), err
+	}
+	return res.(T), err
+}
+
+func (e loadEnvelope[T]) result() (T, error) {
+	if !e.Found {
+		return e.Value, ErrNotFound
+	}
+	return e.Value, nil
+}
+
+// Removes a value cached by GetOrLoad, the next call loads it again
+func Invalidate(name string, key string) error {
+	loadGroup.Forget(name + "\x00" + key)
+	return Del(name, key)
+}
+
+func jitter(ttl time.Duration, fraction float64) time.Duration {
+	if fraction <= 0 || ttl <= 0 {
+		return ttl
+	}
+	delta := time.Duration(float64(ttl) * fraction * (rand.Float64()*2 - 1))
+	if ttl+delta < time.Second {
+		return ttl
+	}
+	return ttl + delta
+}
@@ -1,113 +1,162 @@
 package cachebundle
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"os"
 	"strings"
 	"time"
 
-	"github.com/aerospike/aerospike-client-go"
-	"github.com/go-redis/redis"
 	"github.com/sc-js/backend_core/src/tools"
 	"github.com/sc-js/pour"
 )
 
 const translation_path = "./translations/"
 
-// Gets a translation for a key, given a
+// Catalog of the former localization bundle, merged into the translation files on startup
+const legacy_locales_path = "./locales.json"
+
+// Gets a translation for a key, unknown keys are registered for the locale so they can be translated later.
+// Keys that are missing or empty in the locale are looked up along its fallback chain (de_CH -> de_DE -> en_EN).
 func GetTS(locale string, key string) (string, error) {
+	var firstErr error
+	for i, loc := range tools.LocaleChain(locale) {
+		trans, err := Get[string]("translation_"+loc+"_", key)
+		if err == errNf && i == 0 && validateLocale(loc) {
+			WriteNewTSEntry(loc, key)
+		}
+		if err == nil && len(trans) > 0 {
+			return trans, nil
+		}
+		if i == 0 {
+			firstErr = err
+		}
+		if IsUnavailable(err) {
+			return "", err
+		}
+	}
+	return "", firstErr
+}
 
-	switch connectedModule {
-	case AeroSpike:
-		pour.LogPanicKill(1, errors.New("not_implemented"))
-	case Redis:
-		err := redisClient.Get("translation_" + locale + "_" + key).Err()
-		if err == redis.Nil {
-			WriteNewTSEntry(locale, key)
-			return "", errNf
+// Gets the translations of many keys with one round-trip per locale of the fallback chain if the engine supports batch reads.
+// Unknown keys are registered like in GetTS and are missing from the result.
+func GetTSBatch(locale string, keys []string) map[string]string {
+	result := make(map[string]string, len(keys))
+	for i, loc := range tools.LocaleChain(locale) {
+		found, err := getMany[string]("translation_"+loc+"_", keys)
+		if err != nil {
+			pour.LogColor(false, pour.ColorRed, "Error reading translations:", err)
+			return result
+		}
+		missing := []string{}
+		for _, key := range keys {
+			trans, ok := found[key]
+			if !ok && i == 0 && validateLocale(loc) {
+				WriteNewTSEntry(loc, key)
+			}
+			if len(trans) > 0 {
+				result[key] = trans
+			} else {
+				missing = append(missing, key)
+			}
+		}
+		if len(missing) == 0 {
+			break
+		}
+		keys = missing
+	}
+	return result
+}
+
+// Returns every cached translation of a locale, the engine has to support scans
+func GetTSLocale(locale string) (map[string]string, error) {
+	scanner, ok := activeEngine.(ScanEngine)
+	if !ok {
+		return nil, errors.New("cache engine does not support scans")
+	}
+	raw, err := guarded(func() (map[string][]byte, error) {
+		return scanner.Scan("translation_" + locale + "_")
+	})
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]string, len(raw))
+	c := getCodec()
+	for key, data := range raw {
+		var val string
+		if err := c.Unmarshal(data, &val); err == nil {
+			result[key] = val
 		}
-		return redisClient.Get("translation_" + locale + "_" + key).Val(), nil
 	}
-	pour.LogPanicKill(1, errors.New("not_implemented"))
-	return "", errors.New("not_implemented")
+	return result, nil
 }
 
 // Append a new translation entry (specified by key) into a specific locale data structure (Cache and file).
+// Keys that already exist in the file keep their value, it's only missing from the cache then.
 func WriteNewTSEntry(locale string, key string) {
 
 	go func() {
-		cacheFileLock.Lock()
-		defer cacheFileLock.Unlock()
-		outPath := translation_path
-		if _, err := os.Stat(outPath); os.IsNotExist(err) {
-			os.Mkdir(outPath, 0755)
-		}
-		localeFileName := locale + ".json"
-		var fileData []byte
-		dat, err := os.ReadFile(outPath + localeFileName)
-		if err == nil {
-			structure := map[string]string{}
-			json.Unmarshal(dat, &structure)
-			structure[key] = ""
-			fileData, err = json.Marshal(&structure)
-			if err != nil {
-				pour.LogColor(false, pour.ColorRed, "error appending translation data to cache", err)
-				return
-			}
-			f, _ := os.OpenFile(outPath+localeFileName, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
-			defer f.Close()
-			f.Write(fileData)
-		} else {
-			structure := map[string]string{}
-			structure[key] = ""
-			b, err := json.Marshal(&structure)
-			if err != nil {
-				pour.LogColor(false, pour.ColorRed, "error marshalling new transmap", err)
-				return
+		val := ""
+		err := updateTSFile(locale, func(entries map[string]string) bool {
+			if existing, ok := entries[key]; ok {
+				val = existing
+				return false
 			}
-			os.WriteFile(outPath+localeFileName, b, 0644)
+			entries[key] = ""
+			return true
+		})
+		if err != nil {
+			pour.LogColor(false, pour.ColorRed, "error appending translation entry for", locale, err)
+			return
 		}
-		PutTS(key, locale, "")
+		PutTS(key, locale, val)
 	}()
 }
 
+// Removes every cached translation of a locale, the translation files are not touched
+func DelTSLocale(locale string) (int, error) {
+	return DelPrefix("translation_"+locale+"_", "")
+}
+
 // Translations
 func PutTS(key string, locale string, val string) error {
-
-	switch connectedModule {
-	case AeroSpike:
-		internalKey, err := aerospike.NewKey(workspace, "translation_"+locale+"_", key)
-		if err != nil {
-			return err
-		}
-		bin := aerospike.BinMap{"a": val}
-		return aeroClient.Put(nil, internalKey, bin)
-	case Redis:
-		return redisClient.Set("translation_"+locale+"_"+key, val, 0).Err()
-	}
-	return nil
+	return Put("translation_"+locale+"_", key, val)
 }
 
-// Automatically calls readTSJson in a given time period, to refresh translation data, should it be changed during runtime
+// Automatically calls readTSJson in a given time period, to refresh translation data, should it be changed during runtime.
+// Only the leader node refreshes, the others read the shared cache.
 func ReadTSJson(path string, autoRefresh bool) {
+	subscribeLocales()
 	insertDefaultValues()
+	if err := migrateLegacyLocales(legacy_locales_path); err != nil {
+		pour.LogColor(false, pour.ColorRed, "Error migrating", legacy_locales_path+":", err)
+	}
 	if autoRefresh {
-		go func() {
+		go RunAsLeader("translation_refresh", time.Minute, func(ctx context.Context) {
 			for {
-				time.Sleep(time.Second * 60)
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(time.Second * 60):
+				}
 				err := readTSJson(path)
 				if err != nil {
 					pour.LogColor(false, pour.ColorRed, "Error reading TS Json:", err)
 					return
 				}
 			}
-		}()
+		})
 	}
 }
 
+// Re-reads the translation files into the cache, for scheduling the refresh outside of ReadTSJson
+func RefreshTranslations() error {
+	return readTSJson(translation_path)
+}
+
 // Reads all locale files in a specific path (normally, this should be the translations directory).
-// Removes all Pre- and suffixes from the name to determine the corresponding locale.
+// Removes all Pre- and suffixes from the name to determine the corresponding locale, files of locales added on other nodes are picked up as well.
 // Parses the locales and caches them for faster access.
 func readTSJson(path string) error {
 
@@ -122,7 +171,7 @@ func readTSJson(path string) error {
 		if len(ext) == 1 {
 			ext = strings.Split(split[0], "\\")
 		}
-		if validateLocale(ext[1]) {
+		if registerLocale(ext[1]) {
 			var m map[string]string
 			element = strings.Replace(element, "\\", "/", -1)
 			dat, err := os.ReadFile(element)
@@ -149,13 +198,20 @@ func readTSJson(path string) error {
 
 // Check whether a given locale is valid, e.G. en_EN, de_DE etc.
 func validateLocale(loc string) bool {
+	localesLock.RLock()
+	defer localesLock.RUnlock()
 	return valid_locales[loc]
 }
 
 // Insert the default locale values into the cache.
 func insertDefaultValues() {
 	for key, value := range default_locales {
+		localesLock.Lock()
 		valid_locales[key] = true
+		localesLock.Unlock()
+		if err := upgradeDefaults(key); err != nil {
+			pour.LogColor(true, pour.ColorRed, err)
+		}
 		err := PutTSMap(value, key, true)
 		if err != nil {
 			pour.LogColor(true, pour.ColorRed, err)
@@ -163,53 +219,47 @@ func insertDefaultValues() {
 	}
 }
 
+// Replaces translations of the file that still hold a former default (replaced_defaults) with the current one
+func upgradeDefaults(locale string) error {
+	former, ok := replaced_defaults[locale]
+	if !ok {
+		return nil
+	}
+	return updateTSFile(locale, func(entries map[string]string) bool {
+		changed := false
+		for key, val := range former {
+			if existing, ok := entries[key]; ok && existing == val {
+				entries[key] = default_locales[locale][key]
+				changed = true
+			}
+		}
+		return changed
+	})
+}
+
 // Auto-creates the translations folder and creates the default translation files which are hardcoded for the moment.
-// Translations are cached for faster acccess.
+// Values already in the file win over the ones in m, the merged translations are cached for faster acccess.
 func PutTSMap(m map[string]string, locale string, verbose bool) error {
 
-	if _, err := os.Stat(translation_path); os.IsNotExist(err) {
-		err := os.Mkdir(translation_path, 0777)
-		if err != nil {
-			pour.LogColor(false, pour.ColorRed, "Error creating dir:", err)
-			return err
-		}
-	}
 	if strings.Contains(locale, "/") {
 		spl := strings.Split(locale, "/")
 		locale = spl[len(spl)-1]
 	}
-	var j []byte
-	var existing map[string]string
-	if tools.Exists(translation_path + locale + ".json") {
-		b, err := os.ReadFile(translation_path + locale + ".json")
-		if err != nil {
-			pour.LogColor(false, pour.ColorRed, "Can't read locale file for:", locale)
-			return err
-		}
-		err = json.Unmarshal(b, &existing)
-		if err != nil {
-			pour.LogColor(false, pour.ColorRed, "Can't read locale file for:", locale)
-			return err
-		}
-	} else {
-		ff, err := os.Create(translation_path + locale + ".json")
-		if err != nil {
-			return err
-		}
-		err = ff.Close()
-		if err != nil {
-			return err
+	err := updateTSFile(locale, func(entries map[string]string) bool {
+		changed := false
+		for key, val := range m {
+			if _, ok := entries[key]; !ok {
+				entries[key] = val
+				changed = true
+			}
 		}
-	}
-
-	if len(existing) > 0 {
-		for key, val := range existing {
+		for key, val := range entries {
 			m[key] = val
 		}
-	}
-
-	j, err := json.Marshal(&m)
+		return changed
+	})
 	if err != nil {
+		pour.LogColor(false, pour.ColorRed, "Can't write locale file for:", locale)
 		return err
 	}
 
@@ -217,32 +267,26 @@ func PutTSMap(m map[string]string, locale string, verbose bool) error {
 		pour.LogColor(false, pour.ColorYellow, "Writing/Caching", len(m), "translations for", locale)
 	}
 
-	f, err := os.OpenFile(translation_path+locale+".json", os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0755)
-	if err != nil {
-		return err
+	// The refresh task re-puts every translation each minute, only changed ones are written,
+	// so unchanged runs don't invalidate L1 or publish anything
+	name := "translation_" + locale + "_"
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
 	}
-	_, err = f.Write(j)
+	cached, err := MGet[string](name, keys)
 	if err != nil {
-		return err
+		cached = map[string]string{}
 	}
-
+	changed := map[string]string{}
 	for key, element := range m {
-		switch connectedModule {
-		case AeroSpike:
-			internalKey, err := aerospike.NewKey(workspace, "translation_"+locale+"_", key)
-			if err != nil {
-				pour.LogColor(true, pour.ColorRed, err)
-			}
-			bin := aerospike.BinMap{"a": element}
-			err = aeroClient.Put(nil, internalKey, bin)
-			if err != nil {
-				pour.LogColor(true, pour.ColorRed, err)
-			}
-		case Redis:
-			err := redisClient.Set("translation_"+locale+"_"+key, element, 0).Err()
-			if err != nil {
-				pour.LogColor(true, pour.ColorRed, err)
-			}
+		if current, ok := cached[key]; !ok || current != element {
+			changed[key] = element
+		}
+	}
+	if len(changed) > 0 {
+		if err := MPut(name, changed, 0); err != nil {
+			pour.LogColor(true, pour.ColorRed, err)
 		}
 	}
 
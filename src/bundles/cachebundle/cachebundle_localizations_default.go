@@ -7,11 +7,41 @@ var default_locales map[string]map[string]string = map[string]map[string]string{
 	"de_DE": default_de,
 }
 
+// Former default values, translation files still holding them are updated to the current default on startup.
+// Translations that were changed by hand are kept.
+var replaced_defaults map[string]map[string]string = map[string]map[string]string{
+	// text/template placeholders, mails use the ICU message format of the catalog now
+	"en_EN": {
+		"mail_magic_link_body": "Hello {{.Username}},\n\nuse the following link to log in. It is valid for {{.Minutes}} minutes and can only be used once:\n\n{{.Link}}\n\nIf you didn't request this, you can ignore this mail.",
+		"mail_login_code_body": "Hello {{.Username}},\n\nyour login code is {{.Code}}. It is valid for {{.Minutes}} minutes and can only be used once.\n\nIf you didn't request this, you can ignore this mail.",
+		"mail_invitation_body": "Hello,\n\nyou have been invited to create an account. Your invitation code is {{.Code}}.\n{{if .Link}}\nRegister here: {{.Link}}\n{{end}}",
+	},
+	"de_DE": {
+		"mail_magic_link_body": "Hallo {{.Username}},\n\nnutze den folgenden Link, um dich anzumelden. Er ist {{.Minutes}} Minuten gültig und kann nur einmal verwendet werden:\n\n{{.Link}}\n\nFalls du das nicht angefordert hast, kannst du diese Mail ignorieren.",
+		"mail_login_code_body": "Hallo {{.Username}},\n\ndein Login-Code lautet {{.Code}}. Er ist {{.Minutes}} Minuten gültig und kann nur einmal verwendet werden.\n\nFalls du das nicht angefordert hast, kannst du diese Mail ignorieren.",
+		"mail_invitation_body": "Hallo,\n\ndu wurdest eingeladen, einen Account zu erstellen. Dein Einladungscode lautet {{.Code}}.\n{{if .Link}}\nHier registrieren: {{.Link}}\n{{end}}",
+	},
+}
+
 var default_en map[string]string = map[string]string{
 	"bad_login":                        "Bad login",
 	"not_found":                        "Not found",
 	"auth_error":                       "Authentification error",
 	"not_authorized":                   "Not authorized",
+	"cache_unavailable":                "Service temporarily unavailable, please try again",
+	"err_job_duplicate":                "A job with this key is already queued",
+	"err_job_running":                  "The job is currently running",
+	"err_job_not_retryable":            "Only pending or failed jobs can be retried",
+	"err_job_invalid_status":           "Only done, dead or pending jobs can be purged",
+	"err_task_running":                 "The task is already running",
+	"task_triggered":                   "The task was started",
+	"err_locale_invalid":               "Invalid locale, expected a name like en_EN",
+	"err_locale_exists":                "The locale already exists",
+	"err_locale_default":               "Default locales can't be removed",
+	"err_locale_not_found":             "Unknown locale",
+	"err_import_mode":                  "Invalid import mode, expected merge or replace",
+	"locale_removed":                   "The locale was removed",
+	"translation_removed":              "The translation was removed",
 	"internal_error":                   "Internal error",
 	"male":                             "Male",
 	"female":                           "Female",
@@ -33,6 +63,27 @@ var default_en map[string]string = map[string]string{
 	"message":                          "Message",
 	"successfully_delivered":           "sucessfully delivered",
 	"error_delivered":                  "could not be delivered",
+	"account_disabled":                 "This account has been disabled",
+	"err_self_action":                  "You can't perform this action on your own account",
+	"only_user":                        "Only available for user accounts",
+	"csrf_failed":                      "Invalid or missing CSRF token",
+	"insufficient_scope":               "The token lacks the scope required for this request",
+	"err_name_empty":                   "Name can't be empty",
+	"err_impersonation_not_allowed":    "This account can't be impersonated",
+	"err_not_impersonating":            "This session is not an impersonation",
+	"err_email_empty":                  "Email can't be empty",
+	"err_magic_link_disabled":          "Magic links are not enabled",
+	"err_passwordless_invalid":         "The code or link is invalid or has expired",
+	"passwordless_sent":                "If the email is registered, a login mail was sent",
+	"mail_magic_link_subject":          "Your login link",
+	"mail_magic_link_body":             "Hello {Username},\n\nuse the following link to log in. It is valid for {Minutes, plural, one {# minute} other {# minutes}} and can only be used once:\n\n{Link}\n\nIf you didn't request this, you can ignore this mail.",
+	"mail_login_code_subject":          "Your login code",
+	"mail_login_code_body":             "Hello {Username},\n\nyour login code is {Code}. It is valid for {Minutes, plural, one {# minute} other {# minutes}} and can only be used once.\n\nIf you didn't request this, you can ignore this mail.",
+	"err_invite_required":              "An invitation is required to register",
+	"err_invite_invalid":               "The invitation is invalid, expired or already used",
+	"err_invalid_duration":             "Invalid duration",
+	"mail_invitation_subject":          "You have been invited",
+	"mail_invitation_body":             "Hello,\n\nyou have been invited to create an account. Your invitation code is {Code}.\n{HasLink, select, true {\nRegister here: {Link}\n} other {}}",
 }
 
 var default_de map[string]string = map[string]string{
@@ -40,6 +91,20 @@ var default_de map[string]string = map[string]string{
 	"not_found":                        "Nicht gefunden",
 	"auth_error":                       "Authentifizierungsfehler",
 	"not_authorized":                   "Nicht autorisiert",
+	"cache_unavailable":                "Dienst vorübergehend nicht verfügbar, bitte versuche es erneut",
+	"err_job_duplicate":                "Ein Job mit diesem Schlüssel ist bereits eingereiht",
+	"err_job_running":                  "Der Job wird gerade ausgeführt",
+	"err_job_not_retryable":            "Nur wartende oder fehlgeschlagene Jobs können wiederholt werden",
+	"err_job_invalid_status":           "Nur erledigte, tote oder wartende Jobs können gelöscht werden",
+	"err_task_running":                 "Die Aufgabe läuft bereits",
+	"task_triggered":                   "Die Aufgabe wurde gestartet",
+	"err_locale_invalid":               "Ungültige Sprache, erwartet wird ein Name wie de_DE",
+	"err_locale_exists":                "Die Sprache existiert bereits",
+	"err_locale_default":               "Standardsprachen können nicht entfernt werden",
+	"err_locale_not_found":             "Unbekannte Sprache",
+	"err_import_mode":                  "Ungültiger Importmodus, erwartet wird merge oder replace",
+	"locale_removed":                   "Die Sprache wurde entfernt",
+	"translation_removed":              "Die Übersetzung wurde entfernt",
 	"internal_error":                   "Interner Fehler",
 	"male":                             "Männlich",
 	"female":                           "Weiblich",
@@ -61,4 +126,25 @@ var default_de map[string]string = map[string]string{
 	"message":                          "Nachricht",
 	"successfully_delivered":           "wurde erfolgreich verschickt",
 	"error_delivered":                  "konnte nicht verschickt werden",
+	"account_disabled":                 "Dieser Account wurde deaktiviert",
+	"err_self_action":                  "Diese Aktion kannst du nicht auf deinen eigenen Account anwenden",
+	"only_user":                        "Nur für Benutzeraccounts verfügbar",
+	"csrf_failed":                      "CSRF-Token ungültig oder nicht vorhanden",
+	"insufficient_scope":               "Dem Token fehlt die für diese Anfrage nötige Berechtigung",
+	"err_name_empty":                   "Name kann nicht leer sein",
+	"err_impersonation_not_allowed":    "Dieser Account kann nicht übernommen werden",
+	"err_not_impersonating":            "Diese Sitzung ist keine Account-Übernahme",
+	"err_email_empty":                  "E-Mail darf nicht leer sein",
+	"err_magic_link_disabled":          "Login-Links sind nicht aktiviert",
+	"err_passwordless_invalid":         "Der Code oder Link ist ungültig oder abgelaufen",
+	"passwordless_sent":                "Falls die E-Mail registriert ist, wurde eine Login-Mail versendet",
+	"mail_magic_link_subject":          "Dein Login-Link",
+	"mail_magic_link_body":             "Hallo {Username},\n\nnutze den folgenden Link, um dich anzumelden. Er ist {Minutes, plural, one {# Minute} other {# Minuten}} gültig und kann nur einmal verwendet werden:\n\n{Link}\n\nFalls du das nicht angefordert hast, kannst du diese Mail ignorieren.",
+	"mail_login_code_subject":          "Dein Login-Code",
+	"mail_login_code_body":             "Hallo {Username},\n\ndein Login-Code lautet {Code}. Er ist {Minutes, plural, one {# Minute} other {# Minuten}} gültig und kann nur einmal verwendet werden.\n\nFalls du das nicht angefordert hast, kannst du diese Mail ignorieren.",
+	"err_invite_required":              "Für die Registrierung ist eine Einladung erforderlich",
+	"err_invite_invalid":               "Die Einladung ist ungültig, abgelaufen oder bereits verwendet",
+	"err_invalid_duration":             "Ungültige Dauer",
+	"mail_invitation_subject":          "Du wurdest eingeladen",
+	"mail_invitation_body":             "Hallo,\n\ndu wurdest eingeladen, einen Account zu erstellen. Dein Einladungscode lautet {Code}.\n{HasLink, select, true {\nHier registrieren: {Link}\n} other {}}",
 }
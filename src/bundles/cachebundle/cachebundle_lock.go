@@ -0,0 +1,205 @@
+package cachebundle
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/sc-js/pour"
+)
+
+var (
+	// Returned by Lock if another holder owns the lock
+	ErrLockHeld = errors.New("lock is held by another owner")
+	// Returned by Unlock if the lease expired or was taken over
+	ErrLockLost = errors.New("lock was lost")
+
+	errNoLockSupport = errors.New("cache engine does not support locks")
+)
+
+const lockMinTTL = time.Second
+
+// A held distributed lock. It renews itself every ttl/3 until Unlock is called,
+// if a renewal fails the lease is lost and Lost() is closed.
+type Lease struct {
+	name   string
+	token  []byte
+	fence  int64
+	ttl    time.Duration
+	engine LockEngine
+
+	lost     chan struct{}
+	done     chan struct{}
+	lostOnce sync.Once
+	doneOnce sync.Once
+}
+
+// Tries to acquire the lock called name once, returns ErrLockHeld if another owner holds it.
+// The lock expires after ttl unless it's renewed, which the returned lease does automatically.
+func Lock(name string, ttl time.Duration) (*Lease, error) {
+	engine, ok := activeEngine.(LockEngine)
+	counter, isCounter := activeEngine.(CounterEngine)
+	if activeEngine == nil {
+		return nil, errNoEngine
+	} else if !ok || !isCounter {
+		return nil, errNoLockSupport
+	}
+	if ttl < lockMinTTL {
+		ttl = lockMinTTL
+	}
+
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return nil, err
+	}
+	token = []byte(hex.EncodeToString(token))
+
+	acquired, err := guarded(func() (bool, error) {
+		return engine.PutIfAbsent("lock", name, token, ttl)
+	})
+	if err != nil {
+		return nil, err
+	} else if !acquired {
+		return nil, ErrLockHeld
+	}
+
+	// Every acquisition gets a higher fence, so writes of a stale holder can be rejected downstream.
+	// Fences never expire and aren't evicted by the memory engine, otherwise they would start over.
+	fence, err := guarded(func() (int64, error) {
+		return counter.IncrBy("lock_fence", name, 1, 0)
+	})
+	if err != nil {
+		engine.CompareAndDel("lock", name, token)
+		return nil, err
+	}
+
+	lease := &Lease{name: name, token: token, fence: fence, ttl: ttl, engine: engine, lost: make(chan struct{}), done: make(chan struct{})}
+	go lease.renew()
+	return lease, nil
+}
+
+// Reports whether anyone currently holds the lock called name
+func IsLocked(name string) (bool, error) {
+	_, err := guarded(func() ([]byte, error) {
+		return activeEngine.Get("lock", name)
+	})
+	if IsNotFound(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Like Lock, but retries until the lock is acquired or ctx is done
+func LockWait(ctx context.Context, name string, ttl time.Duration) (*Lease, error) {
+	wait := 50 * time.Millisecond
+	for {
+		lease, err := Lock(name, ttl)
+		if err != ErrLockHeld {
+			return lease, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+		if wait < time.Second {
+			wait *= 2
+		}
+	}
+}
+
+// Runs task whenever this node holds the lock called name, so only one node in the cluster runs it at a time.
+// The context passed to task is cancelled when the lease is lost, task should return then.
+// If task returns while the lease is held, it is released and task is started again on whichever node gets it next.
+// Blocks forever, call it in a goroutine.
+func RunAsLeader(name string, ttl time.Duration, task func(ctx context.Context)) {
+	retry := ttl / 2
+	if retry < lockMinTTL {
+		retry = lockMinTTL
+	}
+	for {
+		lease, err := Lock(name, ttl)
+		if err == errNoLockSupport {
+			// Engines without lock support are single node, so this node is always the leader
+			pour.LogColor(false, pour.ColorYellow, "Cache engine does not support locks, running", name, "without leader election")
+			task(context.Background())
+			return
+		}
+		if err != nil {
+			if err != ErrLockHeld && !IsUnavailable(err) {
+				pour.LogColor(false, pour.ColorRed, "Error acquiring leader lock", name+":", err)
+			}
+			time.Sleep(retry)
+			continue
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			select {
+			case <-lease.Lost():
+				pour.LogColor(false, pour.ColorYellow, "Lost leadership for", name)
+			case <-ctx.Done():
+			}
+			cancel()
+		}()
+		task(ctx)
+		cancel()
+		lease.Unlock()
+		time.Sleep(retry)
+	}
+}
+
+// Monotonic token of this acquisition, higher fences are newer owners
+func (l *Lease) Fence() int64 {
+	return l.fence
+}
+
+// Closed once the lease can't be renewed anymore, the lock may be held by someone else from then on
+func (l *Lease) Lost() <-chan struct{} {
+	return l.lost
+}
+
+// Stops renewing and releases the lock if it's still ours
+func (l *Lease) Unlock() error {
+	l.doneOnce.Do(func() { close(l.done) })
+	released, err := guarded(func() (bool, error) {
+		return l.engine.CompareAndDel("lock", l.name, l.token)
+	})
+	if err != nil {
+		return err
+	} else if !released {
+		return ErrLockLost
+	}
+	return nil
+}
+
+func (l *Lease) renew() {
+	ticker := time.NewTicker(l.ttl / 3)
+	defer ticker.Stop()
+	renewed := time.Now()
+	for {
+		select {
+		case <-l.done:
+			return
+		case <-ticker.C:
+			ok, err := guarded(func() (bool, error) {
+				return l.engine.CompareAndExpire("lock", l.name, l.token, l.ttl)
+			})
+			if err != nil {
+				// A single failed round-trip is fine, the lock is only gone once the TTL has passed
+				pour.LogColor(false, pour.ColorYellow, "Error renewing lock", l.name+":", err)
+				if time.Since(renewed) < l.ttl {
+					continue
+				}
+			}
+			if !ok {
+				l.lostOnce.Do(func() { close(l.lost) })
+				return
+			}
+			renewed = time.Now()
+		}
+	}
+}
@@ -0,0 +1,418 @@
+package cachebundle
+
+import (
+	"bytes"
+	"container/list"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sc-js/pour"
+)
+
+const MemoryDefaultMaxEntries = 100000
+
+// Names that are never evicted, lock fences have to keep counting up for as long as the process runs
+var memoryPinnedNames = map[string]bool{"lock_fence": true}
+
+// In-process engine for development, tests and single node deployments. Entries expire after their TTL,
+// once MaxEntries is reached the least recently used entry is evicted (except for memoryPinnedNames).
+type memoryEngine struct {
+	lock       sync.Mutex
+	entries    map[string]*list.Element
+	lru        *list.List
+	maxEntries int
+	stop       chan struct{}
+}
+
+type memoryEntry struct {
+	name    string
+	key     string
+	value   []byte
+	expires time.Time
+	// Members of sets and fields of hashes, sets have nil values
+	fields map[string][]byte
+}
+
+func newMemoryEngine() *memoryEngine {
+	return &memoryEngine{entries: map[string]*list.Element{}, lru: list.New(), maxEntries: MemoryDefaultMaxEntries}
+}
+
+func (e *memoryEngine) Connect(config EngineConfig) error {
+	if config.MaxEntries > 0 {
+		e.maxEntries = config.MaxEntries
+	}
+	e.stop = make(chan struct{})
+	go e.janitor()
+	pour.LogColor(false, pour.ColorPurple, "In-memory cache initialized, max entries:", e.maxEntries)
+	return nil
+}
+
+func (e *memoryEngine) Put(name string, key string, val []byte, expiration time.Duration) error {
+	entry := &memoryEntry{name: name, key: key, value: append([]byte(nil), val...)}
+	if expiration > 0 {
+		entry.expires = time.Now().Add(expiration)
+	}
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.putLocked(entry)
+	return nil
+}
+
+// Stores or replaces an entry and evicts the least recently used ones, has to be called with the lock held
+func (e *memoryEngine) putLocked(entry *memoryEntry) {
+	if el, ok := e.entries[memoryKey(entry.name, entry.key)]; ok {
+		el.Value = entry
+		e.lru.MoveToFront(el)
+		return
+	}
+	e.entries[memoryKey(entry.name, entry.key)] = e.lru.PushFront(entry)
+	for e.lru.Len() > e.maxEntries {
+		victim := e.lru.Back()
+		for victim != nil && memoryPinnedNames[victim.Value.(*memoryEntry).name] {
+			victim = victim.Prev()
+		}
+		if victim == nil {
+			return
+		}
+		e.remove(victim)
+	}
+}
+
+func (e *memoryEngine) Get(name string, key string) ([]byte, error) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	el, ok := e.entries[memoryKey(name, key)]
+	if !ok {
+		return nil, errNf
+	}
+	entry := el.Value.(*memoryEntry)
+	if entry.expired(time.Now()) {
+		e.remove(el)
+		return nil, errNf
+	}
+	e.lru.MoveToFront(el)
+	return entry.value, nil
+}
+
+func (e *memoryEngine) MGet(name string, keys []string) (map[string][]byte, error) {
+	result := map[string][]byte{}
+	for _, key := range keys {
+		if data, err := e.Get(name, key); err == nil {
+			result[key] = data
+		}
+	}
+	return result, nil
+}
+
+func (e *memoryEngine) Scan(name string) (map[string][]byte, error) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	now := time.Now()
+	result := map[string][]byte{}
+	for _, el := range e.entries {
+		entry := el.Value.(*memoryEntry)
+		if entry.name == name && !entry.expired(now) {
+			result[entry.key] = entry.value
+		}
+	}
+	return result, nil
+}
+
+func (e *memoryEngine) PutIfAbsent(name string, key string, val []byte, expiration time.Duration) (bool, error) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if el, ok := e.entries[memoryKey(name, key)]; ok && !el.Value.(*memoryEntry).expired(time.Now()) {
+		return false, nil
+	}
+	entry := &memoryEntry{name: name, key: key, value: append([]byte(nil), val...)}
+	if expiration > 0 {
+		entry.expires = time.Now().Add(expiration)
+	}
+	e.putLocked(entry)
+	return true, nil
+}
+
+func (e *memoryEngine) CompareAndExpire(name string, key string, val []byte, expiration time.Duration) (bool, error) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	entry, ok := e.current(name, key, val)
+	if !ok {
+		return false, nil
+	}
+	entry.expires = time.Time{}
+	if expiration > 0 {
+		entry.expires = time.Now().Add(expiration)
+	}
+	return true, nil
+}
+
+func (e *memoryEngine) CompareAndDel(name string, key string, val []byte) (bool, error) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if _, ok := e.current(name, key, val); !ok {
+		return false, nil
+	}
+	e.remove(e.entries[memoryKey(name, key)])
+	return true, nil
+}
+
+func (e *memoryEngine) IncrBy(name string, key string, delta int64, expiration time.Duration) (int64, error) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	entry := e.live(name, key)
+	if entry == nil {
+		entry = &memoryEntry{name: name, key: key}
+		if expiration > 0 {
+			entry.expires = time.Now().Add(expiration)
+		}
+		e.putLocked(entry)
+	}
+	n, _ := strconv.ParseInt(string(entry.value), 10, 64)
+	n += delta
+	entry.value = []byte(strconv.FormatInt(n, 10))
+	return n, nil
+}
+
+func (e *memoryEngine) Counter(name string, key string) (int64, error) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	entry := e.live(name, key)
+	if entry == nil {
+		return 0, errNf
+	}
+	return strconv.ParseInt(string(entry.value), 10, 64)
+}
+
+func (e *memoryEngine) MPut(name string, entries map[string][]byte, expiration time.Duration) error {
+	for key, val := range entries {
+		if err := e.Put(name, key, val, expiration); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *memoryEngine) DelPrefix(name string, prefix string) (int, error) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	deleted := 0
+	for _, el := range e.entries {
+		entry := el.Value.(*memoryEntry)
+		if entry.name == name && strings.HasPrefix(entry.key, prefix) {
+			e.remove(el)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (e *memoryEngine) TTL(name string, key string) (time.Duration, error) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	entry := e.live(name, key)
+	if entry == nil {
+		return 0, errNf
+	} else if entry.expires.IsZero() {
+		return 0, nil
+	}
+	return time.Until(entry.expires), nil
+}
+
+func (e *memoryEngine) Expire(name string, key string, expiration time.Duration) error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	entry := e.live(name, key)
+	if entry == nil {
+		return errNf
+	}
+	entry.expires = time.Now().Add(expiration)
+	return nil
+}
+
+func (e *memoryEngine) Persist(name string, key string) error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	entry := e.live(name, key)
+	if entry == nil {
+		return errNf
+	}
+	entry.expires = time.Time{}
+	return nil
+}
+
+func (e *memoryEngine) SAdd(name string, key string, members []string) error {
+	fields := make(map[string][]byte, len(members))
+	for _, member := range members {
+		fields[member] = nil
+	}
+	return e.HSet(name, key, fields)
+}
+
+func (e *memoryEngine) SRem(name string, key string, members []string) error {
+	return e.HDel(name, key, members)
+}
+
+func (e *memoryEngine) SMembers(name string, key string) ([]string, error) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	members := []string{}
+	if entry := e.live(name, key); entry != nil {
+		for member := range entry.fields {
+			members = append(members, member)
+		}
+	}
+	return members, nil
+}
+
+func (e *memoryEngine) SIsMember(name string, key string, member string) (bool, error) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if entry := e.live(name, key); entry != nil {
+		_, ok := entry.fields[member]
+		return ok, nil
+	}
+	return false, nil
+}
+
+func (e *memoryEngine) HSet(name string, key string, fields map[string][]byte) error {
+	if len(fields) == 0 {
+		return nil
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	entry := e.live(name, key)
+	if entry == nil {
+		entry = &memoryEntry{name: name, key: key}
+		e.putLocked(entry)
+	}
+	if entry.fields == nil {
+		entry.fields = map[string][]byte{}
+	}
+	for field, val := range fields {
+		entry.fields[field] = append([]byte(nil), val...)
+	}
+	return nil
+}
+
+func (e *memoryEngine) HGet(name string, key string, field string) ([]byte, error) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if entry := e.live(name, key); entry != nil {
+		if val, ok := entry.fields[field]; ok {
+			return val, nil
+		}
+	}
+	return nil, errNf
+}
+
+func (e *memoryEngine) HDel(name string, key string, fields []string) error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	entry := e.live(name, key)
+	if entry == nil || entry.fields == nil {
+		return nil
+	}
+	for _, field := range fields {
+		delete(entry.fields, field)
+	}
+	// Like on Redis, empty collections disappear
+	if len(entry.fields) == 0 {
+		e.remove(e.entries[memoryKey(name, key)])
+	}
+	return nil
+}
+
+func (e *memoryEngine) HGetAll(name string, key string) (map[string][]byte, error) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	result := map[string][]byte{}
+	if entry := e.live(name, key); entry != nil {
+		for field, val := range entry.fields {
+			result[field] = val
+		}
+	}
+	return result, nil
+}
+
+// Returns the entry if it exists and hasn't expired, has to be called with the lock held
+func (e *memoryEngine) live(name string, key string) *memoryEntry {
+	el, ok := e.entries[memoryKey(name, key)]
+	if !ok {
+		return nil
+	}
+	entry := el.Value.(*memoryEntry)
+	if entry.expired(time.Now()) {
+		e.remove(el)
+		return nil
+	}
+	e.lru.MoveToFront(el)
+	return entry
+}
+
+// Returns the live entry if it holds val, has to be called with the lock held
+func (e *memoryEngine) current(name string, key string, val []byte) (*memoryEntry, bool) {
+	el, ok := e.entries[memoryKey(name, key)]
+	if !ok {
+		return nil, false
+	}
+	entry := el.Value.(*memoryEntry)
+	if entry.expired(time.Now()) || !bytes.Equal(entry.value, val) {
+		return nil, false
+	}
+	return entry, true
+}
+
+func (e *memoryEngine) Del(name string, key string) error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if el, ok := e.entries[memoryKey(name, key)]; ok {
+		e.remove(el)
+	}
+	return nil
+}
+
+func (e *memoryEngine) Close() error {
+	if e.stop != nil {
+		close(e.stop)
+	}
+	return nil
+}
+
+// Has to be called with the lock held
+func (e *memoryEngine) remove(el *list.Element) {
+	e.lru.Remove(el)
+	entry := el.Value.(*memoryEntry)
+	delete(e.entries, memoryKey(entry.name, entry.key))
+}
+
+// Separated like in the L1 cache, so "a_" + "b" and "a" + "_b" can't collide
+func memoryKey(name string, key string) string {
+	return name + "\x00" + key
+}
+
+// Removes expired entries periodically, so keys which are never read again don't pile up
+func (e *memoryEngine) janitor() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-e.stop:
+			return
+		case now := <-ticker.C:
+			e.lock.Lock()
+			for _, el := range e.entries {
+				if el.Value.(*memoryEntry).expired(now) {
+					e.remove(el)
+				}
+			}
+			e.lock.Unlock()
+		}
+	}
+}
+
+func (m *memoryEntry) expired(now time.Time) bool {
+	return !m.expires.IsZero() && now.After(m.expires)
+}
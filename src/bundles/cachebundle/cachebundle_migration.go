@@ -0,0 +1,61 @@
+package cachebundle
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/sc-js/pour"
+)
+
+// Converts a value written before the codec layer into the format of the active codec
+type LegacyConverter func(name string, key string, old []byte) ([]byte, error)
+
+// Legacy Redis values were plain strings: numbers and JSON documents are kept as they are and
+// everything else is encoded as a string. Bool flags were written as 1/0, pass their names in boolNames.
+func DefaultLegacyConverter(boolNames ...string) LegacyConverter {
+	return func(name string, key string, old []byte) ([]byte, error) {
+		for _, boolName := range boolNames {
+			if name == boolName {
+				return getCodec().Marshal(string(old) != "0" && len(old) > 0)
+			}
+		}
+		var val interface{}
+		if json.Valid(old) {
+			if err := json.Unmarshal(old, &val); err != nil {
+				return nil, err
+			}
+		} else {
+			val = string(old)
+		}
+		return getCodec().Marshal(val)
+	}
+}
+
+// Moves Redis keys of the given names from the old name+key layout to the namespaced key scheme,
+// returns how many keys were moved. Only needed once when upgrading a deployment with existing sessions.
+// Keys are told apart by their name prefix, legacy keys containing a colon are left alone.
+func MigrateRedisKeys(names []string, convert LegacyConverter) (int, error) {
+	engine, ok := activeEngine.(*redisEngine)
+	if !ok {
+		return 0, errors.New("key migration is only supported for redis")
+	}
+	// The client is nil until a degraded start connected
+	if !available.Load() {
+		return 0, ErrUnavailable
+	}
+	if convert == nil {
+		convert = DefaultLegacyConverter()
+	}
+	total := 0
+	for _, name := range names {
+		moved, err := engine.migrateLegacyKeys(name, convert)
+		total += moved
+		if err != nil {
+			return total, err
+		}
+		if moved > 0 {
+			pour.LogColor(false, pour.ColorPurple, "Migrated", moved, "cache keys of", name)
+		}
+	}
+	return total, nil
+}
@@ -0,0 +1,19 @@
+package cachebundle
+
+type localeRequest struct {
+	Locale string `json:"locale" binding:"required"`
+}
+
+type translationRequest struct {
+	Value string `json:"value"`
+}
+
+type translationEntry struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+type localeEntries struct {
+	LocaleStats
+	Entries []translationEntry `json:"entries"`
+}
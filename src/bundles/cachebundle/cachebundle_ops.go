@@ -0,0 +1,270 @@
+package cachebundle
+
+import (
+	"errors"
+	"time"
+)
+
+// Reads many keys of a name with one round-trip if the engine supports it, missing keys are left out of the result
+func MGet[T any](name string, keys []string) (map[string]T, error) {
+	return getMany[T](name, keys)
+}
+
+// Stores all entries under the name with the same expiration, 0 doesn't expire
+func MPut[T any](name string, entries map[string]T, expiration time.Duration) error {
+	c := getCodec()
+	raw := make(map[string][]byte, len(entries))
+	for key, val := range entries {
+		data, err := c.Marshal(val)
+		if err != nil {
+			return err
+		}
+		raw[key] = data
+	}
+	err := guard(func() error {
+		if batch, ok := activeEngine.(BatchEngine); ok {
+			return batch.MPut(name, raw, expiration)
+		}
+		for key, data := range raw {
+			if err := activeEngine.Put(name, key, data, expiration); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	// One invalidation for the whole batch instead of one bus message per key
+	if len(raw) == 1 {
+		for key := range raw {
+			invalidateL1(name, key, false)
+		}
+	} else if len(raw) > 1 {
+		invalidateL1(name, "", true)
+	}
+	return err
+}
+
+// Reads and deletes a key in one step, for single-use values like login codes. Of concurrent callers
+// only the one whose delete removed the entry gets the value, the others get ErrNotFound. The engine has to support locks.
+func Take[T any](name string, key string) (T, error) {
+	var result T
+	engine, err := supports[LockEngine]("locks")
+	if err != nil {
+		return result, err
+	}
+	defer invalidateL1(name, key, false)
+	data, err := guarded(func() ([]byte, error) {
+		data, err := activeEngine.Get(name, key)
+		if err != nil {
+			return nil, err
+		}
+		deleted, err := engine.CompareAndDel(name, key, data)
+		if err != nil {
+			return nil, err
+		} else if !deleted {
+			return nil, errNf
+		}
+		return data, nil
+	})
+	if err != nil {
+		return result, err
+	}
+	err = getCodec().Unmarshal(data, &result)
+	return result, err
+}
+
+// Deletes every key of the name starting with prefix, an empty prefix clears the whole name.
+// Returns how many keys were deleted. Aerospike can only match keys stored with the record,
+// entries written before this version are only removed by an empty prefix.
+func DelPrefix(name string, prefix string) (int, error) {
+	engine, err := supports[ScanEngine]("scans")
+	if err != nil {
+		return 0, err
+	}
+	defer invalidateL1(name, prefix, true)
+	return guarded(func() (int, error) {
+		return engine.DelPrefix(name, prefix)
+	})
+}
+
+// Remaining lifetime of a key, 0 if it doesn't expire and ErrNotFound if it doesn't exist
+func TTL(name string, key string) (time.Duration, error) {
+	engine, err := supports[TTLEngine]("expirations")
+	if err != nil {
+		return 0, err
+	}
+	return guarded(func() (time.Duration, error) {
+		return engine.TTL(name, key)
+	})
+}
+
+// Sets a new expiration on an existing key
+func Expire(name string, key string, expiration time.Duration) error {
+	engine, err := supports[TTLEngine]("expirations")
+	if err != nil {
+		return err
+	}
+	return guard(func() error {
+		return engine.Expire(name, key, expiration)
+	})
+}
+
+// Removes the expiration of an existing key
+func Persist(name string, key string) error {
+	engine, err := supports[TTLEngine]("expirations")
+	if err != nil {
+		return err
+	}
+	return guard(func() error {
+		return engine.Persist(name, key)
+	})
+}
+
+// Atomically increments a counter and returns the new value. The expiration is only set when the counter is created,
+// which makes fixed windows for rate limits easy. Counters have to be read with GetCounter.
+func Incr(name string, key string, expiration time.Duration) (int64, error) {
+	return IncrBy(name, key, 1, expiration)
+}
+
+// Atomically decrements a counter, see Incr
+func Decr(name string, key string, expiration time.Duration) (int64, error) {
+	return IncrBy(name, key, -1, expiration)
+}
+
+func IncrBy(name string, key string, delta int64, expiration time.Duration) (int64, error) {
+	engine, err := supports[CounterEngine]("counters")
+	if err != nil {
+		return 0, err
+	}
+	return guarded(func() (int64, error) {
+		return engine.IncrBy(name, key, delta, expiration)
+	})
+}
+
+// Reads a counter written by Incr, Decr or IncrBy
+func GetCounter(name string, key string) (int64, error) {
+	engine, err := supports[CounterEngine]("counters")
+	if err != nil {
+		return 0, err
+	}
+	return guarded(func() (int64, error) {
+		return engine.Counter(name, key)
+	})
+}
+
+// Adds members to the set stored under name/key, the set is created if needed
+func SAdd(name string, key string, members ...string) error {
+	engine, err := supports[CollectionEngine]("sets")
+	if err != nil {
+		return err
+	}
+	return guard(func() error {
+		return engine.SAdd(name, key, members)
+	})
+}
+
+func SRem(name string, key string, members ...string) error {
+	engine, err := supports[CollectionEngine]("sets")
+	if err != nil {
+		return err
+	}
+	return guard(func() error {
+		return engine.SRem(name, key, members)
+	})
+}
+
+// Members of the set in no particular order, empty if it doesn't exist
+func SMembers(name string, key string) ([]string, error) {
+	engine, err := supports[CollectionEngine]("sets")
+	if err != nil {
+		return nil, err
+	}
+	return guarded(func() ([]string, error) {
+		return engine.SMembers(name, key)
+	})
+}
+
+func SIsMember(name string, key string, member string) (bool, error) {
+	engine, err := supports[CollectionEngine]("sets")
+	if err != nil {
+		return false, err
+	}
+	return guarded(func() (bool, error) {
+		return engine.SIsMember(name, key, member)
+	})
+}
+
+// Sets a field of the hash stored under name/key, values go through the active codec like with Put
+func HSet[T any](name string, key string, field string, val T) error {
+	engine, err := supports[CollectionEngine]("hashes")
+	if err != nil {
+		return err
+	}
+	data, err := getCodec().Marshal(val)
+	if err != nil {
+		return err
+	}
+	return guard(func() error {
+		return engine.HSet(name, key, map[string][]byte{field: data})
+	})
+}
+
+// Reads a field of a hash, returns ErrNotFound if the hash or the field doesn't exist
+func HGet[T any](name string, key string, field string) (T, error) {
+	var result T
+	engine, err := supports[CollectionEngine]("hashes")
+	if err != nil {
+		return result, err
+	}
+	data, err := guarded(func() ([]byte, error) {
+		return engine.HGet(name, key, field)
+	})
+	if err != nil {
+		return result, err
+	}
+	err = getCodec().Unmarshal(data, &result)
+	return result, err
+}
+
+func HDel(name string, key string, fields ...string) error {
+	engine, err := supports[CollectionEngine]("hashes")
+	if err != nil {
+		return err
+	}
+	return guard(func() error {
+		return engine.HDel(name, key, fields)
+	})
+}
+
+// All fields of a hash, fields which can't be decoded into T are left out
+func HGetAll[T any](name string, key string) (map[string]T, error) {
+	result := map[string]T{}
+	engine, err := supports[CollectionEngine]("hashes")
+	if err != nil {
+		return result, err
+	}
+	raw, err := guarded(func() (map[string][]byte, error) {
+		return engine.HGetAll(name, key)
+	})
+	if err != nil {
+		return result, err
+	}
+	c := getCodec()
+	for field, data := range raw {
+		var val T
+		if err := c.Unmarshal(data, &val); err == nil {
+			result[field] = val
+		}
+	}
+	return result, nil
+}
+
+// Returns the active engine as the optional interface E
+func supports[E any](feature string) (E, error) {
+	engine, ok := activeEngine.(E)
+	if activeEngine == nil {
+		return engine, errNoEngine
+	} else if !ok {
+		return engine, errors.New("cache engine does not support " + feature)
+	}
+	return engine, nil
+}
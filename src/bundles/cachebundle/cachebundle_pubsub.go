@@ -0,0 +1,124 @@
+package cachebundle
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+
+	"github.com/sc-js/pour"
+)
+
+type subscription struct {
+	receive func(payload []byte)
+}
+
+var (
+	subscriptions = map[string][]*subscription{}
+	// Topics subscribed on the active engine, handlers registered before InitCache are attached there
+	engineTopics = map[string]bool{}
+	subLock      sync.RWMutex
+
+	nodeID = newNodeID()
+)
+
+// Random id of this process, lets subscribers tell their own messages apart from other nodes
+func NodeID() string {
+	return nodeID
+}
+
+// Sends msg to the subscribers of topic on every node, including this one.
+// Messages are encoded with the active codec and delivered at most once, nodes that are down miss them.
+func Publish[T any](topic string, msg T) error {
+	payload, err := getCodec().Marshal(msg)
+	if err != nil {
+		return err
+	}
+	if bus, ok := activeEngine.(PubSubEngine); ok {
+		return guard(func() error {
+			return bus.Publish(topic, payload)
+		})
+	}
+	dispatch(topic, payload)
+	return nil
+}
+
+// Calls handler for every message published on topic, messages that can't be decoded into T are skipped.
+// Can be called before the cache is initialized. The returned function removes the handler again.
+func Subscribe[T any](topic string, handler func(msg T)) func() {
+	sub := &subscription{receive: func(payload []byte) {
+		var msg T
+		if err := getCodec().Unmarshal(payload, &msg); err != nil {
+			pour.LogColor(false, pour.ColorRed, "Error decoding message on", topic+":", err)
+			return
+		}
+		handler(msg)
+	}}
+
+	subLock.Lock()
+	subscriptions[topic] = append(subscriptions[topic], sub)
+	subLock.Unlock()
+	attachTopic(topic)
+
+	return func() {
+		subLock.Lock()
+		defer subLock.Unlock()
+		subs := subscriptions[topic]
+		for i, element := range subs {
+			if element == sub {
+				subscriptions[topic] = append(subs[:i:i], subs[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// Subscribes every known topic on the active engine, called once it is connected
+func attachBus() {
+	subLock.RLock()
+	topics := make([]string, 0, len(subscriptions))
+	for topic := range subscriptions {
+		topics = append(topics, topic)
+	}
+	subLock.RUnlock()
+	for _, topic := range topics {
+		attachTopic(topic)
+	}
+}
+
+func attachTopic(topic string) {
+	// Before the engine is reachable, attachBus picks the topic up once it connects
+	bus, ok := activeEngine.(PubSubEngine)
+	if !ok || !available.Load() {
+		return
+	}
+	subLock.Lock()
+	if engineTopics[topic] {
+		subLock.Unlock()
+		return
+	}
+	engineTopics[topic] = true
+	subLock.Unlock()
+
+	if err := bus.Subscribe(topic, func(payload []byte) { dispatch(topic, payload) }); err != nil {
+		pour.LogColor(false, pour.ColorRed, "Error subscribing to", topic+":", err)
+		subLock.Lock()
+		delete(engineTopics, topic)
+		subLock.Unlock()
+	}
+}
+
+// Hands a message to the local handlers of topic, each in its own goroutine so slow handlers don't block the bus
+func dispatch(topic string, payload []byte) {
+	subLock.RLock()
+	subs := append([]*subscription(nil), subscriptions[topic]...)
+	subLock.RUnlock()
+	for _, element := range subs {
+		go element.receive(payload)
+	}
+}
+
+func newNodeID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,458 @@
+package cachebundle
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/sc-js/pour"
+)
+
+// Keys are namespaced as <workspace>:<name>:<key>, or <name>:<key> without a workspace,
+// so several applications can share one Redis instance.
+type redisEngine struct {
+	client redis.UniversalClient
+	prefix string
+
+	// One connection carries every subscribed channel, go-redis resubscribes it after reconnects
+	pubsub    *redis.PubSub
+	receivers map[string]func(payload []byte)
+	subLock   sync.Mutex
+}
+
+func (e *redisEngine) Connect(config EngineConfig) error {
+	port := 6379
+	if config.PortOverride > 0 {
+		port = int(config.PortOverride)
+	}
+	addrs := config.Addresses
+	if len(addrs) == 0 {
+		addrs = []string{config.Address + ":" + fmt.Sprint(port)}
+	}
+	if len(config.Workspace) > 0 {
+		e.prefix = config.Workspace + ":"
+	}
+
+	password, db := config.Password, config.DB
+	var tlsConfig *tls.Config
+	if config.TLS {
+		tlsConfig = &tls.Config{MinVersion: tls.VersionTLS12, InsecureSkipVerify: config.TLSSkipVerify}
+	}
+	// go-redis only knows password AUTH, which runs before OnConnect. ACL users authenticate here instead,
+	// SELECT has to follow since it isn't allowed before AUTH.
+	var onConnect func(conn *redis.Conn) error
+	if len(config.Username) > 0 {
+		username, aclPassword, aclDB := config.Username, config.Password, config.DB
+		password, db = "", 0
+		onConnect = func(conn *redis.Conn) error {
+			if err := conn.Do("AUTH", username, aclPassword).Err(); err != nil {
+				return err
+			}
+			if aclDB > 0 {
+				return conn.Select(aclDB).Err()
+			}
+			return nil
+		}
+	}
+
+	mode := "Redis"
+	switch {
+	case len(config.MasterName) > 0:
+		mode = "Redis Sentinel (" + config.MasterName + ")"
+		e.client = redis.NewFailoverClient(&redis.FailoverOptions{MasterName: config.MasterName, SentinelAddrs: addrs, Password: password, DB: db, OnConnect: onConnect, TLSConfig: tlsConfig})
+	case config.Cluster || len(addrs) > 1:
+		mode = "Redis Cluster"
+		e.client = redis.NewClusterClient(&redis.ClusterOptions{Addrs: addrs, Password: password, OnConnect: onConnect, TLSConfig: tlsConfig})
+	default:
+		e.client = redis.NewClient(&redis.Options{Addr: addrs[0], Password: password, DB: db, OnConnect: onConnect, TLSConfig: tlsConfig})
+	}
+
+	if _, err := e.client.Ping().Result(); err != nil {
+		// Every retry builds a new client, the failed one would keep its pool and goroutines otherwise
+		e.client.Close()
+		e.client = nil
+		return err
+	}
+	pour.LogColor(false, pour.ColorPurple, mode, "connected at", strings.Join(addrs, ", "))
+	return nil
+}
+
+// Pool timeouts and server states in which Redis can't serve requests, like a replica still loading or a cluster without quorum
+func (e *redisEngine) IsConnectionError(err error) bool {
+	msg := err.Error()
+	if msg == "redis: connection pool timeout" || msg == "redis: client is closed" {
+		return true
+	}
+	for _, state := range []string{"LOADING ", "MASTERDOWN ", "CLUSTERDOWN ", "TRYAGAIN ", "READONLY "} {
+		if strings.HasPrefix(msg, state) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *redisEngine) key(name string, key string) string {
+	return e.prefix + name + ":" + key
+}
+
+func (e *redisEngine) Put(name string, key string, val []byte, expiration time.Duration) error {
+	return e.client.Set(e.key(name, key), val, expiration).Err()
+}
+
+func (e *redisEngine) Get(name string, key string) ([]byte, error) {
+	data, err := e.client.Get(e.key(name, key)).Bytes()
+	if err == redis.Nil {
+		return nil, errNf
+	}
+	return data, err
+}
+
+// Uses a pipeline instead of MGET, cluster clients split it by slot where MGET would fail with CROSSSLOT
+func (e *redisEngine) MGet(name string, keys []string) (map[string][]byte, error) {
+	result := map[string][]byte{}
+	if len(keys) == 0 {
+		return result, nil
+	}
+	cmds := make([]*redis.StringCmd, len(keys))
+	_, err := e.client.Pipelined(func(pipe redis.Pipeliner) error {
+		for i, key := range keys {
+			cmds[i] = pipe.Get(e.key(name, key))
+		}
+		return nil
+	})
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+	for i, cmd := range cmds {
+		if data, err := cmd.Bytes(); err == nil {
+			result[keys[i]] = data
+		}
+	}
+	return result, nil
+}
+
+func (e *redisEngine) MPut(name string, entries map[string][]byte, expiration time.Duration) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	_, err := e.client.Pipelined(func(pipe redis.Pipeliner) error {
+		for key, val := range entries {
+			pipe.Set(e.key(name, key), val, expiration)
+		}
+		return nil
+	})
+	return err
+}
+
+// Deletes in pipelines of single-key DELs, multi-key DELs fail on clusters when the keys are in different slots
+func (e *redisEngine) DelPrefix(name string, prefix string) (int, error) {
+	deleted := 0
+	err := e.scanKeys(escapePattern(e.key(name, prefix))+"*", func(client redis.Cmdable, keys []string) error {
+		cmds := make([]*redis.IntCmd, len(keys))
+		_, err := e.client.Pipelined(func(pipe redis.Pipeliner) error {
+			for i, key := range keys {
+				cmds[i] = pipe.Del(key)
+			}
+			return nil
+		})
+		for _, cmd := range cmds {
+			deleted += int(cmd.Val())
+		}
+		return err
+	})
+	return deleted, err
+}
+
+// Iterates the keyspace with SCAN, so it doesn't block the server like KEYS would
+func (e *redisEngine) Scan(name string) (map[string][]byte, error) {
+	prefix := e.key(name, "")
+	result := map[string][]byte{}
+	err := e.scanKeys(escapePattern(prefix)+"*", func(client redis.Cmdable, keys []string) error {
+		stripped := make([]string, len(keys))
+		for i, key := range keys {
+			stripped[i] = strings.TrimPrefix(key, prefix)
+		}
+		values, err := e.MGet(name, stripped)
+		for key, value := range values {
+			result[key] = value
+		}
+		return err
+	})
+	return result, err
+}
+
+// Calls fn with every batch of keys matching the pattern, on clusters every master is scanned
+func (e *redisEngine) scanKeys(pattern string, fn func(client redis.Cmdable, keys []string) error) error {
+	scan := func(client redis.Cmdable) error {
+		var cursor uint64
+		for {
+			keys, next, err := client.Scan(cursor, pattern, 500).Result()
+			if err != nil {
+				return err
+			}
+			if len(keys) > 0 {
+				if err := fn(client, keys); err != nil {
+					return err
+				}
+			}
+			if next == 0 {
+				return nil
+			}
+			cursor = next
+		}
+	}
+	if cluster, ok := e.client.(*redis.ClusterClient); ok {
+		return cluster.ForEachMaster(func(master *redis.Client) error {
+			return scan(master)
+		})
+	}
+	return scan(e.client)
+}
+
+func escapePattern(pattern string) string {
+	return strings.NewReplacer("*", "\\*", "?", "\\?", "[", "\\[", "]", "\\]").Replace(pattern)
+}
+
+const (
+	redisRenewScript = `if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("pexpire", KEYS[1], ARGV[2]) else return 0 end`
+	redisDelScript   = `if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("del", KEYS[1]) else return 0 end`
+)
+
+func (e *redisEngine) PutIfAbsent(name string, key string, val []byte, expiration time.Duration) (bool, error) {
+	return e.client.SetNX(e.key(name, key), val, expiration).Result()
+}
+
+func (e *redisEngine) CompareAndExpire(name string, key string, val []byte, expiration time.Duration) (bool, error) {
+	res, err := e.client.Eval(redisRenewScript, []string{e.key(name, key)}, string(val), int64(expiration/time.Millisecond)).Int64()
+	return res == 1, err
+}
+
+func (e *redisEngine) CompareAndDel(name string, key string, val []byte) (bool, error) {
+	res, err := e.client.Eval(redisDelScript, []string{e.key(name, key)}, string(val)).Int64()
+	return res == 1, err
+}
+
+// Counters are plain integers, so INCRBY works on them. The TTL is only set if INCRBY created the key.
+const redisIncrScript = `local created = redis.call("exists", KEYS[1]) == 0
+local v = redis.call("incrby", KEYS[1], ARGV[1])
+if created and tonumber(ARGV[2]) > 0 then redis.call("pexpire", KEYS[1], ARGV[2]) end
+return v`
+
+func (e *redisEngine) IncrBy(name string, key string, delta int64, expiration time.Duration) (int64, error) {
+	return e.client.Eval(redisIncrScript, []string{e.key(name, key)}, delta, int64(expiration/time.Millisecond)).Int64()
+}
+
+func (e *redisEngine) Counter(name string, key string) (int64, error) {
+	n, err := e.client.Get(e.key(name, key)).Int64()
+	if err == redis.Nil {
+		return 0, errNf
+	}
+	return n, err
+}
+
+func (e *redisEngine) TTL(name string, key string) (time.Duration, error) {
+	ttl, err := e.client.PTTL(e.key(name, key)).Result()
+	if err != nil {
+		return 0, err
+	}
+	// -2 is returned for missing keys, -1 for keys without expiration
+	switch ttl {
+	case -2 * time.Millisecond:
+		return 0, errNf
+	case -1 * time.Millisecond:
+		return 0, nil
+	}
+	return ttl, nil
+}
+
+func (e *redisEngine) Expire(name string, key string, expiration time.Duration) error {
+	ok, err := e.client.PExpire(e.key(name, key), expiration).Result()
+	if err == nil && !ok {
+		return errNf
+	}
+	return err
+}
+
+func (e *redisEngine) Persist(name string, key string) error {
+	ok, err := e.client.Persist(e.key(name, key)).Result()
+	if err != nil || ok {
+		return err
+	}
+	// PERSIST also returns 0 for keys which didn't expire anyway
+	exists, err := e.client.Exists(e.key(name, key)).Result()
+	if err == nil && exists == 0 {
+		return errNf
+	}
+	return err
+}
+
+func (e *redisEngine) SAdd(name string, key string, members []string) error {
+	if len(members) == 0 {
+		return nil
+	}
+	return e.client.SAdd(e.key(name, key), toInterfaces(members)...).Err()
+}
+
+func (e *redisEngine) SRem(name string, key string, members []string) error {
+	if len(members) == 0 {
+		return nil
+	}
+	return e.client.SRem(e.key(name, key), toInterfaces(members)...).Err()
+}
+
+func (e *redisEngine) SMembers(name string, key string) ([]string, error) {
+	return e.client.SMembers(e.key(name, key)).Result()
+}
+
+func (e *redisEngine) SIsMember(name string, key string, member string) (bool, error) {
+	return e.client.SIsMember(e.key(name, key), member).Result()
+}
+
+func (e *redisEngine) HSet(name string, key string, fields map[string][]byte) error {
+	if len(fields) == 0 {
+		return nil
+	}
+	values := make(map[string]interface{}, len(fields))
+	for field, val := range fields {
+		values[field] = val
+	}
+	return e.client.HMSet(e.key(name, key), values).Err()
+}
+
+func (e *redisEngine) HGet(name string, key string, field string) ([]byte, error) {
+	data, err := e.client.HGet(e.key(name, key), field).Bytes()
+	if err == redis.Nil {
+		return nil, errNf
+	}
+	return data, err
+}
+
+func (e *redisEngine) HDel(name string, key string, fields []string) error {
+	if len(fields) == 0 {
+		return nil
+	}
+	return e.client.HDel(e.key(name, key), fields...).Err()
+}
+
+func (e *redisEngine) HGetAll(name string, key string) (map[string][]byte, error) {
+	values, err := e.client.HGetAll(e.key(name, key)).Result()
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string][]byte, len(values))
+	for field, val := range values {
+		result[field] = []byte(val)
+	}
+	return result, nil
+}
+
+func toInterfaces(values []string) []interface{} {
+	result := make([]interface{}, len(values))
+	for i, val := range values {
+		result[i] = val
+	}
+	return result
+}
+
+func (e *redisEngine) Del(name string, key string) error {
+	return e.client.Del(e.key(name, key)).Err()
+}
+
+func (e *redisEngine) Publish(topic string, payload []byte) error {
+	return e.client.Publish(e.channel(topic), payload).Err()
+}
+
+func (e *redisEngine) Subscribe(topic string, receive func(payload []byte)) error {
+	e.subLock.Lock()
+	defer e.subLock.Unlock()
+	channel := e.channel(topic)
+	if e.pubsub == nil {
+		e.receivers = map[string]func(payload []byte){channel: receive}
+		e.pubsub = e.client.Subscribe(channel)
+		// Waits for the confirmation, so messages published after Subscribe returns are received
+		if _, err := e.pubsub.Receive(); err != nil {
+			e.pubsub.Close()
+			e.pubsub = nil
+			return err
+		}
+		go e.receive(e.pubsub.Channel())
+		return nil
+	}
+	e.receivers[channel] = receive
+	return e.pubsub.Subscribe(channel)
+}
+
+func (e *redisEngine) receive(messages <-chan *redis.Message) {
+	for msg := range messages {
+		e.subLock.Lock()
+		receive := e.receivers[msg.Channel]
+		e.subLock.Unlock()
+		if receive != nil {
+			receive([]byte(msg.Payload))
+		}
+	}
+}
+
+// Channels share the key prefix, so workspaces on the same server don't see each others messages
+func (e *redisEngine) channel(topic string) string {
+	return e.prefix + "pubsub:" + topic
+}
+
+func (e *redisEngine) Close() error {
+	e.subLock.Lock()
+	if e.pubsub != nil {
+		e.pubsub.Close()
+	}
+	e.subLock.Unlock()
+	return e.client.Close()
+}
+
+// Moves keys written before the namespaced key scheme (name+key) to <workspace>:<name>:<key>.
+// The values are passed through convert, the remaining TTL is kept.
+func (e *redisEngine) migrateLegacyKeys(name string, convert LegacyConverter) (int, error) {
+	moved := 0
+	newPrefix := e.key(name, "")
+	err := e.scanKeys(escapePattern(name)+"*", func(client redis.Cmdable, keys []string) error {
+		for _, legacy := range keys {
+			key := strings.TrimPrefix(legacy, name)
+			// Namespaced keys always have a colon after their name, this also skips names sharing the prefix (user_sessions:<id>)
+			if strings.HasPrefix(legacy, newPrefix) || strings.Contains(key, ":") {
+				continue
+			}
+			data, err := client.Get(legacy).Bytes()
+			if err == redis.Nil {
+				continue
+			} else if err != nil {
+				return err
+			}
+			ttl, err := client.PTTL(legacy).Result()
+			if err != nil {
+				return err
+			}
+			if ttl < 0 {
+				ttl = 0
+			}
+			converted, err := convert(name, key, data)
+			if err != nil {
+				pour.LogColor(false, pour.ColorYellow, "Skipping legacy cache key", legacy+":", err)
+				continue
+			}
+			if err := e.client.Set(e.key(name, key), converted, ttl).Err(); err != nil {
+				return err
+			}
+			if err := client.Del(legacy).Err(); err != nil {
+				return err
+			}
+			moved++
+		}
+		return nil
+	})
+	if err != nil {
+		return moved, errors.New("migrating " + name + ": " + err.Error())
+	}
+	return moved, nil
+}
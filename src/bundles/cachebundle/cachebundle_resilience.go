@@ -0,0 +1,172 @@
+package cachebundle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/sc-js/pour"
+)
+
+// What InitCache does if the engine can't be reached within the StartupTimeout
+const (
+	// Exits the process, the default
+	STARTUP_FAIL = "fail"
+	// Starts without cache, calls return ErrUnavailable until a background reconnect succeeds
+	STARTUP_DEGRADE = "degrade"
+	// Blocks until the engine is reachable, ignores the timeout
+	STARTUP_WAIT = "wait"
+)
+
+const (
+	defaultStartupTimeout   = 30 * time.Second
+	defaultBreakerThreshold = 5
+	defaultBreakerCooldown  = 5 * time.Second
+	maxRetryBackoff         = 30 * time.Second
+)
+
+// Returned (wrapped) when the engine can't be reached or the circuit is open.
+// Callers should treat it as "don't know" rather than as a missing key.
+var ErrUnavailable = errors.New("cache unavailable")
+
+var (
+	// False until the engine connected and while the circuit is open, calls fail fast then
+	available  atomic.Bool
+	failures   atomic.Int32
+	recovering atomic.Bool
+
+	breakerThreshold int32 = defaultBreakerThreshold
+	breakerCooldown        = defaultBreakerCooldown
+	engineConfig     EngineConfig
+)
+
+// Reports whether err means the cache couldn't answer, as opposed to ErrNotFound
+func IsUnavailable(err error) bool {
+	return errors.Is(err, ErrUnavailable)
+}
+
+// Reports whether cache calls currently reach the engine
+func IsAvailable() bool {
+	return available.Load()
+}
+
+// Connects the engine according to the startup policy, returns false if it should start degraded
+func connectWithPolicy(engineName string, engine CacheEngine, config EngineConfig) bool {
+	timeout := config.StartupTimeout
+	if timeout <= 0 {
+		timeout = defaultStartupTimeout
+	}
+	deadline := time.Now().Add(timeout)
+	for attempt := 0; ; attempt++ {
+		err := engine.Connect(config)
+		if err == nil {
+			return true
+		}
+		if config.StartupPolicy != STARTUP_WAIT && time.Now().After(deadline) {
+			if config.StartupPolicy == STARTUP_DEGRADE {
+				pour.LogColor(false, pour.ColorRed, "Cache engine", engineName, "unreachable, starting without cache:", err)
+				return false
+			}
+			pour.LogPanicKill(1, err)
+		}
+		wait := retryBackoff(attempt)
+		pour.LogColor(false, pour.ColorYellow, "Cache engine", engineName, "unreachable, retrying in", wait.String()+":", err)
+		time.Sleep(wait)
+	}
+}
+
+// Runs an engine call through the circuit breaker. Only connection errors and timeouts count as failures,
+// after breakerThreshold failures in a row the circuit opens and calls fail fast until a probe succeeds.
+// Other errors (missing keys, held locks, bad payloads) mean the engine answered and are returned as they are.
+func guard(fn func() error) error {
+	if activeEngine == nil {
+		return errNoEngine
+	}
+	if !available.Load() {
+		return ErrUnavailable
+	}
+	err := fn()
+	if err == nil || !isConnectionError(err) {
+		failures.Store(0)
+		return err
+	}
+	if failures.Add(1) >= breakerThreshold && available.CompareAndSwap(true, false) {
+		pour.LogColor(false, pour.ColorRed, "Cache engine failing, opening the circuit:", err)
+		go recoverEngine(true)
+	}
+	return fmt.Errorf("%w: %w", ErrUnavailable, err)
+}
+
+func isConnectionError(err error) bool {
+	if engine, ok := activeEngine.(FailureEngine); ok && engine.IsConnectionError(err) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, context.DeadlineExceeded)
+}
+
+// Like guard for calls returning a value
+func guarded[V any](fn func() (V, error)) (V, error) {
+	var val V
+	err := guard(func() error {
+		var err error
+		val, err = fn()
+		return err
+	})
+	return val, err
+}
+
+// Retries in the background until the engine answers again. Engines which never connected are connected first,
+// connected ones are probed with a read, their clients reconnect on their own.
+func recoverEngine(connected bool) {
+	if !recovering.CompareAndSwap(false, true) {
+		return
+	}
+	defer recovering.Store(false)
+	for attempt := 0; ; attempt++ {
+		wait := retryBackoff(attempt)
+		if wait < breakerCooldown {
+			wait = breakerCooldown
+		}
+		time.Sleep(wait)
+
+		var err error
+		if connected {
+			_, err = activeEngine.Get("health", "probe")
+			if IsNotFound(err) {
+				err = nil
+			}
+		} else {
+			err = activeEngine.Connect(engineConfig)
+		}
+		if err == nil {
+			failures.Store(0)
+			available.Store(true)
+			pour.LogColor(false, pour.ColorPurple, "Cache engine available again")
+			if !connected {
+				onConnected()
+			}
+			return
+		}
+		pour.LogColor(false, pour.ColorYellow, "Cache engine still unavailable:", err)
+	}
+}
+
+// Exponential backoff starting at 250ms, capped at maxRetryBackoff
+func retryBackoff(attempt int) time.Duration {
+	wait := 250 * time.Millisecond
+	for i := 0; i < attempt && wait < maxRetryBackoff; i++ {
+		wait *= 2
+	}
+	if wait > maxRetryBackoff {
+		wait = maxRetryBackoff
+	}
+	return wait
+}
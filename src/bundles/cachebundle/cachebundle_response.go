@@ -0,0 +1,196 @@
+package cachebundle
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sc-js/backend_core/src/tools"
+	"github.com/sc-js/pour"
+)
+
+type cachedResponse struct {
+	Status      int    `json:"s"`
+	ContentType string `json:"c"`
+	ETag        string `json:"e"`
+	Body        []byte `json:"b"`
+	// Headers set by the handler, sent again on hits
+	Headers map[string][]string `json:"h,omitempty"`
+}
+
+// Headers the middleware sets itself or that must never be replayed to another client
+var unstoredHeaders = map[string]bool{"Content-Type": true, "Content-Length": true, "Etag": true, "Cache-Control": true, "Vary": true, "X-Cache": true, "Set-Cookie": true}
+
+// Collects the response of the handler, so it can be stored before anything is sent
+type bufferedWriter struct {
+	gin.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *bufferedWriter) WriteHeader(code int) {
+	w.status = code
+}
+
+func (w *bufferedWriter) WriteHeaderNow() {}
+
+func (w *bufferedWriter) Write(data []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.body.Write(data)
+}
+
+func (w *bufferedWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *bufferedWriter) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+func (w *bufferedWriter) Size() int {
+	return w.body.Len()
+}
+
+func (w *bufferedWriter) Written() bool {
+	return w.status != 0
+}
+
+// Caches successful responses of the route according to its CacheTTL/CacheVary/CacheTags, answers with 304
+// if the client already has the current version. A request with "Cache-Control: no-cache" skips the lookup and refreshes the entry,
+// as does one for which the CacheBypass of the route returns true.
+func ResponseCacheMiddleware(route tools.GinRoute) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		bypass := strings.Contains(c.GetHeader("Cache-Control"), "no-cache") || (route.CacheBypass != nil && route.CacheBypass(c))
+		key := responseCacheKey(c, route)
+		if !bypass {
+			if cached, err := Get[cachedResponse]("response_cache", key); err == nil {
+				c.Header("X-Cache", "HIT")
+				serveCachedResponse(c, route, cached)
+				c.Abort()
+				return
+			}
+		}
+
+		original := c.Writer
+		before := original.Header().Clone()
+		writer := &bufferedWriter{ResponseWriter: original}
+		c.Writer = writer
+		c.Next()
+		c.Writer = original
+
+		response := cachedResponse{Status: writer.Status(), ContentType: original.Header().Get("Content-Type"), Body: writer.body.Bytes(), Headers: handlerHeaders(before, original.Header())}
+		if response.Status != http.StatusOK {
+			c.Data(response.Status, response.ContentType, response.Body)
+			return
+		}
+		hash := sha256.Sum256(response.Body)
+		response.ETag = `"` + hex.EncodeToString(hash[:16]) + `"`
+		if err := PutExpire("response_cache", key, response, route.CacheTTL); err != nil {
+			pour.LogColor(false, pour.ColorYellow, "Could not cache response of", route.Endpoint+":", err)
+		}
+		c.Header("X-Cache", "MISS")
+		serveCachedResponse(c, route, response)
+	}
+}
+
+// Headers the handler added or changed, headers of earlier middlewares (CORS, request ids) belong to the current request
+func handlerHeaders(before http.Header, after http.Header) map[string][]string {
+	headers := map[string][]string{}
+	for name, values := range after {
+		if unstoredHeaders[name] || strings.Join(before[name], "\x00") == strings.Join(values, "\x00") {
+			continue
+		}
+		headers[name] = values
+	}
+	if len(headers) == 0 {
+		return nil
+	}
+	return headers
+}
+
+func serveCachedResponse(c *gin.Context, route tools.GinRoute, response cachedResponse) {
+	for name, values := range response.Headers {
+		c.Writer.Header()[name] = values
+	}
+	// Only anonymous responses of public routes may be kept by shared caches
+	visibility := "public"
+	if route.Permission != tools.PERM_ZERO || len(c.GetHeader("Authorization")) > 0 || len(c.GetHeader("Cookie")) > 0 {
+		visibility = "private"
+	}
+	vary := []string{}
+	if tools.Contains(route.CacheVary, tools.VARY_USER) {
+		visibility = "private"
+		vary = append(vary, "Authorization", "Cookie")
+	}
+	if tools.Contains(route.CacheVary, tools.VARY_LOCALE) {
+		vary = append(vary, "X-LOCALE", "Accept-Language")
+		// The locale can also come from the preference of the authenticated user
+		if !tools.Contains(vary, "Authorization") {
+			vary = append(vary, "Authorization", "Cookie")
+		}
+	}
+	if len(vary) > 0 {
+		c.Header("Vary", strings.Join(vary, ", "))
+	}
+	c.Header("ETag", response.ETag)
+	c.Header("Cache-Control", fmt.Sprintf("%s, max-age=%d", visibility, int(route.CacheTTL/time.Second)))
+
+	if etagMatches(c.GetHeader("If-None-Match"), response.ETag) {
+		c.Status(http.StatusNotModified)
+		c.Writer.WriteHeaderNow()
+		return
+	}
+	c.Data(response.Status, response.ContentType, response.Body)
+}
+
+func etagMatches(header string, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// The key contains the generation of every tag of the route, so bumping a tag orphans all its responses
+func responseCacheKey(c *gin.Context, route tools.GinRoute) string {
+	parts := []string{c.Request.URL.Path}
+	for _, vary := range route.CacheVary {
+		switch vary {
+		case tools.VARY_USER:
+			parts = append(parts, "u="+tools.GetSubject(c))
+		case tools.VARY_LOCALE:
+			parts = append(parts, "l="+tools.GetLocale(c))
+		case tools.VARY_QUERY:
+			parts = append(parts, "q="+c.Request.URL.Query().Encode())
+		}
+	}
+	for _, tag := range route.CacheTags {
+		generation, _ := GetCounter("response_tag", tag)
+		parts = append(parts, fmt.Sprint("t=", tag, ":", generation))
+	}
+	hash := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(hash[:])
+}
+
+// Drops every cached response of routes carrying one of the tags. The generations are incremented atomically,
+// so concurrent invalidations each bump them.
+func InvalidateResponses(tags ...string) error {
+	for _, tag := range tags {
+		if _, err := Incr("response_tag", tag, 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
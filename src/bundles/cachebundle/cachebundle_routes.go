@@ -0,0 +1,26 @@
+package cachebundle
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	t "github.com/sc-js/backend_core/src/tools"
+)
+
+var routes []t.GinRoute
+
+// Registers the admin endpoints of the translation management, the cache has to be initialized before
+func InitRoutes(r *gin.RouterGroup) {
+	routes = []t.GinRoute{
+		{Method: http.MethodGet, Endpoint: "/translations", Handler: getLocalesHandler, Permission: t.PERM_ADMIN},
+		{Method: http.MethodPost, Endpoint: "/translations", Handler: addLocaleHandler, Permission: t.PERM_ADMIN},
+		{Method: http.MethodGet, Endpoint: "/translations/:locale", Handler: getTranslationsHandler, Permission: t.PERM_ADMIN},
+		{Method: http.MethodPut, Endpoint: "/translations/:locale", Handler: importTranslationsHandler, Permission: t.PERM_ADMIN},
+		{Method: http.MethodDelete, Endpoint: "/translations/:locale", Handler: removeLocaleHandler, Permission: t.PERM_ADMIN},
+		{Method: http.MethodGet, Endpoint: "/translations/:locale/export", Handler: exportTranslationsHandler, Permission: t.PERM_ADMIN},
+		{Method: http.MethodPatch, Endpoint: "/translations/:locale/:key", Handler: setTranslationHandler, Permission: t.PERM_ADMIN},
+		{Method: http.MethodDelete, Endpoint: "/translations/:locale/:key", Handler: deleteTranslationHandler, Permission: t.PERM_ADMIN},
+	}
+
+	t.InitHandlers(r, routes)
+}
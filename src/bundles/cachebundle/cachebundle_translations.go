@@ -0,0 +1,457 @@
+package cachebundle
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sc-js/pour"
+)
+
+// Cache bus topic, keeps the valid locales of all nodes in sync when locales are added or removed
+const TOPIC_LOCALES = "translation_locales"
+
+var (
+	ErrLocaleInvalid  = errors.New("err_locale_invalid")
+	ErrLocaleExists   = errors.New("err_locale_exists")
+	ErrLocaleDefault  = errors.New("err_locale_default")
+	ErrLocaleNotFound = errors.New("err_locale_not_found")
+
+	localesLock   sync.RWMutex
+	localePattern = regexp.MustCompile(`^[a-z]{2,3}(_[A-Za-z0-9]{2,4})?$`)
+	localesOnce   sync.Once
+)
+
+type localeChange struct {
+	Node    string `json:"node"`
+	Locale  string `json:"locale"`
+	Removed bool   `json:"removed"`
+}
+
+// Completion of a locale, keys are counted against all keys of all locales
+type LocaleStats struct {
+	Locale     string  `json:"locale"`
+	Default    bool    `json:"default"`
+	Keys       int     `json:"keys"`
+	Translated int     `json:"translated"`
+	Completion float64 `json:"completion"`
+}
+
+// Sorted list of the valid locales
+func Locales() []string {
+	localesLock.RLock()
+	defer localesLock.RUnlock()
+	locales := make([]string, 0, len(valid_locales))
+	for locale := range valid_locales {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// Whether the locale is one of the built-in ones, which can't be removed
+func IsDefaultLocale(locale string) bool {
+	_, ok := default_locales[locale]
+	return ok
+}
+
+// Marks a well-formed locale as valid, returns false for names that aren't locales
+func registerLocale(locale string) bool {
+	if !localePattern.MatchString(locale) {
+		return false
+	}
+	localesLock.Lock()
+	valid_locales[locale] = true
+	localesLock.Unlock()
+	return true
+}
+
+func unregisterLocale(locale string) {
+	localesLock.Lock()
+	delete(valid_locales, locale)
+	localesLock.Unlock()
+}
+
+// Applies locale changes of other nodes, files are shared so only the valid locales need an update
+func subscribeLocales() {
+	localesOnce.Do(func() {
+		Subscribe(TOPIC_LOCALES, func(msg localeChange) {
+			if msg.Node == NodeID() {
+				return
+			}
+			if msg.Removed {
+				unregisterLocale(msg.Locale)
+			} else {
+				registerLocale(msg.Locale)
+			}
+		})
+	})
+}
+
+// Reads the translation file of a locale, a missing file reads as empty
+func ReadTSFile(locale string) (map[string]string, error) {
+	cacheFileLock.RLock()
+	defer cacheFileLock.RUnlock()
+	return readTSFile(locale)
+}
+
+func readTSFile(locale string) (map[string]string, error) {
+	entries := map[string]string{}
+	dat, err := os.ReadFile(translation_path + locale + ".json")
+	if os.IsNotExist(err) {
+		return entries, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if len(dat) == 0 {
+		return entries, nil
+	}
+	if err := json.Unmarshal(dat, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// Reads, changes and writes the translation file of a locale, update returns whether it changed anything.
+// Nodes sharing the translations directory serialize their writes with a cluster-wide lock per locale.
+// The file is replaced atomically, so readers never see a partial write.
+func updateTSFile(locale string, update func(entries map[string]string) bool) error {
+	cacheFileLock.Lock()
+	defer cacheFileLock.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	lease, err := LockWait(ctx, "translation_file_"+locale, 10*time.Second)
+	if err == nil {
+		defer lease.Unlock()
+	} else if err != errNoLockSupport && !IsUnavailable(err) {
+		return err
+	}
+
+	if _, err := os.Stat(translation_path); os.IsNotExist(err) {
+		if err := os.Mkdir(translation_path, 0755); err != nil {
+			return err
+		}
+	}
+	entries, err := readTSFile(locale)
+	if err != nil {
+		return err
+	}
+	_, statErr := os.Stat(translation_path + locale + ".json")
+	if !update(entries) && statErr == nil {
+		return nil
+	}
+	return writeTSFile(locale, entries)
+}
+
+func writeTSFile(locale string, entries map[string]string) error {
+	data, err := json.Marshal(&entries)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(translation_path, locale+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), translation_path+locale+".json")
+}
+
+// Adds a locale with every key known to the other locales, untranslated.
+// The locale is valid on all nodes right away and its file is picked up again on restart.
+func AddLocale(locale string) error {
+	if !localePattern.MatchString(locale) {
+		return ErrLocaleInvalid
+	}
+	if validateLocale(locale) {
+		return ErrLocaleExists
+	}
+	keys, err := allTSKeys()
+	if err != nil {
+		return err
+	}
+	entries := map[string]string{}
+	err = updateTSFile(locale, func(existing map[string]string) bool {
+		for key := range keys {
+			if _, ok := existing[key]; !ok {
+				existing[key] = ""
+			}
+		}
+		for key, val := range existing {
+			entries[key] = val
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	registerLocale(locale)
+	if err := MPut("translation_"+locale+"_", entries, 0); err != nil {
+		pour.LogColor(false, pour.ColorRed, "Error caching translations of", locale+":", err)
+	}
+	if err := Publish(TOPIC_LOCALES, localeChange{Node: NodeID(), Locale: locale}); err != nil {
+		pour.LogColor(false, pour.ColorRed, "Error announcing locale", locale+":", err)
+	}
+	pour.LogColor(false, pour.ColorYellow, "Added locale", locale, "with", len(entries), "keys")
+	return nil
+}
+
+// Removes a locale, its file and its cached translations. Default locales can't be removed.
+func RemoveLocale(locale string) error {
+	if IsDefaultLocale(locale) {
+		return ErrLocaleDefault
+	}
+	if !validateLocale(locale) {
+		return ErrLocaleNotFound
+	}
+	cacheFileLock.Lock()
+	err := os.Remove(translation_path + locale + ".json")
+	cacheFileLock.Unlock()
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	unregisterLocale(locale)
+	if _, err := DelTSLocale(locale); err != nil {
+		pour.LogColor(false, pour.ColorRed, "Error removing cached translations of", locale+":", err)
+	}
+	if err := Publish(TOPIC_LOCALES, localeChange{Node: NodeID(), Locale: locale, Removed: true}); err != nil {
+		pour.LogColor(false, pour.ColorRed, "Error announcing removal of locale", locale+":", err)
+	}
+	pour.LogColor(false, pour.ColorYellow, "Removed locale", locale)
+	return nil
+}
+
+// Sets the translation of a key in the file and the cache, unknown keys are added
+func SetTranslation(locale string, key string, val string) error {
+	if !validateLocale(locale) {
+		return ErrLocaleNotFound
+	}
+	err := updateTSFile(locale, func(entries map[string]string) bool {
+		if existing, ok := entries[key]; ok && existing == val {
+			return false
+		}
+		entries[key] = val
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	return PutTS(key, locale, val)
+}
+
+// Removes a key from the file and the cache of a locale, it's registered again the next time it's translated
+func DeleteTranslation(locale string, key string) error {
+	if !validateLocale(locale) {
+		return ErrLocaleNotFound
+	}
+	found := false
+	err := updateTSFile(locale, func(entries map[string]string) bool {
+		_, found = entries[key]
+		delete(entries, key)
+		return found
+	})
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrNotFound
+	}
+	return Del("translation_"+locale+"_", key)
+}
+
+// Writes many translations of a locale at once. With replace the locale ends up with exactly the given entries,
+// otherwise they are merged into the existing ones. Returns the number of entries written.
+func ImportTranslations(locale string, entries map[string]string, replace bool) (int, error) {
+	if !validateLocale(locale) {
+		return 0, ErrLocaleNotFound
+	}
+	written := map[string]string{}
+	err := updateTSFile(locale, func(existing map[string]string) bool {
+		if replace {
+			for key := range existing {
+				delete(existing, key)
+			}
+		}
+		for key, val := range entries {
+			existing[key] = val
+		}
+		for key, val := range existing {
+			written[key] = val
+		}
+		return true
+	})
+	if err != nil {
+		return 0, err
+	}
+	if replace {
+		if _, err := DelTSLocale(locale); err != nil {
+			pour.LogColor(false, pour.ColorRed, "Error removing cached translations of", locale+":", err)
+		}
+	}
+	if err := MPut("translation_"+locale+"_", written, 0); err != nil {
+		return len(entries), err
+	}
+	return len(entries), nil
+}
+
+// Completion of every valid locale, read from the translation files
+func TranslationStats() ([]LocaleStats, error) {
+	files, keys, err := readAllTSFiles()
+	if err != nil {
+		return nil, err
+	}
+	stats := []LocaleStats{}
+	for _, locale := range Locales() {
+		stats = append(stats, localeStats(locale, files[locale], keys))
+	}
+	return stats, nil
+}
+
+// Completion of one locale
+func TranslationStatsOf(locale string) (LocaleStats, error) {
+	if !validateLocale(locale) {
+		return LocaleStats{}, ErrLocaleNotFound
+	}
+	files, keys, err := readAllTSFiles()
+	if err != nil {
+		return LocaleStats{}, err
+	}
+	return localeStats(locale, files[locale], keys), nil
+}
+
+// Every key of the union of all locales with its value in locale, keys missing from the locale have an empty value
+func TranslationEntries(locale string) (map[string]string, error) {
+	if !validateLocale(locale) {
+		return nil, ErrLocaleNotFound
+	}
+	files, keys, err := readAllTSFiles()
+	if err != nil {
+		return nil, err
+	}
+	entries := make(map[string]string, len(keys))
+	for key := range keys {
+		entries[key] = files[locale][key]
+	}
+	return entries, nil
+}
+
+func localeStats(locale string, entries map[string]string, keys map[string]bool) LocaleStats {
+	stats := LocaleStats{Locale: locale, Default: IsDefaultLocale(locale), Keys: len(keys)}
+	for key := range keys {
+		if len(strings.TrimSpace(entries[key])) > 0 {
+			stats.Translated++
+		}
+	}
+	if stats.Keys > 0 {
+		stats.Completion = float64(stats.Translated*10000/stats.Keys) / 100
+	}
+	return stats
+}
+
+func allTSKeys() (map[string]bool, error) {
+	_, keys, err := readAllTSFiles()
+	return keys, err
+}
+
+// Reads the files of all valid locales along with the union of their keys
+func readAllTSFiles() (map[string]map[string]string, map[string]bool, error) {
+	cacheFileLock.RLock()
+	defer cacheFileLock.RUnlock()
+	files := map[string]map[string]string{}
+	keys := map[string]bool{}
+	for _, locale := range Locales() {
+		entries, err := readTSFile(locale)
+		if err != nil {
+			return nil, nil, err
+		}
+		files[locale] = entries
+		for key := range entries {
+			keys[key] = true
+		}
+	}
+	return files, keys, nil
+}
+
+// Merges the locales.json of the former localization bundle ({"de_DE": {"key": "value"}}, nested objects become
+// dotted keys) into the translation files. Values already translated in the files win, the file is renamed to
+// locales.json.migrated afterwards so the migration runs once.
+func migrateLegacyLocales(path string) error {
+	dat, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	content := map[string]interface{}{}
+	if err := json.Unmarshal(dat, &content); err != nil {
+		return err
+	}
+	migrated := 0
+	for locale, val := range content {
+		tree, ok := val.(map[string]interface{})
+		if !ok || !localePattern.MatchString(locale) {
+			pour.LogColor(false, pour.ColorYellow, "Skipping", locale, "in", path+", not a locale")
+			continue
+		}
+		entries := map[string]string{}
+		flattenLocales("", tree, entries)
+		merged := map[string]string{}
+		err := updateTSFile(locale, func(existing map[string]string) bool {
+			changed := false
+			for key, val := range entries {
+				if len(strings.TrimSpace(existing[key])) == 0 && (len(val) > 0 || !hasKey(existing, key)) {
+					existing[key] = val
+					changed = true
+				}
+			}
+			for key, val := range existing {
+				merged[key] = val
+			}
+			return changed
+		})
+		if err != nil {
+			return err
+		}
+		registerLocale(locale)
+		if err := MPut("translation_"+locale+"_", merged, 0); err != nil {
+			pour.LogColor(false, pour.ColorRed, "Error caching translations of", locale+":", err)
+		}
+		migrated += len(entries)
+	}
+	if err := os.Rename(path, path+".migrated"); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	pour.LogColor(false, pour.ColorYellow, "Migrated", migrated, "translations from", path, "into", translation_path)
+	return nil
+}
+
+func flattenLocales(prefix string, tree map[string]interface{}, entries map[string]string) {
+	for key, val := range tree {
+		switch typed := val.(type) {
+		case string:
+			entries[prefix+key] = typed
+		case map[string]interface{}:
+			flattenLocales(prefix+key+".", typed, entries)
+		}
+	}
+}
+
+func hasKey(entries map[string]string, key string) bool {
+	_, ok := entries[key]
+	return ok
+}
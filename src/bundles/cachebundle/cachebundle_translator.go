@@ -3,23 +3,74 @@ package cachebundle
 import (
 	"reflect"
 	"sync"
+
+	"github.com/sc-js/backend_core/src/tools"
 )
 
 var cacheFileLock = sync.RWMutex{}
 
+var messageType = reflect.TypeOf(tools.Message{})
+
 func TranslateStruct(transStruct reflect.Value, languageCode string) interface{} {
 	return translate(transStruct, languageCode)
 }
 
 func translate(obj reflect.Value, languageCode string) interface{} {
+	// First collect every string that needs a translation, so all of them are fetched in one batch read
+	found := map[string]bool{}
+	collectRecursive(obj, found, true)
+	keys := make([]string, 0, len(found))
+	for key := range found {
+		keys = append(keys, key)
+	}
+	translations := GetTSBatch(languageCode, keys)
+
 	// Wrap the original in a reflect.Value
 	copy := reflect.New(obj.Type()).Elem()
-	translateRecursive(copy, obj, languageCode, true)
+	translateRecursive(copy, obj, translations, languageCode, true)
 	// Remove the reflection wrapper
 	return copy
 }
 
-func translateRecursive(copy, original reflect.Value, languageCode string, doTranslate bool) {
+// Walks the value like translateRecursive does and gathers the strings it would translate
+func collectRecursive(original reflect.Value, keys map[string]bool, doTranslate bool) {
+	switch original.Kind() {
+	case reflect.Ptr:
+		if original.Elem().IsValid() {
+			collectRecursive(original.Elem(), keys, doTranslate)
+		}
+	case reflect.Interface:
+		if original.Elem().IsValid() && !original.Elem().IsZero() {
+			collectRecursive(original.Elem(), keys, doTranslate)
+		}
+	case reflect.Struct:
+		if original.Type().Name() == "Time" {
+			return
+		}
+		if original.Type() == messageType && original.CanInterface() {
+			keys[original.Interface().(tools.Message).Key] = true
+			return
+		}
+		for i := 0; i < original.NumField(); i += 1 {
+			_, ok := original.Type().Field(i).Tag.Lookup("trans")
+			collectRecursive(original.Field(i), keys, ok)
+		}
+	case reflect.Slice:
+		for i := 0; i < original.Len(); i += 1 {
+			collectRecursive(original.Index(i), keys, doTranslate)
+		}
+	case reflect.Map:
+		for _, key := range original.MapKeys() {
+			collectRecursive(original.MapIndex(key), keys, doTranslate)
+		}
+	case reflect.String:
+		if doTranslate && original.String() != "" {
+			keys[original.String()] = true
+		}
+	}
+}
+
+func translateRecursive(copy, original reflect.Value, translations map[string]string, languageCode string, doTranslate bool) {
 	switch original.Kind() {
 	// The first cases handle nested structures and translate them recursively
 
@@ -37,7 +88,7 @@ func translateRecursive(copy, original reflect.Value, languageCode string, doTra
 		if copy.CanSet() {
 			copy.Set(reflect.New(originalValue.Type()))
 			// Unwrap the newly created pointer
-			translateRecursive(copy.Elem(), originalValue, languageCode, doTranslate)
+			translateRecursive(copy.Elem(), originalValue, translations, languageCode, doTranslate)
 		}
 
 	// If it is an interface (which is very similar to a pointer), do basically the
@@ -51,7 +102,7 @@ func translateRecursive(copy, original reflect.Value, languageCode string, doTra
 		// points to, so we have to call Elem() to unwrap it
 		if originalValue.IsValid() && !originalValue.IsZero() {
 			copyValue := reflect.New(originalValue.Type()).Elem()
-			translateRecursive(copyValue, originalValue, languageCode, doTranslate)
+			translateRecursive(copyValue, originalValue, translations, languageCode, doTranslate)
 			copy.Set(copyValue)
 		}
 
@@ -61,17 +112,23 @@ func translateRecursive(copy, original reflect.Value, languageCode string, doTra
 			copy.Set(original)
 			return
 		}
+		// Messages are always translated, with their arguments
+		if original.Type() == messageType && original.CanInterface() {
+			msg := original.Interface().(tools.Message)
+			copy.Set(reflect.ValueOf(msg.Localize(languageCode, translations[msg.Key])))
+			return
+		}
 		val := reflect.Indirect(original)
 		for i := 0; i < original.NumField(); i += 1 {
 			_, ok := val.Type().Field(i).Tag.Lookup("trans")
-			translateRecursive(copy.Field(i), original.Field(i), languageCode, ok)
+			translateRecursive(copy.Field(i), original.Field(i), translations, languageCode, ok)
 		}
 
 	// If it is a slice we create a new slice and translate each element
 	case reflect.Slice:
 		copy.Set(reflect.MakeSlice(original.Type(), original.Len(), original.Cap()))
 		for i := 0; i < original.Len(); i += 1 {
-			translateRecursive(copy.Index(i), original.Index(i), languageCode, doTranslate)
+			translateRecursive(copy.Index(i), original.Index(i), translations, languageCode, doTranslate)
 		}
 
 	// If it is a map we create a new map and translate each value
@@ -81,7 +138,7 @@ func translateRecursive(copy, original reflect.Value, languageCode string, doTra
 			originalValue := original.MapIndex(key)
 			// New gives us a pointer, but again we want the value
 			copyValue := reflect.New(originalValue.Type()).Elem()
-			translateRecursive(copyValue, originalValue, languageCode, doTranslate)
+			translateRecursive(copyValue, originalValue, translations, languageCode, doTranslate)
 			copy.SetMapIndex(key, copyValue)
 		}
 
@@ -90,20 +147,12 @@ func translateRecursive(copy, original reflect.Value, languageCode string, doTra
 	// If it is a string translate it (yay finally we're doing what we came for)
 	case reflect.String:
 		if doTranslate {
-			value := original.Interface().(string)
-			if value != "" {
-				trans, err := GetTS(languageCode, value)
-				if err != nil {
-					copy.SetString(value)
-					WriteNewTSEntry(languageCode, value)
-				} else {
-					if len(trans) > 0 {
-						copy.SetString(trans)
-						return
-					}
-					copy.SetString(value)
-				}
+			value := original.String()
+			if trans := translations[value]; len(trans) > 0 {
+				copy.SetString(trans)
+				return
 			}
+			copy.SetString(value)
 
 		} else {
 			copy.Set(original)
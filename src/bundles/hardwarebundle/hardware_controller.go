@@ -1,8 +1,12 @@
 package hardwarebundle
 
 import (
+	"context"
+
 	"github.com/sc-js/backend_core/src/bundles/deepcorebundle"
+	"github.com/sc-js/backend_core/src/bundles/jobbundle"
 	"github.com/sc-js/backend_core/src/tools"
+	"github.com/sc-js/pour"
 )
 
 type hardwareController struct {
@@ -23,6 +27,11 @@ func handleSettings(settings map[string]string, wrap *tools.DataWrap) {
 		return
 	}
 	if settings["polling"] == "true" {
-		go pollUsage(wrap.DB)
+		err := jobbundle.Schedule("hardware_poll", "@every 15m", func(ctx context.Context) error {
+			return sampleUsage(wrap.DB)
+		})
+		if err != nil {
+			pour.LogErr(err)
+		}
 	}
 }
@@ -5,16 +5,14 @@ import (
 	"net/http"
 	"runtime"
 	"strconv"
-	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jaypipes/ghw"
+	"github.com/sc-js/backend_core/src/bundles/cachebundle"
 	"github.com/sc-js/backend_core/src/tools"
+	"github.com/sc-js/pour"
 )
 
-var hwCache hardwareInformation
-var hwExpire = time.Now()
-
 func (con *hardwareController) getHardwareUsageHandler(c *gin.Context) {
 	tools.GetPagedAndSend[hardwareUsage](c, con.DataWrap.DB)
 }
@@ -25,50 +23,54 @@ func (con *hardwareController) getHardwareConfigurationHandler(c *gin.Context) {
 		return
 	}
 
-	inv, err := strconv.ParseBool(c.Request.URL.Query().Get("invalidate"))
-	if err != nil {
-		inv = false
-	}
+	// Responses are cached by the route for 30 minutes, send ?invalidate=true or "Cache-Control: no-cache" to refresh
+	hw := hardwareInformation{}
 
-	if hwExpire.Before(time.Now()) || inv {
-		hwCache = hardwareInformation{}
+	if data, err := ghw.CPU(); err == nil {
+		hw.CPU = *data
+	}
+	if data, err := ghw.GPU(); err == nil {
+		hw.GPU = *data
+	}
+	if data, err := ghw.Memory(); err == nil {
+		hw.Memory = *data
+	}
+	if data, err := ghw.Block(); err == nil {
+		hw.Storage = *data
+	}
+	if data, err := ghw.Network(); err == nil {
+		hw.Network = *data
+	}
+	if data, err := ghw.Topology(); err == nil {
+		hw.Topology = *data
+	}
+	if data, err := ghw.BIOS(); err == nil {
+		hw.Bios = *data
+	}
+	if data, err := ghw.PCI(); err == nil {
+		hw.PCI = *data
+	}
+	if data, err := ghw.Baseboard(); err == nil {
+		hw.Baseboard = *data
+	}
+	if data, err := ghw.Chassis(); err == nil {
+		hw.Chassis = *data
+	}
+	if data, err := ghw.Product(); err == nil {
+		hw.Product = *data
+	}
 
-		if data, err := ghw.CPU(); err == nil {
-			hwCache.CPU = *data
-		}
-		if data, err := ghw.GPU(); err == nil {
-			hwCache.GPU = *data
-		}
-		if data, err := ghw.Memory(); err == nil {
-			hwCache.Memory = *data
-		}
-		if data, err := ghw.Block(); err == nil {
-			hwCache.Storage = *data
-		}
-		if data, err := ghw.Network(); err == nil {
-			hwCache.Network = *data
-		}
-		if data, err := ghw.Topology(); err == nil {
-			hwCache.Topology = *data
-		}
-		if data, err := ghw.BIOS(); err == nil {
-			hwCache.Bios = *data
-		}
-		if data, err := ghw.PCI(); err == nil {
-			hwCache.PCI = *data
-		}
-		if data, err := ghw.Baseboard(); err == nil {
-			hwCache.Baseboard = *data
-		}
-		if data, err := ghw.Chassis(); err == nil {
-			hwCache.Chassis = *data
-		}
-		if data, err := ghw.Product(); err == nil {
-			hwCache.Product = *data
-		}
+	tools.RespondWithJSON(c, http.StatusOK, hw)
+}
 
-		hwExpire = time.Now().Add(time.Minute * 30)
+// Drops the cached hardware configuration on ?invalidate=true, so the request reads it again
+func invalidateRequested(c *gin.Context) bool {
+	inv, err := strconv.ParseBool(c.Request.URL.Query().Get("invalidate"))
+	if err != nil || !inv {
+		return false
 	}
-
-	tools.RespondWithJSON(c, http.StatusOK, hwCache)
+	if err := cachebundle.InvalidateResponses("hardware"); err != nil {
+		pour.LogColor(false, pour.ColorYellow, "Could not invalidate the hardware configuration:", err)
+	}
+	return true
 }
@@ -2,6 +2,7 @@ package hardwarebundle
 
 import (
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	t "github.com/sc-js/backend_core/src/tools"
@@ -13,7 +14,7 @@ func InitBundle(r *gin.RouterGroup, wrap *t.DataWrap, autoMigrate bool, settings
 	controller := initialize(wrap, autoMigrate, settings)
 
 	routes = []t.GinRoute{
-		{Method: http.MethodGet, Endpoint: "/hardware/configuration", Handler: controller.getHardwareConfigurationHandler, Permission: t.PERM_ADMIN},
+		{Method: http.MethodGet, Endpoint: "/hardware/configuration", Handler: controller.getHardwareConfigurationHandler, Permission: t.PERM_ADMIN, CacheTTL: time.Minute * 30, CacheTags: []string{"hardware"}, CacheBypass: invalidateRequested},
 		{Method: http.MethodGet, Endpoint: "/hardware/usage", Handler: controller.getHardwareUsageHandler, Permission: t.PERM_ADMIN},
 	}
 
@@ -9,34 +9,31 @@ import (
 	"gorm.io/gorm"
 )
 
-func pollUsage(db *gorm.DB) {
-	for {
-		hw := hardwareUsage{}
-		memory, err := memory.Get()
-		if err == nil {
-			time.Sleep(time.Second)
-			hw.MemoryTotal = memory.Total
-			hw.MemoryFree = memory.Free
-			hw.MemoryUsed = memory.Used
-		}
+// Samples CPU and memory usage and deletes samples older than a day. Scheduled as the "hardware_poll" task,
+// so a cluster writes one sample per interval.
+func sampleUsage(db *gorm.DB) error {
+	hw := hardwareUsage{}
+	memory, err := memory.Get()
+	if err == nil {
+		time.Sleep(time.Second)
+		hw.MemoryTotal = memory.Total
+		hw.MemoryFree = memory.Free
+		hw.MemoryUsed = memory.Used
+	}
 
-		before, err := cpu.Get()
+	before, err := cpu.Get()
+	if err == nil {
+		time.Sleep(time.Second)
+		after, err := cpu.Get()
 		if err == nil {
-			time.Sleep(time.Second)
-			after, err := cpu.Get()
-			if err == nil {
-				total := float64(after.Total - before.Total)
-				hw.CPUIdle = float64(after.Idle-before.Idle) / total * 100
-				hw.CPUSystem = float64(after.System-before.System) / total * 100
-				hw.CPUUser = float64(after.User-before.User) / total * 100
-			}
+			total := float64(after.Total - before.Total)
+			hw.CPUIdle = float64(after.Idle-before.Idle) / total * 100
+			hw.CPUSystem = float64(after.System-before.System) / total * 100
+			hw.CPUUser = float64(after.User-before.User) / total * 100
 		}
-
-		db.Unscoped().Delete(&hardwareUsage{}, "created_at < ?", time.Now().Add(-time.Hour*24))
-		pour.LogColor(true, pour.ColorYellow, "CPU:[", "User:", hw.CPUUser, "% Idle:", hw.CPUIdle, "% System", hw.CPUSystem, "% ] - MEM:[", "Total", hw.MemoryTotal, "Free", hw.MemoryFree, "Used", hw.MemoryUsed, "]")
-		if err := db.Create(&hw).Error; err != nil {
-			pour.LogErr(err)
-		}
-		time.Sleep(time.Minute * 15)
 	}
+
+	db.Unscoped().Delete(&hardwareUsage{}, "created_at < ?", time.Now().Add(-time.Hour*24))
+	pour.LogColor(true, pour.ColorYellow, "CPU:[", "User:", hw.CPUUser, "% Idle:", hw.CPUIdle, "% System", hw.CPUSystem, "% ] - MEM:[", "Total", hw.MemoryTotal, "Free", hw.MemoryFree, "Used", hw.MemoryUsed, "]")
+	return db.Create(&hw).Error
 }
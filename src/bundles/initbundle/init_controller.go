@@ -16,10 +16,12 @@ import (
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
+	"github.com/sc-js/backend_core/src/bundles/auditbundle"
 	"github.com/sc-js/backend_core/src/bundles/authbundle"
 	"github.com/sc-js/backend_core/src/bundles/cachebundle"
 	"github.com/sc-js/backend_core/src/bundles/deepcorebundle"
-	"github.com/sc-js/backend_core/src/bundles/localizationbundle"
+	"github.com/sc-js/backend_core/src/bundles/jobbundle"
+	"github.com/sc-js/backend_core/src/bundles/mailbundle"
 	"github.com/sc-js/backend_core/src/mongowrap"
 	"github.com/sc-js/backend_core/src/tools"
 	"github.com/sc-js/pour"
@@ -68,29 +70,100 @@ func InitializeCoreWithBundles(bundles []Bundle, conf *InitConfiguration) {
 
 	deepcorebundle.Init(wrap.DB, autoMigrate)
 
-	localizationbundle.InitLocales()
-
 	//HTTP Router
 	gin.SetMode(initConf.GinMode)
 	r = gin.New()
-	r.Use(cors.New(cors.Config{AllowAllOrigins: true, AllowCredentials: true, AllowMethods: []string{http.MethodGet, http.MethodPost, http.MethodDelete, http.MethodPut, http.MethodPatch, http.MethodHead, http.MethodOptions}, AllowHeaders: []string{"Access-Control-Request-Headers", "Access-Control-Allow-Headers", "Content-Type", "Content-Length", "Accept-Encoding", "X-CSRF-Token", "Authorization", "Accept", "Origin", "Accept-Language", "Cache-Control", "X-Requested-With", "X-LOCALE"}}))
+	corsConfig := cors.Config{AllowAllOrigins: true, AllowCredentials: true, AllowMethods: []string{http.MethodGet, http.MethodPost, http.MethodDelete, http.MethodPut, http.MethodPatch, http.MethodHead, http.MethodOptions}, AllowHeaders: []string{"Access-Control-Request-Headers", "Access-Control-Allow-Headers", "Content-Type", "Content-Length", "Accept-Encoding", "X-CSRF-Token", "Authorization", "Accept", "Origin", "Accept-Language", "Cache-Control", "X-Requested-With", "X-LOCALE", "X-SESSION-MODE", tools.HEADER_REQUEST_ID}, ExposeHeaders: []string{tools.HEADER_REQUEST_ID}}
+	// Browsers refuse credentialed requests against a wildcard origin, so cookie sessions need explicit origins
+	if len(SystemConfig.Session.AllowedOrigins) > 0 {
+		corsConfig.AllowAllOrigins = false
+		corsConfig.AllowOrigins = SystemConfig.Session.AllowedOrigins
+	}
+	r.Use(cors.New(corsConfig))
 	r.SetTrustedProxies(nil)
+	r.Use(tools.RequestIDMiddleware())
 	r.MaxMultipartMemory = initConf.MaxMultipartMemory << 20
 	gr = r.Group("")
 	//gr.Use(timeoutMiddleware())
+	gr.Use(authbundle.CSRFMiddleware())
 	gr.Use(authbundle.AuthMiddleware(wrap.DB))
 	wsr = r.Group("/ws")
 
+	//Locale negotiation, needed before translations are read
+	tools.SetDefaultLocale(SystemConfig.Localization.DefaultLocale)
+	tools.SetLocaleFallbacks(SystemConfig.Localization.Fallbacks)
+
 	//Cache Engine
-	switch strings.ToLower(SystemConfig.Cache.CacheEngine) {
-	case ("aerospike"):
-		cachebundle.InitCache(cachebundle.AeroSpike, SystemConfig.Cache.Address, SystemConfig.Cache.PortOverride, SystemConfig.Cache.Username, SystemConfig.Cache.Password, SystemConfig.Cache.Workspace)
-	case ("redis"):
-		cachebundle.InitCache(cachebundle.Redis, SystemConfig.Cache.Address, SystemConfig.Cache.PortOverride, SystemConfig.Cache.Username, SystemConfig.Cache.Password, SystemConfig.Cache.Workspace)
+	engine := strings.ToLower(SystemConfig.Cache.CacheEngine)
+	// Sessions and locks live in the cache, a silent per-process fallback would break them across replicas,
+	// so the memory engine has to be chosen explicitly
+	switch engine {
+	case "":
+		pour.LogPanicKill(1, errors.New("no cache engine configured, set cache.cache_engine to aerospike, redis or memory (single node only)"))
+	case cachebundle.ENGINE_MEMORY:
+		pour.LogColor(false, pour.ColorYellow, "Using the in-memory cache, sessions and locks are not shared with other nodes")
+	}
+	startupTimeout, _ := time.ParseDuration(SystemConfig.Cache.StartupTimeout)
+	breakerCooldown, _ := time.ParseDuration(SystemConfig.Cache.BreakerCooldown)
+	var l1Policies map[string]time.Duration
+	if SystemConfig.Cache.L1Policies != nil {
+		l1Policies = map[string]time.Duration{}
+		for name, ttl := range SystemConfig.Cache.L1Policies {
+			parsed, err := time.ParseDuration(ttl)
+			if err != nil {
+				pour.LogColor(false, pour.ColorRed, "Invalid L1 policy for", name+":", err)
+				continue
+			}
+			l1Policies[name] = parsed
+		}
+	}
+	cachebundle.InitCacheWithConfig(engine, cachebundle.EngineConfig{
+		Address:       SystemConfig.Cache.Address,
+		PortOverride:  SystemConfig.Cache.PortOverride,
+		Username:      SystemConfig.Cache.Username,
+		Password:      SystemConfig.Cache.Password,
+		Workspace:     SystemConfig.Cache.Workspace,
+		MaxEntries:    SystemConfig.Cache.MaxEntries,
+		Codec:         SystemConfig.Cache.Codec,
+		Addresses:     SystemConfig.Cache.Addresses,
+		DB:            SystemConfig.Cache.DB,
+		MasterName:    SystemConfig.Cache.MasterName,
+		Cluster:       SystemConfig.Cache.Cluster,
+		TLS:           SystemConfig.Cache.TLS,
+		TLSSkipVerify: SystemConfig.Cache.TLSSkipVerify,
+
+		StartupPolicy:    strings.ToLower(SystemConfig.Cache.StartupPolicy),
+		StartupTimeout:   startupTimeout,
+		BreakerThreshold: SystemConfig.Cache.BreakerThreshold,
+		BreakerCooldown:  breakerCooldown,
+		L1MaxEntries:     SystemConfig.Cache.L1MaxEntries,
+		L1Policies:       l1Policies,
+
+		ScheduledTranslationRefresh: true,
+		// Sessions written before keys were namespaced, so upgrading doesn't log everyone out
+		LegacyNames: []string{"user_session"},
+	})
+	if err := cachebundle.RegisterGormInvalidation(wrap.DB); err != nil {
+		pour.LogErr(err)
+	}
+	cachebundle.InitRoutes(gr)
+
+	//Mail, used for passwordless logins and notifications
+	mailbundle.InitMailer(SystemConfig.Mail.Host, SystemConfig.Mail.Port, SystemConfig.Mail.Username, SystemConfig.Mail.Password, SystemConfig.Mail.From)
+
+	//Audit log, needed by the auth bundle
+	auditbundle.InitBundle(getBundleRequirements(map[string]string{"storage": SystemConfig.Audit.Storage}))
+
+	//Job queue, used for mails and by external bundles
+	jobbundle.InitBundle(getBundleRequirements(jobSettings(SystemConfig.Jobs)))
+	if err := jobbundle.Schedule("translation_refresh", "@every 1m", func(ctx context.Context) error {
+		return cachebundle.RefreshTranslations()
+	}); err != nil {
+		pour.LogErr(err)
 	}
 
 	pour.LogColor(false, pour.ColorBlue, "Initializing", len(bundles), "external bundle(s)..")
-	bundleNames := []string{"auth"}
+	bundleNames := []string{"auth", "audit", "job"}
 	for _, element := range bundles {
 		bundleName := strings.Split(tools.GetPackageName(element.Handler), "bundle")[0]
 		bundleNames = append(bundleNames, bundleName)
@@ -126,6 +199,23 @@ func handleInitConf(c *InitConfiguration) {
 	}
 }
 
+func jobSettings(config Jobs) map[string]string {
+	settings := map[string]string{"poll_interval": config.PollInterval, "timeout": config.Timeout, "retention": config.Retention, "payload_secret": config.PayloadSecret}
+	if config.Workers > 0 {
+		settings["workers"] = fmt.Sprint(config.Workers)
+	}
+	if config.DisableWorkers {
+		settings["workers"] = "0"
+	}
+	if config.MaxAttempts > 0 {
+		settings["max_attempts"] = fmt.Sprint(config.MaxAttempts)
+	}
+	for name, spec := range config.Schedules {
+		settings["schedule."+name] = spec
+	}
+	return settings
+}
+
 func getBundleRequirements(settings map[string]string) (*gin.RouterGroup, *tools.DataWrap, bool, map[string]string) {
 	return gr, wrap, autoMigrate, settings
 }
@@ -153,6 +243,17 @@ func setupSettings(settings map[string]string) map[string]string {
 	}
 	settings["jwt_secret"] = SystemConfig.JWTSecret
 	settings["jwt_refresh_secret"] = SystemConfig.JWTRefreshSecret
+	settings["session_cookies"] = fmt.Sprint(SystemConfig.Session.Cookies)
+	settings["cookie_domain"] = SystemConfig.Session.Domain
+	settings["cookie_same_site"] = SystemConfig.Session.SameSite
+	settings["service_token_ttl"] = SystemConfig.Auth.ServiceTokenTTL
+	settings["impersonation_ttl"] = SystemConfig.Auth.ImpersonationTTL
+	settings["erasure_grace_period"] = SystemConfig.Auth.ErasureGracePeriod
+	settings["passwordless"] = fmt.Sprint(SystemConfig.Auth.Passwordless)
+	settings["magic_link_url"] = SystemConfig.Auth.MagicLinkURL
+	settings["passwordless_ttl"] = SystemConfig.Auth.PasswordlessTTL
+	settings["registration"] = SystemConfig.Auth.Registration
+	settings["invite_url"] = SystemConfig.Auth.InviteURL
 
 	if len(SystemConfig.JWTSecret) == 0 || len(SystemConfig.JWTRefreshSecret) == 0 {
 		pour.LogPanicKill(1, errors.New("JWT secret or JWT refresh secret was empty, please check config"))
@@ -276,11 +377,9 @@ func readConfig() {
 	SystemConfig = config
 }
 
+// The cache workspace is left empty if not configured: Aerospike falls back to its default namespace
+// and Redis keeps keys unprefixed, as they were before workspaces existed.
 func putDefaultConfigValues(config Config) Config {
-	if len(config.Cache.Workspace) == 0 {
-		config.Cache.Workspace = cachebundle.AerospikeDefaultWorkspace
-	}
-
 	return config
 }
 
@@ -10,15 +10,21 @@ type InitConfiguration struct {
 }
 
 type Config struct {
-	AutoMigrate      bool      `json:"auto_migrate"`
-	Database         Database  `json:"database"`
-	Cache            Cache     `json:"cache"`
-	Server           Server    `json:"server"`
-	LogServer        LogServer `json:"logserver"`
-	Mongo            Mongo     `json:"mongo"`
-	Salt             string    `json:"salt"`
-	JWTSecret        string    `json:"jwt_secret"`
-	JWTRefreshSecret string    `json:"jwt_refresh_secret"`
+	AutoMigrate      bool         `json:"auto_migrate"`
+	Database         Database     `json:"database"`
+	Cache            Cache        `json:"cache"`
+	Server           Server       `json:"server"`
+	LogServer        LogServer    `json:"logserver"`
+	Mongo            Mongo        `json:"mongo"`
+	Audit            Audit        `json:"audit"`
+	Session          Session      `json:"session"`
+	Auth             Auth         `json:"auth"`
+	Mail             Mail         `json:"mail"`
+	Jobs             Jobs         `json:"jobs"`
+	Localization     Localization `json:"localization"`
+	Salt             string       `json:"salt"`
+	JWTSecret        string       `json:"jwt_secret"`
+	JWTRefreshSecret string       `json:"jwt_refresh_secret"`
 }
 
 type Mongo struct {
@@ -51,6 +57,7 @@ type LogServer struct {
 	ClientKey   string `json:"client_key"`
 }
 
+// CacheEngine is one of aerospike, redis, memory or the name of an engine registered with cachebundle.RegisterEngine
 type Cache struct {
 	CacheEngine  string `json:"cache_engine"`
 	Address      string `json:"address"`
@@ -58,4 +65,76 @@ type Cache struct {
 	Username     string `json:"username"`
 	Password     string `json:"password"`
 	Workspace    string `json:"workspace"`
+	MaxEntries   int    `json:"max_entries"`
+	Codec        string `json:"codec"`
+	// Redis: seed addresses (host:port) for sentinel/cluster, database index, sentinel master name and TLS
+	Addresses     []string `json:"addresses"`
+	DB            int      `json:"db"`
+	MasterName    string   `json:"master_name"`
+	Cluster       bool     `json:"cluster"`
+	TLS           bool     `json:"tls"`
+	TLSSkipVerify bool     `json:"tls_skip_verify"`
+	// fail, degrade or wait if the engine is unreachable on startup, durations like "30s"
+	StartupPolicy    string `json:"startup_policy"`
+	StartupTimeout   string `json:"startup_timeout"`
+	BreakerThreshold int    `json:"breaker_threshold"`
+	BreakerCooldown  string `json:"breaker_cooldown"`
+	// In-process tier in front of redis/aerospike, policies map cache names (trailing * for prefixes) to durations
+	L1MaxEntries int               `json:"l1_max_entries"`
+	L1Policies   map[string]string `json:"l1_policies"`
+}
+
+// Workers is the number of job workers on this node (default 4), DisableWorkers makes the node only enqueue jobs.
+// Durations like "1s", Retention applies to finished jobs.
+type Jobs struct {
+	Workers        int    `json:"workers"`
+	DisableWorkers bool   `json:"disable_workers"`
+	MaxAttempts    int    `json:"max_attempts"`
+	PollInterval   string `json:"poll_interval"`
+	Timeout        string `json:"timeout"`
+	Retention      string `json:"retention"`
+	// Encrypts sealed job payloads (e.G. mails with login codes), must be the same on all nodes. Derived from the hashid salt if empty.
+	PayloadSecret string `json:"payload_secret"`
+	// Replaces the schedule of a task by name, e.G. {"hardware_poll": "*/5 * * * *"}
+	Schedules map[string]string `json:"schedules"`
+}
+
+// DefaultLocale is used when a request matches no locale (default en_EN). Fallbacks list the parents of a locale
+// for missing translations, e.G. {"de_CH": ["de_DE"]}, locales without one fall back to the main locale of their language.
+type Localization struct {
+	DefaultLocale string              `json:"default_locale"`
+	Fallbacks     map[string][]string `json:"fallbacks"`
+}
+
+type Audit struct {
+	Storage string `json:"storage"`
+}
+
+// Optional cookie based browser sessions, bearer tokens keep working regardless
+type Session struct {
+	Cookies        bool     `json:"cookies"`
+	Domain         string   `json:"domain"`
+	SameSite       string   `json:"same_site"`
+	AllowedOrigins []string `json:"allowed_origins"`
+}
+
+type Auth struct {
+	ServiceTokenTTL    string `json:"service_token_ttl"`
+	ImpersonationTTL   string `json:"impersonation_ttl"`
+	ErasureGracePeriod string `json:"erasure_grace_period"`
+	Passwordless       bool   `json:"passwordless"`
+	MagicLinkURL       string `json:"magic_link_url"`
+	PasswordlessTTL    string `json:"passwordless_ttl"`
+	// open (default), invite or closed
+	Registration string `json:"registration"`
+	InviteURL    string `json:"invite_url"`
+}
+
+// Outgoing mail, without a host mails are only written to the log
+type Mail struct {
+	Host     string `json:"host"`
+	Port     uint   `json:"port"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	From     string `json:"from"`
 }
@@ -0,0 +1,139 @@
+package jobbundle
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sc-js/backend_core/src/bundles/deepcorebundle"
+	"github.com/sc-js/backend_core/src/tools"
+)
+
+type jobController struct {
+	deepcorebundle.Controller
+	DataWrap *tools.DataWrap
+}
+
+type jobHandler struct {
+	run     func(ctx context.Context, payload string) error
+	options HandlerOptions
+}
+
+var controller *jobController
+
+var (
+	workers        = 4
+	pollInterval   = time.Second
+	defaultTimeout = 5 * time.Minute
+	defaultBackoff = 10 * time.Second
+	maxBackoff     = time.Hour
+	maxAttempts    = 5
+	// Done jobs and task runs are deleted after this, dead jobs are kept until purged
+	retention = 7 * 24 * time.Hour
+	// Key material of sealed payloads, the hashid salt is used if empty
+	payloadSecret = ""
+)
+
+var (
+	handlers    = map[string]jobHandler{}
+	handlerLock sync.RWMutex
+)
+
+func initialize(wrap *tools.DataWrap, settings map[string]string) *jobController {
+	c := &jobController{Controller: deepcorebundle.Controller{}, DataWrap: wrap}
+	deepcorebundle.RegisterModel(Job{}, []string{"created_at", "run_at", "priority", "status", "type", "attempts"})
+	deepcorebundle.RegisterModel(ScheduledTask{}, []string{"name"})
+	deepcorebundle.RegisterModel(TaskRun{}, []string{"started_at", "duration_ms", "success"})
+	handleSettings(settings)
+	controller = c
+	return c
+}
+
+func handleSettings(settings map[string]string) {
+	if settings == nil {
+		return
+	}
+	if n, err := strconv.Atoi(settings["workers"]); err == nil && n >= 0 {
+		workers = n
+	}
+	if n, err := strconv.Atoi(settings["max_attempts"]); err == nil && n > 0 {
+		maxAttempts = n
+	}
+	if interval, err := time.ParseDuration(settings["poll_interval"]); err == nil && interval > 0 {
+		pollInterval = interval
+	}
+	if timeout, err := time.ParseDuration(settings["timeout"]); err == nil && timeout > 0 {
+		defaultTimeout = timeout
+	}
+	if keep, err := time.ParseDuration(settings["retention"]); err == nil && keep > 0 {
+		retention = keep
+	}
+	payloadSecret = settings["payload_secret"]
+	for key, spec := range settings {
+		if name, ok := strings.CutPrefix(key, "schedule."); ok && len(spec) > 0 {
+			specOverrides[name] = spec
+		}
+	}
+}
+
+// Registers the handler for jobs of jobType, payloads are decoded from JSON into T.
+// Nodes only pick up jobs they have a handler for, so handlers should be registered before InitBundle or right after.
+// Returning an error retries the job with backoff, errors wrapped with Permanent move it to the dead-letter state immediately.
+func Handle[T any](jobType string, handler func(ctx context.Context, payload T) error, options ...HandlerOptions) {
+	h := jobHandler{run: func(ctx context.Context, payload string) error {
+		var val T
+		if err := json.Unmarshal([]byte(payload), &val); err != nil {
+			return Permanent(err)
+		}
+		return handler(ctx, val)
+	}}
+	if len(options) > 0 {
+		h.options = options[0]
+	}
+	handlerLock.Lock()
+	handlers[jobType] = h
+	handlerLock.Unlock()
+	wake()
+}
+
+func getHandler(jobType string) (jobHandler, bool) {
+	handlerLock.RLock()
+	defer handlerLock.RUnlock()
+	h, ok := handlers[jobType]
+	return h, ok
+}
+
+func handledTypes() []string {
+	handlerLock.RLock()
+	defer handlerLock.RUnlock()
+	types := make([]string, 0, len(handlers))
+	for jobType := range handlers {
+		types = append(types, jobType)
+	}
+	return types
+}
+
+func (h jobHandler) timeout() time.Duration {
+	if h.options.Timeout > 0 {
+		return h.options.Timeout
+	}
+	return defaultTimeout
+}
+
+// Delay before the next attempt, doubles from the handler backoff and is capped at an hour
+func (h jobHandler) backoff(attempts int) time.Duration {
+	delay := defaultBackoff
+	if h.options.Backoff > 0 {
+		delay = h.options.Backoff
+	}
+	for i := 1; i < attempts && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxBackoff {
+		delay = maxBackoff
+	}
+	return delay
+}
@@ -0,0 +1,66 @@
+package jobbundle
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseScheduleNext(t *testing.T) {
+	// 2024-06-01 is a Saturday
+	after := time.Date(2024, 6, 1, 10, 7, 30, 0, time.UTC)
+	tests := []struct {
+		spec string
+		want time.Time
+	}{
+		{"*/15 * * * *", time.Date(2024, 6, 1, 10, 15, 0, 0, time.UTC)},
+		{"5/20 * * * *", time.Date(2024, 6, 1, 10, 25, 0, 0, time.UTC)},
+		{"7 * * * *", time.Date(2024, 6, 1, 11, 7, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2024, 6, 1, 11, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2024, 6, 2, 0, 0, 0, 0, time.UTC)},
+		{"@every 15m", time.Date(2024, 6, 1, 10, 15, 0, 0, time.UTC)},
+		{"0 9 * * mon-fri", time.Date(2024, 6, 3, 9, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2024, 6, 2, 0, 0, 0, 0, time.UTC)},
+		{"30 2 1 * *", time.Date(2024, 7, 1, 2, 30, 0, 0, time.UTC)},
+		{"0 12 * JAN,jul *", time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)},
+		// Both day fields restricted, either of them matches
+		{"0 0 13 * fri", time.Date(2024, 6, 7, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 31 2 *", time.Time{}},
+	}
+	for _, tt := range tests {
+		s, err := parseSchedule(tt.spec)
+		if err != nil {
+			t.Errorf("parseSchedule(%q): %v", tt.spec, err)
+			continue
+		}
+		if got := s.next(after); !got.Equal(tt.want) {
+			t.Errorf("parseSchedule(%q).next = %v, want %v", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestParseScheduleInvalid(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"*/x * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+		"* * * foo *",
+		"@every",
+		"@every x",
+		"@every -1m",
+		"@weekdays",
+	} {
+		if _, err := parseSchedule(spec); err == nil {
+			t.Errorf("parseSchedule(%q): expected an error", spec)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package jobbundle
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sc-js/backend_core/src/tools"
+	"gorm.io/gorm"
+)
+
+// Paged job listing, supports the query filters status and type. Newest jobs come first unless an order is given.
+func (con *jobController) getJobsHandler(c *gin.Context) {
+	db := filterJobs(con.DataWrap.DB, c)
+	if len(c.Request.URL.Query()["order"]) == 0 {
+		db = db.Order("created_at DESC")
+	}
+	tools.GetPagedAndSend[Job](c, db)
+}
+
+func (con *jobController) getJobHandler(c *gin.Context) {
+	job, err := tools.GetSingleById[Job](c, con.DataWrap.DB)
+	if err != nil {
+		tools.RespondError(errors.New("not_found"), http.StatusNotFound, c)
+		return
+	}
+	tools.RespondWithJSON(c, http.StatusOK, &job)
+}
+
+// Number of jobs per type and status
+func (con *jobController) getStatsHandler(c *gin.Context) {
+	stats := []jobStats{}
+	if err := con.DataWrap.DB.Model(&Job{}).Select("type, status, COUNT(*) AS count").Group("type, status").Order("type, status").Scan(&stats).Error; err != nil {
+		tools.RespondError(err, http.StatusBadRequest, c)
+		return
+	}
+	tools.RespondWithJSON(c, http.StatusOK, &stats)
+}
+
+// Puts a dead or pending job back into the queue to run right away, with its attempts reset
+func (con *jobController) retryJobHandler(c *gin.Context) {
+	job, err := tools.GetSingleById[Job](c, con.DataWrap.DB)
+	if err != nil {
+		tools.RespondError(errors.New("not_found"), http.StatusNotFound, c)
+		return
+	}
+	if job.Status == STATUS_RUNNING || job.Status == STATUS_DONE {
+		tools.RespondError(errors.New("err_job_not_retryable"), http.StatusConflict, c)
+		return
+	}
+	if err := con.DataWrap.DB.Model(&Job{}).Where("id = ? AND status = ?", job.ID, job.Status).Updates(requeue()).Error; err != nil {
+		tools.RespondError(err, http.StatusBadRequest, c)
+		return
+	}
+	wake()
+	con.DataWrap.DB.First(&job, job.ID)
+	tools.RespondWithJSON(c, http.StatusOK, &job)
+}
+
+// Requeues all dead jobs, optionally only those of one type
+func (con *jobController) retryDeadHandler(c *gin.Context) {
+	db := con.DataWrap.DB.Model(&Job{}).Where("status = ?", STATUS_DEAD)
+	if jobType := c.Query("type"); len(jobType) > 0 {
+		db = db.Where("type = ?", jobType)
+	}
+	result := db.Updates(requeue())
+	if result.Error != nil {
+		tools.RespondError(result.Error, http.StatusBadRequest, c)
+		return
+	}
+	wake()
+	tools.RespondWithJSON(c, http.StatusOK, map[string]int64{"retried": result.RowsAffected})
+}
+
+func (con *jobController) deleteJobHandler(c *gin.Context) {
+	job, err := tools.GetSingleById[Job](c, con.DataWrap.DB)
+	if err != nil {
+		tools.RespondError(errors.New("not_found"), http.StatusNotFound, c)
+		return
+	}
+	if job.Status == STATUS_RUNNING {
+		tools.RespondError(errors.New("err_job_running"), http.StatusConflict, c)
+		return
+	}
+	if err := con.DataWrap.DB.Unscoped().Where("status <> ?", STATUS_RUNNING).Delete(&job).Error; err != nil {
+		tools.RespondError(err, http.StatusBadRequest, c)
+		return
+	}
+	tools.RespondWithJSON(c, http.StatusOK, &job)
+}
+
+// Deletes all jobs with the given status (done, dead or pending), optionally filtered by type and a "before" RFC3339 timestamp
+func (con *jobController) purgeJobsHandler(c *gin.Context) {
+	status := c.Query("status")
+	if status != STATUS_DONE && status != STATUS_DEAD && status != STATUS_PENDING {
+		tools.RespondError(errors.New("err_job_invalid_status"), http.StatusBadRequest, c)
+		return
+	}
+	db := filterJobs(con.DataWrap.DB.Unscoped(), c)
+	if before, err := time.Parse(time.RFC3339, c.Query("before")); err == nil {
+		db = db.Where("created_at < ?", before)
+	}
+	result := db.Delete(&Job{})
+	if result.Error != nil {
+		tools.RespondError(result.Error, http.StatusBadRequest, c)
+		return
+	}
+	tools.RespondWithJSON(c, http.StatusOK, map[string]int64{"deleted": result.RowsAffected})
+}
+
+func filterJobs(db *gorm.DB, c *gin.Context) *gorm.DB {
+	if status := c.Query("status"); len(status) > 0 {
+		db = db.Where("status = ?", status)
+	}
+	if jobType := c.Query("type"); len(jobType) > 0 {
+		db = db.Where("type = ?", jobType)
+	}
+	return db
+}
+
+func requeue() map[string]interface{} {
+	return map[string]interface{}{"status": STATUS_PENDING, "attempts": 0, "run_at": time.Now(), "last_error": "", "finished_at": nil}
+}
+
+// Lists the tasks scheduled on this node with their cluster wide state
+func (con *jobController) getSchedulesHandler(c *gin.Context) {
+	states := []ScheduledTask{}
+	if err := con.DataWrap.DB.Find(&states).Error; err != nil {
+		tools.RespondError(err, http.StatusBadRequest, c)
+		return
+	}
+	byName := map[string]ScheduledTask{}
+	for _, state := range states {
+		byName[state.Name] = state
+	}
+	infos := []taskInfo{}
+	for _, task := range sortedTasks() {
+		infos = append(infos, task.info(byName[task.name]))
+	}
+	tools.RespondWithJSON(c, http.StatusOK, &infos)
+}
+
+// Paged run history of a task, newest runs first
+func (con *jobController) getTaskRunsHandler(c *gin.Context) {
+	db := con.DataWrap.DB.Where("task = ?", c.Param("name"))
+	if len(c.Request.URL.Query()["order"]) == 0 {
+		db = db.Order("started_at DESC")
+	}
+	tools.GetPagedAndSend[TaskRun](c, db)
+}
+
+// Starts a run right away, also if the task is paused
+func (con *jobController) triggerTaskHandler(c *gin.Context) {
+	task, ok := getTask(c.Param("name"))
+	if !ok {
+		tools.RespondError(errors.New("not_found"), http.StatusNotFound, c)
+		return
+	}
+	lease, err := task.acquire()
+	if err != nil {
+		tools.RespondError(err, http.StatusConflict, c)
+		return
+	}
+	go task.execute(con.DataWrap.DB, lease, TRIGGER_MANUAL)
+	tools.RespondWithJSON(c, http.StatusAccepted, "task_triggered")
+}
+
+func (con *jobController) pauseTaskHandler(c *gin.Context) {
+	con.setTaskPaused(c, true)
+}
+
+func (con *jobController) resumeTaskHandler(c *gin.Context) {
+	con.setTaskPaused(c, false)
+}
+
+// Pausing applies to all nodes, a run that's already going is not interrupted
+func (con *jobController) setTaskPaused(c *gin.Context, paused bool) {
+	task, ok := getTask(c.Param("name"))
+	if !ok {
+		tools.RespondError(errors.New("not_found"), http.StatusNotFound, c)
+		return
+	}
+	if err := con.DataWrap.DB.Model(&ScheduledTask{}).Where("name = ?", task.name).Update("paused", paused).Error; err != nil {
+		tools.RespondError(err, http.StatusBadRequest, c)
+		return
+	}
+	state := ScheduledTask{}
+	con.DataWrap.DB.Where("name = ?", task.name).First(&state)
+	info := task.info(state)
+	tools.RespondWithJSON(c, http.StatusOK, &info)
+}
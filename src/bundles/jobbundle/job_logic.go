@@ -0,0 +1,188 @@
+package jobbundle
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sc-js/backend_core/src/bundles/cachebundle"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// Returned with the existing job if one with the same UniqueKey is pending or running
+	ErrDuplicate      = errors.New("err_job_duplicate")
+	errNotInitialized = errors.New("job queue not initialized")
+)
+
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Marks a handler error as not worth retrying, the job goes to the dead-letter state right away
+func Permanent(err error) error {
+	return permanentError{err: err}
+}
+
+func isPermanent(err error) bool {
+	var permanent permanentError
+	return errors.As(err, &permanent)
+}
+
+// Stores a job for the handler registered under jobType, payload is encoded as JSON.
+// The job runs on any node with a handler for the type, surviving restarts of the node that enqueued it.
+func Enqueue[T any](jobType string, payload T, options ...EnqueueOptions) (Job, error) {
+	job := Job{}
+	if controller == nil {
+		return job, errNotInitialized
+	}
+	opts := EnqueueOptions{}
+	if len(options) > 0 {
+		opts = options[0]
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return job, err
+	}
+
+	payloadText := string(data)
+	if opts.Sealed {
+		if payloadText, err = sealPayload(data); err != nil {
+			return job, err
+		}
+	}
+
+	job = Job{Type: jobType, Payload: payloadText, Sealed: opts.Sealed, Status: STATUS_PENDING, Priority: opts.Priority, MaxAttempts: opts.MaxAttempts, RunAt: time.Now().Add(opts.Delay)}
+	if !opts.RunAt.IsZero() {
+		job.RunAt = opts.RunAt
+	}
+	if job.MaxAttempts <= 0 {
+		job.MaxAttempts = maxAttempts
+		if h, ok := getHandler(jobType); ok && h.options.MaxAttempts > 0 {
+			job.MaxAttempts = h.options.MaxAttempts
+		}
+	}
+
+	db := controller.DataWrap.DB
+	if len(opts.UniqueKey) == 0 {
+		if err := db.Create(&job).Error; err != nil {
+			return job, err
+		}
+	} else {
+		job.UniqueKey = &opts.UniqueKey
+		result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&job)
+		if result.Error != nil {
+			return job, result.Error
+		}
+		if result.RowsAffected == 0 {
+			existing := Job{}
+			if err := db.Where("unique_key = ?", opts.UniqueKey).First(&existing).Error; err != nil {
+				return job, err
+			}
+			return existing, ErrDuplicate
+		}
+	}
+
+	if !job.RunAt.After(time.Now()) {
+		wake()
+		cachebundle.Publish(TOPIC_ENQUEUED, job.Type)
+	}
+	return job, nil
+}
+
+// Locks the next due job of a handled type for this worker. Jobs whose lock ran out are due again.
+// Returns nil if there is nothing to do.
+func claim(db *gorm.DB, lockedBy string) (*Job, error) {
+	types := handledTypes()
+	if len(types) == 0 {
+		return nil, nil
+	}
+	now := time.Now()
+	query := `SELECT id FROM jobs WHERE deleted_at IS NULL AND type IN ? AND ((status = ? AND run_at <= ?) OR (status = ? AND locked_until < ?))
+		ORDER BY priority DESC, run_at ASC LIMIT 1`
+	if db.Dialector.Name() == "postgres" {
+		// Lets workers on all nodes poll the same table without blocking on each other's rows
+		query += " FOR UPDATE SKIP LOCKED"
+	}
+
+	jobs := []Job{}
+	err := db.Raw(`UPDATE jobs SET status = ?, attempts = attempts + 1, locked_until = ?, locked_by = ?, updated_at = ? WHERE id = (`+query+`) RETURNING *`,
+		STATUS_RUNNING, now.Add(defaultTimeout), lockedBy, now, types, STATUS_PENDING, now, STATUS_RUNNING, now).Scan(&jobs).Error
+	if err != nil || len(jobs) == 0 {
+		return nil, err
+	}
+	return &jobs[0], nil
+}
+
+// Runs a claimed job and stores the outcome, unless the job was reclaimed by another worker in the meantime
+func execute(db *gorm.DB, job *Job) {
+	h, ok := getHandler(job.Type)
+	if !ok {
+		finish(db, job, STATUS_DEAD, errors.New("no handler for job type "+job.Type))
+		return
+	}
+	// A worker died while running it, every such run counts as an attempt
+	if job.Attempts > job.MaxAttempts {
+		finish(db, job, STATUS_DEAD, errors.New("lock expired after the last attempt"))
+		return
+	}
+	timeout := h.timeout()
+	if timeout != defaultTimeout {
+		db.Model(&Job{}).Where("id = ? AND locked_by = ?", job.ID, job.LockedBy).Update("locked_until", time.Now().Add(timeout))
+	}
+
+	payload := job.Payload
+	if job.Sealed {
+		opened, err := openPayload(payload)
+		if err != nil {
+			logFailure(job, err, true)
+			finish(db, job, STATUS_DEAD, err)
+			return
+		}
+		payload = opened
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	err := runSafe(h, ctx, payload)
+
+	switch {
+	case err == nil:
+		finish(db, job, STATUS_DONE, nil)
+	case isPermanent(err) || job.Attempts >= job.MaxAttempts:
+		logFailure(job, err, true)
+		finish(db, job, STATUS_DEAD, err)
+	default:
+		logFailure(job, err, false)
+		db.Model(&Job{}).Where("id = ? AND locked_by = ? AND attempts = ?", job.ID, job.LockedBy, job.Attempts).Updates(map[string]interface{}{
+			"status": STATUS_PENDING, "run_at": time.Now().Add(h.backoff(job.Attempts)), "last_error": err.Error(), "locked_until": nil, "locked_by": "",
+		})
+	}
+}
+
+// Moves a job to done or dead and frees its unique key
+func finish(db *gorm.DB, job *Job, status string, cause error) {
+	now := time.Now()
+	lastError := ""
+	if cause != nil {
+		lastError = cause.Error()
+	}
+	db.Model(&Job{}).Where("id = ? AND locked_by = ? AND attempts = ?", job.ID, job.LockedBy, job.Attempts).Updates(map[string]interface{}{
+		"status": status, "finished_at": now, "last_error": lastError, "locked_until": nil, "locked_by": "", "unique_key": nil,
+	})
+}
+
+func runSafe(h jobHandler, ctx context.Context, payload string) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+	return h.run(ctx, payload)
+}
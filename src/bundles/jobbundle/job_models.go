@@ -0,0 +1,121 @@
+package jobbundle
+
+import (
+	"time"
+
+	"github.com/sc-js/backend_core/src/tools"
+)
+
+const (
+	// Waiting for RunAt, also used for failed jobs that will be retried
+	STATUS_PENDING = "pending"
+	STATUS_RUNNING = "running"
+	STATUS_DONE    = "done"
+	// Failed MaxAttempts times or had no handler, kept until retried or purged (dead-letter)
+	STATUS_DEAD = "dead"
+)
+
+const (
+	PRIORITY_LOW    = -10
+	PRIORITY_NORMAL = 0
+	PRIORITY_HIGH   = 10
+)
+
+// A persisted unit of work, Payload is the JSON encoded value passed to Enqueue.
+// The payload is never sent by the admin API, it can hold secrets like login codes.
+type Job struct {
+	tools.Model
+	Type    string `json:"type" gorm:"index"`
+	Payload string `json:"-" gorm:"type:text"`
+	// Payload is encrypted, see EnqueueOptions.Sealed
+	Sealed      bool      `json:"sealed"`
+	Status      string    `json:"status" gorm:"index"`
+	Priority    int       `json:"priority"`
+	Attempts    int       `json:"attempts"`
+	MaxAttempts int       `json:"max_attempts"`
+	RunAt       time.Time `json:"run_at" gorm:"index"`
+	// Set while running, a job whose lock ran out (e.g. the node died) is picked up again
+	LockedUntil *time.Time `json:"locked_until,omitempty"`
+	LockedBy    string     `json:"locked_by,omitempty"`
+	// Only one pending or running job can have the same key, it is cleared once the job is done or dead
+	UniqueKey  *string    `json:"unique_key,omitempty" gorm:"uniqueIndex"`
+	LastError  string     `json:"last_error,omitempty" gorm:"type:text"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// Defaults for the jobs of a type, zero values fall back to the bundle settings
+type HandlerOptions struct {
+	MaxAttempts int
+	// Deadline of a single attempt, the job lock is held for as long
+	Timeout time.Duration
+	// First retry delay, doubled on every further attempt
+	Backoff time.Duration
+}
+
+type EnqueueOptions struct {
+	Priority int
+	// Run not before Delay from now, or not before RunAt if set
+	Delay time.Duration
+	RunAt time.Time
+	// Enqueueing a job with the key of a pending or running one returns that job and ErrDuplicate
+	UniqueKey   string
+	MaxAttempts int
+	// Encrypts the payload in the database, for payloads with secrets like login codes or tokens
+	Sealed bool
+}
+
+const (
+	TRIGGER_SCHEDULE = "schedule"
+	TRIGGER_MANUAL   = "manual"
+)
+
+// Cluster wide state of a scheduled task, the task itself is registered in code with Schedule
+type ScheduledTask struct {
+	tools.Model
+	Name   string `json:"name" gorm:"uniqueIndex"`
+	Paused bool   `json:"paused"`
+	// Last scheduled run claimed by a node, so every tick runs only once across replicas
+	LastTick       *time.Time `json:"-"`
+	LastRunAt      *time.Time `json:"last_run_at,omitempty"`
+	LastDurationMs int64      `json:"last_duration_ms"`
+	LastError      string     `json:"last_error,omitempty" gorm:"type:text"`
+}
+
+// History entry of a single task run
+type TaskRun struct {
+	tools.Model
+	Task       string    `json:"task" gorm:"index"`
+	Trigger    string    `json:"trigger"`
+	Node       string    `json:"node"`
+	StartedAt  time.Time `json:"started_at" gorm:"index"`
+	DurationMs int64     `json:"duration_ms"`
+	Success    bool      `json:"success"`
+	Error      string    `json:"error,omitempty" gorm:"type:text"`
+}
+
+type TaskOptions struct {
+	// Random delay of up to Jitter added to every scheduled run, spreads load of tasks due at the same time
+	Jitter time.Duration
+	// Cancels the context of a run after Timeout, 0 doesn't limit runs
+	Timeout time.Duration
+	// Runs once right after the scheduler started, in addition to the schedule
+	RunOnStart bool
+}
+
+// Scheduled task as listed by the admin API
+type taskInfo struct {
+	Name           string     `json:"name"`
+	Spec           string     `json:"spec"`
+	Paused         bool       `json:"paused"`
+	Running        bool       `json:"running"`
+	NextRunAt      *time.Time `json:"next_run_at,omitempty"`
+	LastRunAt      *time.Time `json:"last_run_at,omitempty"`
+	LastDurationMs int64      `json:"last_duration_ms"`
+	LastError      string     `json:"last_error,omitempty"`
+}
+
+type jobStats struct {
+	Type   string `json:"type"`
+	Status string `json:"status"`
+	Count  int64  `json:"count"`
+}
@@ -0,0 +1,35 @@
+package jobbundle
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	t "github.com/sc-js/backend_core/src/tools"
+)
+
+var routes []t.GinRoute
+
+func InitBundle(r *gin.RouterGroup, wrap *t.DataWrap, autoMigrate bool, settings map[string]string) {
+	controller := initialize(wrap, settings)
+
+	routes = []t.GinRoute{
+		{Method: http.MethodGet, Endpoint: "/jobs", Handler: controller.getJobsHandler, Permission: t.PERM_ADMIN},
+		{Method: http.MethodDelete, Endpoint: "/jobs", Handler: controller.purgeJobsHandler, Permission: t.PERM_ADMIN},
+		{Method: http.MethodGet, Endpoint: "/jobs/stats", Handler: controller.getStatsHandler, Permission: t.PERM_ADMIN},
+		{Method: http.MethodPost, Endpoint: "/jobs/retry", Handler: controller.retryDeadHandler, Permission: t.PERM_ADMIN},
+		{Method: http.MethodGet, Endpoint: "/jobs/:hid", Handler: controller.getJobHandler, Permission: t.PERM_ADMIN},
+		{Method: http.MethodDelete, Endpoint: "/jobs/:hid", Handler: controller.deleteJobHandler, Permission: t.PERM_ADMIN},
+		{Method: http.MethodPost, Endpoint: "/jobs/:hid/retry", Handler: controller.retryJobHandler, Permission: t.PERM_ADMIN},
+
+		{Method: http.MethodGet, Endpoint: "/schedules", Handler: controller.getSchedulesHandler, Permission: t.PERM_ADMIN},
+		{Method: http.MethodGet, Endpoint: "/schedules/:name/runs", Handler: controller.getTaskRunsHandler, Permission: t.PERM_ADMIN},
+		{Method: http.MethodPost, Endpoint: "/schedules/:name/run", Handler: controller.triggerTaskHandler, Permission: t.PERM_ADMIN},
+		{Method: http.MethodPost, Endpoint: "/schedules/:name/pause", Handler: controller.pauseTaskHandler, Permission: t.PERM_ADMIN},
+		{Method: http.MethodPost, Endpoint: "/schedules/:name/resume", Handler: controller.resumeTaskHandler, Permission: t.PERM_ADMIN},
+	}
+
+	t.InitHandlers(r, routes)
+	startWorkers(wrap.DB)
+	scheduleCleanup(wrap.DB)
+	startScheduler(wrap.DB)
+}
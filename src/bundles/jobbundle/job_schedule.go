@@ -0,0 +1,224 @@
+package jobbundle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sc-js/backend_core/src/bundles/cachebundle"
+	"github.com/sc-js/pour"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var errTaskRunning = errors.New("err_task_running")
+
+type scheduledTask struct {
+	name     string
+	spec     string
+	schedule schedule
+	run      func(ctx context.Context) error
+	options  TaskOptions
+	running  atomic.Bool
+	// Set once the missing cluster lock was logged, every run would log it again otherwise
+	lockWarned atomic.Bool
+}
+
+var (
+	tasks    = map[string]*scheduledTask{}
+	taskLock sync.RWMutex
+	// Set once InitBundle ran, tasks scheduled before wait for it
+	schedulerDB *gorm.DB
+	// Schedules configured per task name, they replace the ones given in code
+	specOverrides = map[string]string{}
+)
+
+// Runs task on the given schedule, a cron expression ("*/15 * * * *"), a descriptor ("@hourly") or an interval ("@every 15m").
+// Every run happens on one node only, runs that are still going when the next one is due are skipped.
+// The schedule can be overridden by name in the job settings, runs are recorded as TaskRun.
+func Schedule(name string, spec string, task func(ctx context.Context) error, options ...TaskOptions) error {
+	t := &scheduledTask{name: name, spec: spec, run: task}
+	if len(options) > 0 {
+		t.options = options[0]
+	}
+	var err error
+	if t.schedule, err = parseSchedule(spec); err != nil {
+		return err
+	}
+
+	taskLock.Lock()
+	defer taskLock.Unlock()
+	if _, ok := tasks[name]; ok {
+		return errors.New("task " + name + " is already scheduled")
+	}
+	tasks[name] = t
+	if schedulerDB != nil {
+		t.applyOverride()
+		go t.loop(schedulerDB)
+	}
+	return nil
+}
+
+func startScheduler(db *gorm.DB) {
+	taskLock.Lock()
+	defer taskLock.Unlock()
+	schedulerDB = db
+	for _, t := range tasks {
+		t.applyOverride()
+		go t.loop(db)
+	}
+}
+
+// Replaces the schedule with the one configured for the task, has to be called with taskLock held
+func (t *scheduledTask) applyOverride() {
+	spec, ok := specOverrides[t.name]
+	if !ok {
+		return
+	}
+	parsed, err := parseSchedule(spec)
+	if err != nil {
+		pour.LogColor(false, pour.ColorRed, "Ignoring configured schedule of task", t.name+":", err)
+		return
+	}
+	t.spec, t.schedule = spec, parsed
+}
+
+func getTask(name string) (*scheduledTask, bool) {
+	taskLock.RLock()
+	defer taskLock.RUnlock()
+	t, ok := tasks[name]
+	return t, ok
+}
+
+func sortedTasks() []*scheduledTask {
+	taskLock.RLock()
+	defer taskLock.RUnlock()
+	list := make([]*scheduledTask, 0, len(tasks))
+	for _, t := range tasks {
+		list = append(list, t)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].name < list[j].name })
+	return list
+}
+
+func (t *scheduledTask) loop(db *gorm.DB) {
+	if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&ScheduledTask{Name: t.name}).Error; err != nil {
+		pour.LogErr(err)
+	}
+	pour.LogColor(false, pour.ColorPurple, "Scheduled task", t.name, "("+t.spec+")")
+
+	if t.options.RunOnStart {
+		t.fire(db, time.Now().Truncate(time.Second))
+	}
+	for {
+		next := t.schedule.next(time.Now())
+		if next.IsZero() {
+			pour.LogColor(false, pour.ColorYellow, "Task", t.name, "won't run again")
+			return
+		}
+		wait := time.Until(next)
+		if t.options.Jitter > 0 {
+			wait += time.Duration(rand.Int63n(int64(t.options.Jitter)))
+		}
+		time.Sleep(wait)
+		t.fire(db, next)
+	}
+}
+
+// Runs the task for a scheduled tick unless it's paused or another node already claimed the tick
+func (t *scheduledTask) fire(db *gorm.DB, tick time.Time) {
+	state := ScheduledTask{}
+	if err := db.Where("name = ?", t.name).First(&state).Error; err != nil {
+		pour.LogErr(err)
+		return
+	}
+	if state.Paused {
+		return
+	}
+	claimed := db.Model(&ScheduledTask{}).Where("name = ? AND (last_tick IS NULL OR last_tick < ?)", t.name, tick).Update("last_tick", tick)
+	if claimed.Error != nil || claimed.RowsAffected == 0 {
+		return
+	}
+	lease, err := t.acquire()
+	if err != nil {
+		if err == errTaskRunning {
+			pour.LogColor(false, pour.ColorYellow, "Task", t.name, "is still running, skipping this run")
+		}
+		return
+	}
+	t.execute(db, lease, TRIGGER_SCHEDULE)
+}
+
+// Makes sure no other run of the task is going on, locally and on the other nodes.
+// Without a lock capable cache engine only the local check applies.
+func (t *scheduledTask) acquire() (*cachebundle.Lease, error) {
+	if !t.running.CompareAndSwap(false, true) {
+		return nil, errTaskRunning
+	}
+	lease, err := cachebundle.Lock("task_"+t.name, time.Minute)
+	if err == cachebundle.ErrLockHeld {
+		t.running.Store(false)
+		return nil, errTaskRunning
+	} else if err != nil && t.lockWarned.CompareAndSwap(false, true) {
+		pour.LogColor(false, pour.ColorYellow, "Task", t.name, "runs without a cluster lock:", err)
+	}
+	return lease, nil
+}
+
+// Runs the task with an acquired lease and records the run, lease may be nil
+func (t *scheduledTask) execute(db *gorm.DB, lease *cachebundle.Lease, trigger string) {
+	defer t.running.Store(false)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if t.options.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, t.options.Timeout)
+		defer cancel()
+	}
+	if lease != nil {
+		defer lease.Unlock()
+		go func() {
+			select {
+			case <-lease.Lost():
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+	}
+
+	started := time.Now()
+	err := runTask(ctx, t.run)
+	run := TaskRun{Task: t.name, Trigger: trigger, Node: cachebundle.NodeID(), StartedAt: started, DurationMs: time.Since(started).Milliseconds(), Success: err == nil}
+	if err != nil {
+		run.Error = err.Error()
+		pour.LogColor(false, pour.ColorRed, "Task", t.name, "failed:", err)
+	}
+	if err := db.Create(&run).Error; err != nil {
+		pour.LogErr(err)
+	}
+	db.Model(&ScheduledTask{}).Where("name = ?", t.name).Updates(map[string]interface{}{"last_run_at": started, "last_duration_ms": run.DurationMs, "last_error": run.Error})
+}
+
+func (t *scheduledTask) info(state ScheduledTask) taskInfo {
+	info := taskInfo{Name: t.name, Spec: t.spec, Paused: state.Paused, Running: t.running.Load(), LastRunAt: state.LastRunAt, LastDurationMs: state.LastDurationMs, LastError: state.LastError}
+	if !info.Running {
+		info.Running, _ = cachebundle.IsLocked("task_" + t.name)
+	}
+	if next := t.schedule.next(time.Now()); !next.IsZero() && !state.Paused {
+		info.NextRunAt = &next
+	}
+	return info
+}
+
+func runTask(ctx context.Context, task func(ctx context.Context) error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("task panicked: %v", r)
+		}
+	}()
+	return task(ctx)
+}
@@ -0,0 +1,61 @@
+package jobbundle
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+
+	"github.com/sc-js/backend_core/src/tools"
+)
+
+var errSealedPayload = errors.New("sealed job payload could not be opened")
+
+// Key of sealed payloads, from the payload_secret setting or derived from the hashid salt.
+// All nodes need the same key, otherwise jobs sealed on one node fail on the others.
+func sealKey() []byte {
+	if len(payloadSecret) > 0 {
+		key := sha256.Sum256([]byte(payloadSecret))
+		return key[:]
+	}
+	return tools.DeriveKey("jobbundle_payload")
+}
+
+// Encrypts a payload with AES-GCM, the nonce is prepended to the ciphertext
+func sealPayload(data []byte) (string, error) {
+	gcm, err := payloadCipher()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(gcm.Seal(nonce, nonce, data, nil)), nil
+}
+
+func openPayload(payload string) (string, error) {
+	gcm, err := payloadCipher()
+	if err != nil {
+		return "", err
+	}
+	data, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil || len(data) < gcm.NonceSize() {
+		return "", errSealedPayload
+	}
+	plain, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", errSealedPayload
+	}
+	return string(plain), nil
+}
+
+func payloadCipher() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(sealKey())
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
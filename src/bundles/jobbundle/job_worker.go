@@ -0,0 +1,89 @@
+package jobbundle
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sc-js/backend_core/src/bundles/cachebundle"
+	"github.com/sc-js/backend_core/src/tools"
+	"github.com/sc-js/pour"
+	"gorm.io/gorm"
+)
+
+// Cache bus topic, wakes idle workers on all nodes when a job is due right away
+const TOPIC_ENQUEUED = "jobs_enqueued"
+
+var wakeup = make(chan struct{}, 1)
+
+// Starts the workers of this node
+func startWorkers(db *gorm.DB) {
+	if workers == 0 {
+		pour.LogColor(false, pour.ColorYellow, "Job workers disabled on this node, jobs are only enqueued")
+		return
+	}
+	cachebundle.Subscribe(TOPIC_ENQUEUED, func(jobType string) {
+		if _, ok := getHandler(jobType); ok {
+			wake()
+		}
+	})
+	for i := 0; i < workers; i++ {
+		go work(db, cachebundle.NodeID()+"/"+fmt.Sprint(i))
+	}
+	pour.LogColor(false, pour.ColorPurple, "Started", workers, "job worker(s)")
+}
+
+// Claims and runs jobs until the table is drained, then sleeps until woken or the poll interval passed
+func work(db *gorm.DB, name string) {
+	for {
+		job, err := claim(db, name)
+		if err != nil {
+			pour.LogColor(false, pour.ColorRed, "Error claiming job:", err)
+		}
+		if job != nil {
+			execute(db, job)
+			continue
+		}
+		select {
+		case <-wakeup:
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// Nudges one idle worker, enough as it keeps claiming until nothing is due
+func wake() {
+	select {
+	case wakeup <- struct{}{}:
+	default:
+	}
+}
+
+// Deletes done jobs and task runs older than the retention once an hour, on one node
+func scheduleCleanup(db *gorm.DB) {
+	err := Schedule("job_cleanup", "@hourly", func(ctx context.Context) error {
+		return cleanup(db)
+	})
+	if err != nil {
+		pour.LogErr(err)
+	}
+}
+
+func cleanup(db *gorm.DB) error {
+	result := db.Unscoped().Where("status = ? AND finished_at < ?", STATUS_DONE, time.Now().Add(-retention)).Delete(&Job{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		pour.LogColor(false, pour.ColorYellow, "Deleted", result.RowsAffected, "finished job(s)")
+	}
+	return db.Unscoped().Where("started_at < ?", time.Now().Add(-retention)).Delete(&TaskRun{}).Error
+}
+
+func logFailure(job *Job, err error, dead bool) {
+	if dead {
+		pour.LogColor(false, pour.ColorRed, "Job", tools.Encode(job.ID), job.Type, "failed for good after", job.Attempts, "attempt(s):", err)
+		return
+	}
+	pour.LogColor(false, pour.ColorYellow, "Job", tools.Encode(job.ID), job.Type, "failed, attempt", job.Attempts, "of", job.MaxAttempts, ":", err)
+}
@@ -1,78 +1,46 @@
 package localizationbundle
 
 import (
-	"io"
-	"os"
-
-	"github.com/Jeffail/gabs"
 	"github.com/gin-gonic/gin"
-	"github.com/sc-js/pour"
+	"github.com/sc-js/backend_core/src/bundles/cachebundle"
+	"github.com/sc-js/backend_core/src/tools"
 )
 
-var gabsLocale *gabs.Container
-var localeKeyCache map[string]string
-
-func InitLocales() {
-	jsonFile, err := os.Open("./locales.json")
-	if err != nil {
-		return
-	}
-	defer jsonFile.Close()
-
-	byteValue, _ := io.ReadAll(jsonFile)
-	gabsLocale, err = gabs.ParseJSON(byteValue)
-
-	if err != nil {
-		pour.LogColor(false, pour.ColorRed, "Parsing locales failed")
-	}
-
-	localeKeyCache = make(map[string]string)
+// Cache bus topic, every node reloaded its locales.json when a message arrived.
+//
+// Deprecated: nothing subscribes to it anymore, use cachebundle.RefreshTranslations.
+const TOPIC_RELOAD = "locales_reload"
+
+// Kept for compatibility, the entries of locales.json are migrated into the translation files once the cache is connected.
+//
+// Deprecated: translations are read by the cachebundle catalog, nothing has to be initialized.
+func InitLocales() {}
+
+// Re-reads the translation files into the shared cache
+//
+// Deprecated: use cachebundle.RefreshTranslations.
+func ReloadLocales() error {
+	return cachebundle.RefreshTranslations()
 }
 
+// Translates key into the locale of the request, keys without a translation are returned as they are.
+//
+// Deprecated: use tools.T.
 func LocRes(key string, c *gin.Context) string {
-	locale := "en_EN"
-	if c.Request != nil {
-		locale = c.Request.Header.Get("X-LOCALE")
-		if len(locale) <= 0 {
-			locale = "en_EN"
-		}
-	}
-	localePath := locale + "." + key
-
-	cached, cacheOk := localeKeyCache[localePath]
-	if cacheOk {
-		return cached
-	}
-
-	val, ok := gabsLocale.Path(localePath).Data().(string)
-	if !ok {
-		return "LOCALE " + localePath + " NOT FOUND"
-	} else {
-		localeKeyCache[localePath] = val
-		return val
+	if c == nil || c.Request == nil {
+		return tools.TLocale(tools.DefaultLocale(), key, nil)
 	}
+	return tools.T(c, key, nil)
 }
 
+// Translates key into the closest valid locale, e.G. de_DE for de_AT.
+//
+// Deprecated: use tools.MatchLocale and tools.TLocale.
 func GetLocaleFCM(key string, locale string) string {
-	if locale == "de_AT" || locale == "de_CH" {
-		locale = "de_DE"
-	}
-	if locale != "de_DE" && locale != "en_EN" {
-		locale = "en_EN"
-	}
-
-	localePath := locale + "." + key
-
-	cached, cacheOk := localeKeyCache[localePath]
-	if cacheOk {
-		return cached
-	}
-
-	val, ok := gabsLocale.Path(localePath).Data().(string)
-	if !ok {
-		return "LOCALE " + localePath + " NOT FOUND"
+	if match, ok := tools.MatchLocale(locale); ok {
+		locale = match
 	} else {
-		localeKeyCache[localePath] = val
-		return val
+		locale = tools.DefaultLocale()
 	}
+	return tools.TLocale(locale, key, nil)
 }
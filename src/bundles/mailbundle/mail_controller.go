@@ -0,0 +1,82 @@
+package mailbundle
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/mail"
+	"net/smtp"
+	"strings"
+
+	"github.com/sc-js/pour"
+)
+
+var mailer Mailer = logMailer{}
+
+// Initializes the mailer, SMTP is used if a host is configured, otherwise mails are only logged (development)
+func InitMailer(host string, port uint, username string, password string, from string) {
+	registerJobs()
+	if len(host) == 0 {
+		pour.LogColor(false, pour.ColorYellow, "No mail server configured, mails will only be logged")
+		return
+	}
+	if port == 0 {
+		port = 587
+	}
+	mailer = &smtpMailer{Address: host + ":" + fmt.Sprint(port), Host: host, Username: username, Password: password, From: from}
+	pour.LogColor(false, pour.ColorPurple, "Mailer configured for", host+":"+fmt.Sprint(port))
+}
+
+// Replaces the active mailer, e.G. with a transactional mail API client
+func SetMailer(m Mailer) {
+	mailer = m
+}
+
+func Send(msg Message) error {
+	if len(msg.To) == 0 {
+		return errors.New("mail_no_recipient")
+	}
+	return mailer.Send(msg)
+}
+
+type smtpMailer struct {
+	Address  string
+	Host     string
+	Username string
+	Password string
+	From     string
+}
+
+func (m *smtpMailer) Send(msg Message) error {
+	var auth smtp.Auth
+	if len(m.Username) > 0 {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+	// Addresses are parsed and written back encoded, so line breaks in them can't add headers
+	from, err := mail.ParseAddress(m.From)
+	if err != nil {
+		return errors.New("mail_invalid_sender")
+	}
+	to, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return errors.New("mail_invalid_recipient")
+	}
+	contentType := "text/plain"
+	if msg.HTML {
+		contentType = "text/html"
+	}
+	subject := strings.NewReplacer("\r", " ", "\n", " ").Replace(msg.Subject)
+	header := "From: " + from.String() + "\r\n" +
+		"To: " + to.String() + "\r\n" +
+		"Subject: " + mime.QEncoding.Encode("utf-8", subject) + "\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: " + contentType + "; charset=\"utf-8\"\r\n\r\n"
+	return smtp.SendMail(m.Address, auth, from.Address, []string{to.Address}, []byte(header+msg.Body))
+}
+
+type logMailer struct{}
+
+func (logMailer) Send(msg Message) error {
+	pour.LogColor(false, pour.ColorYellow, "MAIL -> To:", msg.To, "Subject:", msg.Subject, "\n"+msg.Body)
+	return nil
+}
@@ -0,0 +1,13 @@
+package mailbundle
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+	HTML    bool
+}
+
+// Anything that can deliver a message, e.G. SMTP or a third party API. Register custom ones with SetMailer.
+type Mailer interface {
+	Send(msg Message) error
+}
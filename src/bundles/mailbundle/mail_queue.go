@@ -0,0 +1,30 @@
+package mailbundle
+
+import (
+	"context"
+
+	"github.com/sc-js/backend_core/src/bundles/jobbundle"
+)
+
+// Job type of queued template mails
+const JOB_TEMPLATE = "mail_template"
+
+type templateJob struct {
+	To     string            `json:"to"`
+	Locale string            `json:"locale"`
+	Name   string            `json:"name"`
+	Data   map[string]string `json:"data"`
+}
+
+func registerJobs() {
+	jobbundle.Handle(JOB_TEMPLATE, func(ctx context.Context, job templateJob) error {
+		return SendTemplate(job.To, job.Locale, job.Name, job.Data)
+	})
+}
+
+// Sends a template mail through the job queue, so it is retried if the mail server is down and survives restarts.
+// The data is sealed in the queue, as it often holds login codes or tokens. Returns the error if the mail couldn't be queued.
+func QueueTemplate(to string, locale string, name string, data map[string]string) error {
+	_, err := jobbundle.Enqueue(JOB_TEMPLATE, templateJob{To: to, Locale: locale, Name: name, Data: data}, jobbundle.EnqueueOptions{Sealed: true})
+	return err
+}
@@ -0,0 +1,31 @@
+package mailbundle
+
+import "github.com/sc-js/backend_core/src/tools"
+
+// Renders and sends a localized mail. Subject and body are looked up as the translation keys
+// <name>_subject and <name>_body and use the message format of the catalog, e.g. {Link} (see tools.FormatMessage).
+func SendTemplate(to string, locale string, name string, data map[string]string) error {
+	subject, err := render(locale, name+"_subject", data)
+	if err != nil {
+		return err
+	}
+	body, err := render(locale, name+"_body", data)
+	if err != nil {
+		return err
+	}
+	return Send(Message{To: to, Subject: subject, Body: body})
+}
+
+func render(locale string, key string, data map[string]string) (string, error) {
+	text := key
+	if tools.SingleTranslationCallback != nil {
+		if trans, err := tools.SingleTranslationCallback(locale, key); err == nil && len(trans) > 0 {
+			text = trans
+		}
+	}
+	args := make(tools.Args, len(data))
+	for name, val := range data {
+		args[name] = val
+	}
+	return tools.FormatMessage(locale, text, args)
+}
@@ -0,0 +1,83 @@
+package websocketbundle
+
+import (
+	"fmt"
+
+	"github.com/sc-js/backend_core/src/bundles/authbundle"
+	"github.com/sc-js/backend_core/src/bundles/cachebundle"
+	"github.com/sc-js/backend_core/src/tools"
+	"github.com/sc-js/pour"
+)
+
+// Cache bus topics used to run the hub across several nodes
+const (
+	TOPIC_MESSAGE   = "ws_message"
+	TOPIC_CONNECTED = "ws_connected"
+)
+
+// Presence entries outlive a missed ping, they are refreshed on every ping
+const presenceTTL = pongWait + pingPeriod
+
+func subscribeCluster() {
+	cachebundle.Subscribe(TOPIC_MESSAGE, func(d wsDelivery) {
+		deliverLocal(d.Message, d.IDs)
+	})
+	cachebundle.Subscribe(TOPIC_CONNECTED, func(c wsConnected) {
+		// Accounts only have one connection, an older one on another node is closed
+		if c.Node != cachebundle.NodeID() {
+			closeLocal(c.ID)
+		}
+		flushPending(c.ID)
+	})
+	cachebundle.Subscribe(authbundle.TOPIC_REVOCATION, func(r authbundle.Revocation) {
+		// Single sessions don't matter here, the websocket isn't bound to a token
+		if r.UserID > 0 && len(r.Session) == 0 {
+			closeLocal(r.UserID)
+		}
+	})
+}
+
+// Marks the account as connected to this node and tells the others
+func announceClient(id tools.ModelID) {
+	setPresence(id)
+	if err := cachebundle.Publish(TOPIC_CONNECTED, wsConnected{ID: id, Node: cachebundle.NodeID()}); err != nil {
+		pour.LogColor(false, pour.ColorRed, "Error announcing WS client:", err)
+		flushPending(id)
+	}
+}
+
+func setPresence(id tools.ModelID) {
+	if err := cachebundle.PutExpire("ws_presence", fmt.Sprint(id), cachebundle.NodeID(), presenceTTL); err != nil {
+		pour.LogColor(false, pour.ColorRed, "Error storing WS presence:", err)
+	}
+}
+
+// Removes the presence entry if it still points to this node, the account may have reconnected elsewhere
+func dropPresence(id tools.ModelID) {
+	node, err := cachebundle.Get[string]("ws_presence", fmt.Sprint(id))
+	if err == nil && node == cachebundle.NodeID() {
+		cachebundle.Del("ws_presence", fmt.Sprint(id))
+	}
+}
+
+func isPresent(id tools.ModelID) bool {
+	_, err := cachebundle.Get[string]("ws_presence", fmt.Sprint(id))
+	return err == nil
+}
+
+func closeLocal(id tools.ModelID) {
+	if client, ok := wshub.idClientMap.Load(id); ok {
+		client.(*wsclient).conn.Close()
+	}
+}
+
+// Sends the messages this node queued while the account was offline
+func flushPending(id tools.ModelID) {
+	cache, ok := wsSendingCache.LoadAndDelete(id)
+	if !ok {
+		return
+	}
+	for _, element := range cache.([]WSMessage) {
+		go SpreadMessageToIds(element, []tools.ModelID{id})
+	}
+}
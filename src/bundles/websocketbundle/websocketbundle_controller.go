@@ -25,6 +25,7 @@ func initialize(wrap *tools.DataWrap, settings map[string]string) *websocketCont
 	handleSettings(settings, wrap)
 	wshub = newHub(wrap)
 	go wshub.run()
+	subscribeCluster()
 
 	return c
 }
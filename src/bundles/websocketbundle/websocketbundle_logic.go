@@ -9,6 +9,7 @@ import (
 
 	"github.com/gorilla/websocket"
 	"github.com/sc-js/backend_core/src/bundles/authbundle"
+	"github.com/sc-js/backend_core/src/bundles/cachebundle"
 	"github.com/sc-js/backend_core/src/tools"
 	"github.com/sc-js/pour"
 	"gorm.io/gorm"
@@ -65,10 +66,11 @@ func (h *hub) run() {
 				connectedClient.(*wsclient).conn.Close()
 			}
 			h.idClientMap.Store(client.User.ID, client)
+			go announceClient(client.User.ID)
 		case client := <-h.unregister:
 			if _, ok := h.clients[client]; ok {
 				delete(h.clients, client)
-				h.idClientMap.Delete(client.User.ID)
+				h.forget(client)
 				close(client.send)
 			}
 		case message := <-h.broadcast:
@@ -78,13 +80,21 @@ func (h *hub) run() {
 				default:
 					close(client.send)
 					delete(h.clients, client)
-					h.idClientMap.Delete(client.User.ID)
+					h.forget(client)
 				}
 			}
 		}
 	}
 }
 
+// Removes the id mapping unless the user already reconnected with a newer client
+func (h *hub) forget(client *wsclient) {
+	if current, ok := h.idClientMap.Load(client.User.ID); ok && current.(*wsclient) == client {
+		h.idClientMap.Delete(client.User.ID)
+		go dropPresence(client.User.ID)
+	}
+}
+
 func (c *wsclient) SendMessage(msg WSMessage) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -99,6 +109,18 @@ type WSMessage struct {
 	Content string `json:"content"`
 }
 
+// Messages on TOPIC_MESSAGE, every node delivers Message to the listed accounts connected to it
+type wsDelivery struct {
+	Message WSMessage       `json:"message"`
+	IDs     []tools.ModelID `json:"ids"`
+}
+
+// Messages on TOPIC_CONNECTED, published when an account connects to Node
+type wsConnected struct {
+	ID   tools.ModelID `json:"id"`
+	Node string        `json:"node"`
+}
+
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
@@ -117,26 +139,36 @@ func serveWs(hub *hub, w http.ResponseWriter, r *http.Request, user authbundle.A
 	pour.LogColor(false, pour.ColorYellow, "Registered WS Client Account:", client.User.ID)
 	client.hub.register <- client
 
-	cache, ok := wsSendingCache.Load(user.ID)
-	if ok {
-		wsSendingCache.LoadAndDelete(user.ID)
-		for _, element := range cache.([]WSMessage) {
-			go SpreadMessageToIds(element, []tools.ModelID{user.ID})
-		}
-	}
-
 	go client.writePump()
 	go client.readPump()
 }
 
+// Sends a message to the given accounts on whichever node they are connected to.
+// Messages for accounts that aren't connected anywhere are queued until they connect.
 func SpreadMessageToIds(msg WSMessage, ids []tools.ModelID) {
-	clients, missing := wshub.getClientsWithAccountIds(ids)
+	online := []tools.ModelID{}
+	for _, element := range ids {
+		if isPresent(element) {
+			online = append(online, element)
+		} else {
+			go CacheSendMessage(msg, element)
+		}
+	}
+	if len(online) == 0 {
+		return
+	}
+	if err := cachebundle.Publish(TOPIC_MESSAGE, wsDelivery{Message: msg, IDs: online}); err != nil {
+		pour.LogColor(false, pour.ColorRed, "Error publishing WS message, delivering locally only:", err)
+		deliverLocal(msg, online)
+	}
+}
+
+// Sends a message to the accounts connected to this node, the others are handled by their nodes
+func deliverLocal(msg WSMessage, ids []tools.ModelID) {
+	clients, _ := wshub.getClientsWithAccountIds(ids)
 	for _, element := range clients {
 		go element.SendMessage(msg)
 	}
-	for _, element := range missing {
-		go CacheSendMessage(msg, element)
-	}
 }
 
 func CacheSendMessage(msg WSMessage, id tools.ModelID) {
@@ -157,7 +189,7 @@ func CacheSendMessage(msg WSMessage, id tools.ModelID) {
 }
 
 func (h *hub) getClientsWithAccountIds(ids []tools.ModelID) ([]*wsclient, []tools.ModelID) {
-	missingIds := ids
+	missingIds := append([]tools.ModelID(nil), ids...)
 	clients := []*wsclient{}
 
 	for _, element := range ids {
@@ -235,6 +267,7 @@ func (c *wsclient) writePump() {
 			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
 				return
 			}
+			setPresence(c.User.ID)
 		}
 	}
 }
@@ -69,7 +69,7 @@ func GetMany[T any](m *Mongo, query *Query, collectionName string) ([]T, error)
 	}
 
 	cursor, cursorError = m.Database.Collection(name).Find(context.TODO(), filter, options)
-	if err != nil {
+	if cursorError != nil {
 		return data, cursorError
 	}
 
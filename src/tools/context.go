@@ -0,0 +1,44 @@
+package tools
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/twinj/uuid"
+)
+
+const (
+	CTX_REQUEST_ID    = "request_id"
+	CTX_IMPERSONATOR  = "impersonator_id"
+	CTX_SUBJECT       = "subject"
+	HEADER_REQUEST_ID = "X-Request-ID"
+)
+
+// Tags every request with an id, either taken from the incoming X-Request-ID header or freshly generated.
+// The id is echoed back in the response header so clients can correlate logs and audit events.
+func RequestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(HEADER_REQUEST_ID)
+		if len(id) == 0 || len(id) > 64 {
+			id = uuid.NewV4().String()
+		}
+		c.Set(CTX_REQUEST_ID, id)
+		c.Header(HEADER_REQUEST_ID, id)
+		c.Next()
+	}
+}
+
+func GetRequestID(c *gin.Context) string {
+	return c.GetString(CTX_REQUEST_ID)
+}
+
+// Returns the id of the admin impersonating the requesting user, or 0 if the request is not impersonated
+func GetImpersonator(c *gin.Context) ModelID {
+	if id, ok := c.Get(CTX_IMPERSONATOR); ok {
+		return id.(ModelID)
+	}
+	return 0
+}
+
+// Returns who is calling, e.G. "user:12" or "service:<client id>", empty for anonymous requests
+func GetSubject(c *gin.Context) string {
+	return c.GetString(CTX_SUBJECT)
+}
@@ -39,10 +39,16 @@ func GetSingleByIdAndSend[T any](c *gin.Context, db *gorm.DB) (T, error) {
 }
 
 func Update[T any](obj interface{}, db *gorm.DB, c *gin.Context) (T, error) {
+	return UpdateById[T](obj, Decode(c.Param("hid")), db, c)
+}
+
+// Works like Update, but patches the object with the given id instead of the one from the "hid" route param,
+// e.G. for self-service handlers where the id comes from the token
+func UpdateById[T any](obj interface{}, id ModelID, db *gorm.DB, c *gin.Context) (T, error) {
 
 	t := obj.(T)
 	c.Bind(&t)
-	o, err := updateObject(t, db, c)
+	o, err := updateObject(t, id, db)
 	if err != nil {
 		RespondError(err, http.StatusBadRequest, c)
 		return t, err
@@ -56,8 +62,7 @@ func Update[T any](obj interface{}, db *gorm.DB, c *gin.Context) (T, error) {
 	return oc, err
 }
 
-func updateObject(object interface{}, db *gorm.DB, c *gin.Context) (interface{}, error) {
-	id := Decode(c.Param("hid"))
+func updateObject(object interface{}, id ModelID, db *gorm.DB) (interface{}, error) {
 	objectType := reflect.TypeOf(object)
 	copy := reflect.New(objectType).Interface()
 	if err := db.First(&copy, "id=?", id).Error; err != nil {
@@ -91,16 +96,10 @@ func autoPatch(x interface{}, y interface{}) interface{} {
 			} else {
 				z = v
 			}
-			t := z.Type()
-			for i := 0; i < z.NumField(); i++ {
-				field := z.Field(i)
-				if !reflect.DeepEqual(field.Interface(), reflect.Zero(field.Type()).Interface()) && t.Field(i).Tag.Get("update") != "false" {
-					if j == 0 {
-						fieldsOld[t.Field(i).Name] = &typeValue{Type: field.Type().Kind(), Value: field.Interface()}
-					} else {
-						fieldsNew[t.Field(i).Name] = &typeValue{Type: field.Type().Kind(), Value: field.Interface()}
-					}
-				}
+			if j == 0 {
+				collectPatchFields(z, fieldsOld)
+			} else {
+				collectPatchFields(z, fieldsNew)
 			}
 		}(i)
 	}
@@ -128,6 +127,25 @@ func autoPatch(x interface{}, y interface{}) interface{} {
 	return valOld.Interface()
 }
 
+// Collects the non-zero fields that may be updated. Embedded structs (tools.Model) are walked field by field,
+// so the update:"false" tags of their fields are respected and the id of the object can't be overwritten.
+func collectPatchFields(z reflect.Value, fields map[string]*typeValue) {
+	t := z.Type()
+	for i := 0; i < z.NumField(); i++ {
+		field := z.Field(i)
+		if t.Field(i).Tag.Get("update") == "false" {
+			continue
+		}
+		if t.Field(i).Anonymous && field.Kind() == reflect.Struct {
+			collectPatchFields(field, fields)
+			continue
+		}
+		if !reflect.DeepEqual(field.Interface(), reflect.Zero(field.Type()).Interface()) {
+			fields[t.Field(i).Name] = &typeValue{Type: field.Type().Kind(), Value: field.Interface()}
+		}
+	}
+}
+
 /*func write(oldField reflect.Value, newField reflect.Value, fieldType reflect.Kind) {
 	//Sieht schlimm aus, wird aber vom compiler zur hashmap optimiert
 	oldField.Set(newField)
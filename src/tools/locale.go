@@ -0,0 +1,200 @@
+package tools
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+const CTX_LOCALE = "locale"
+
+type userLocaleOperator func(c *gin.Context) string
+
+// Returns the preferred locale of the requesting user or an empty string, set by the auth bundle
+var UserLocaleCallback userLocaleOperator
+
+var (
+	defaultLocale = "en_EN"
+	// Parents per locale, e.G. de_CH -> [de_DE]. Locales without an entry fall back to the main locale of their language (de_DE).
+	localeFallbacks = map[string][]string{}
+	fallbackLock    sync.RWMutex
+)
+
+// Sets the locale used when nothing else matches and at the end of every fallback chain
+func SetDefaultLocale(locale string) {
+	if len(locale) == 0 {
+		return
+	}
+	fallbackLock.Lock()
+	defaultLocale = NormalizeLocale(locale)
+	fallbackLock.Unlock()
+}
+
+func DefaultLocale() string {
+	fallbackLock.RLock()
+	defer fallbackLock.RUnlock()
+	return defaultLocale
+}
+
+// Replaces the configured fallback chains, e.G. {"de_CH": ["de_DE"], "de_LI": ["de_CH"]}. Chains are followed transitively.
+func SetLocaleFallbacks(fallbacks map[string][]string) {
+	normalized := make(map[string][]string, len(fallbacks))
+	for locale, parents := range fallbacks {
+		for _, parent := range parents {
+			normalized[NormalizeLocale(locale)] = append(normalized[NormalizeLocale(locale)], NormalizeLocale(parent))
+		}
+	}
+	fallbackLock.Lock()
+	localeFallbacks = normalized
+	fallbackLock.Unlock()
+}
+
+// Locales to look a translation up in, starting with locale itself and ending with the default locale.
+// Parents are taken from the configured chains, otherwise the main locale of the language is used (de_AT -> de_DE).
+// Parents that aren't valid locales are skipped.
+func LocaleChain(locale string) []string {
+	fallbackLock.RLock()
+	defer fallbackLock.RUnlock()
+	chain := []string{locale}
+	seen := map[string]bool{locale: true}
+	var follow func(loc string)
+	follow = func(loc string) {
+		parents, ok := localeFallbacks[loc]
+		if !ok {
+			parents = []string{mainLocale(loc)}
+		}
+		for _, parent := range parents {
+			if seen[parent] {
+				continue
+			}
+			seen[parent] = true
+			if isValidLocale(parent) {
+				chain = append(chain, parent)
+			}
+			follow(parent)
+		}
+	}
+	follow(locale)
+	if !seen[defaultLocale] {
+		chain = append(chain, defaultLocale)
+	}
+	return chain
+}
+
+// de_DE for de_AT or de, the repository convention for the locale of a language
+func mainLocale(locale string) string {
+	lang := language(locale)
+	return lang + "_" + strings.ToUpper(lang)
+}
+
+// Turns language tags like de-ch into the locale format de_CH, script subtags are title case (zh_Hant_TW)
+func NormalizeLocale(tag string) string {
+	parts := strings.Split(strings.TrimSpace(strings.ReplaceAll(tag, "-", "_")), "_")
+	parts[0] = strings.ToLower(parts[0])
+	for i := 1; i < len(parts); i++ {
+		if len(parts[i]) == 4 {
+			parts[i] = strings.ToUpper(parts[i][:1]) + strings.ToLower(parts[i][1:])
+		} else {
+			parts[i] = strings.ToUpper(parts[i])
+		}
+	}
+	return strings.Join(parts, "_")
+}
+
+func isValidLocale(locale string) bool {
+	return ValidatorCallback != nil && ValidatorCallback(locale)
+}
+
+// Maps a requested language tag to a valid locale: the locale itself, the first valid parent of its fallback chain
+// or the main locale of its language. Returns false if none of them is valid.
+func MatchLocale(tag string) (string, bool) {
+	if len(tag) == 0 {
+		return "", false
+	}
+	locale := NormalizeLocale(tag)
+	if isValidLocale(locale) {
+		return locale, true
+	}
+	chain := LocaleChain(locale)
+	for _, parent := range chain[1:] {
+		// The default locale only matches requests of its own language
+		if parent == DefaultLocale() && language(parent) != language(locale) {
+			continue
+		}
+		return parent, true
+	}
+	return "", false
+}
+
+type acceptedLanguage struct {
+	tag string
+	q   float64
+}
+
+// Parses an Accept-Language header into its tags, ordered by q-value. Tags with q=0 are dropped.
+func ParseAcceptLanguage(header string) []string {
+	accepted := []acceptedLanguage{}
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if len(tag) == 0 {
+			continue
+		}
+		q := 1.0
+		if val, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(val, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if q <= 0 {
+			continue
+		}
+		accepted = append(accepted, acceptedLanguage{tag: strings.TrimSpace(tag), q: q})
+	}
+	sort.SliceStable(accepted, func(i, j int) bool { return accepted[i].q > accepted[j].q })
+	tags := make([]string, len(accepted))
+	for i, lang := range accepted {
+		tags[i] = lang.tag
+	}
+	return tags
+}
+
+// Picks the locale of a request: the X-LOCALE header, the preferred locale of the user, Accept-Language and
+// finally the default locale. Each of them is matched against the valid locales, the result is kept for the request.
+func negotiateLocale(c *gin.Context) string {
+	if c == nil {
+		return DefaultLocale()
+	}
+	if locale := c.GetString(CTX_LOCALE); len(locale) > 0 {
+		return locale
+	}
+	locale := DefaultLocale()
+	if match, ok := MatchLocale(c.GetHeader("X-LOCALE")); ok {
+		locale = match
+	} else if match, ok := matchUserLocale(c); ok {
+		locale = match
+	} else {
+		for _, tag := range ParseAcceptLanguage(c.GetHeader("Accept-Language")) {
+			if tag == "*" {
+				break
+			}
+			if match, ok := MatchLocale(tag); ok {
+				locale = match
+				break
+			}
+		}
+	}
+	c.Set(CTX_LOCALE, locale)
+	return locale
+}
+
+func matchUserLocale(c *gin.Context) (string, bool) {
+	if UserLocaleCallback == nil {
+		return "", false
+	}
+	return MatchLocale(UserLocaleCallback(c))
+}
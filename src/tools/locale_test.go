@@ -0,0 +1,100 @@
+package tools
+
+import (
+	"reflect"
+	"testing"
+)
+
+// Registers the valid locales and a fallback chain for the duration of a test
+func withLocales(t *testing.T, valid ...string) {
+	validator, fallbacks, def := ValidatorCallback, localeFallbacks, defaultLocale
+	t.Cleanup(func() {
+		ValidatorCallback, localeFallbacks, defaultLocale = validator, fallbacks, def
+	})
+	locales := map[string]bool{}
+	for _, locale := range valid {
+		locales[locale] = true
+	}
+	ValidatorCallback = func(locale string) bool { return locales[locale] }
+	SetDefaultLocale("en_EN")
+	SetLocaleFallbacks(map[string][]string{"de-li": {"de-ch"}})
+}
+
+func TestNormalizeLocale(t *testing.T) {
+	tests := map[string]string{
+		"de-ch":      "de_CH",
+		"DE_de":      "de_DE",
+		" en ":       "en",
+		"ZH-hant-tw": "zh_Hant_TW",
+		"sr_latn":    "sr_Latn",
+	}
+	for tag, want := range tests {
+		if got := NormalizeLocale(tag); got != want {
+			t.Errorf("NormalizeLocale(%q) = %q, want %q", tag, got, want)
+		}
+	}
+}
+
+func TestLocaleChain(t *testing.T) {
+	withLocales(t, "en_EN", "de_DE", "de_CH")
+	tests := map[string][]string{
+		"de_LI": {"de_LI", "de_CH", "de_DE", "en_EN"},
+		"de_AT": {"de_AT", "de_DE", "en_EN"},
+		"de_DE": {"de_DE", "en_EN"},
+		"en_EN": {"en_EN"},
+		"fr_CA": {"fr_CA", "en_EN"},
+	}
+	for locale, want := range tests {
+		if got := LocaleChain(locale); !reflect.DeepEqual(got, want) {
+			t.Errorf("LocaleChain(%q) = %v, want %v", locale, got, want)
+		}
+	}
+}
+
+func TestMatchLocale(t *testing.T) {
+	withLocales(t, "en_EN", "de_DE", "de_CH", "zh_Hant_TW")
+	tests := []struct {
+		tag  string
+		want string
+		ok   bool
+	}{
+		{"de-ch", "de_CH", true},
+		{"de-AT", "de_DE", true},
+		{"de-LI", "de_CH", true},
+		{"de", "de_DE", true},
+		{"en-US", "en_EN", true},
+		{"zh-hant-tw", "zh_Hant_TW", true},
+		// The default locale isn't a match for other languages
+		{"es-ES", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := MatchLocale(tt.tag)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("MatchLocale(%q) = %q, %v, want %q, %v", tt.tag, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestParseAcceptLanguage(t *testing.T) {
+	tests := []struct {
+		header string
+		want   []string
+	}{
+		{"", []string{}},
+		{"de", []string{"de"}},
+		{"fr-CH, fr;q=0.9, en;q=0.8, de;q=0.7, *;q=0.5", []string{"fr-CH", "fr", "en", "de", "*"}},
+		{"en;q=0.5, de-AT", []string{"de-AT", "en"}},
+		// Equal q-values keep the order of the header
+		{"a;q=0.5, b, c;q=0.5", []string{"b", "a", "c"}},
+		{"en;q=0, de", []string{"de"}},
+		{"en;q=abc, de", []string{"de"}},
+		{" , de ,, ", []string{"de"}},
+		{"de ; q=0.3, en", []string{"en", "de"}},
+	}
+	for _, tt := range tests {
+		if got := ParseAcceptLanguage(tt.header); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseAcceptLanguage(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,444 @@
+package tools
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// Named arguments of a message, see FormatMessage
+type Args map[string]any
+
+// Parsed messages by pattern, translations are formatted over and over again
+var messageCache sync.Map
+
+type msgPart struct {
+	text  string
+	arg   *msgArg
+	pound bool
+}
+
+type msgArg struct {
+	name    string
+	kind    string
+	style   string
+	offset  float64
+	options []msgOption
+}
+
+type msgOption struct {
+	selector string
+	parts    []msgPart
+}
+
+// Formats an ICU style message pattern with named arguments for the locale. Supported are
+//
+//	{name}                                      the argument, numbers are formatted for the locale
+//	{count, number} / {count, number, integer}  a formatted number, "percent" multiplies by 100
+//	{count, plural, =0 {none} one {# item} other {# items}}
+//	{place, selectordinal, one {#st} two {#nd} few {#rd} other {#th}}
+//	{gender, select, female {she} male {he} other {they}}
+//	{day, date} / {day, time}                   time.Time values
+//
+// Plural categories follow the CLDR rules of the locale, "offset:n" subtracts n from the number before the category is picked.
+// Inside plural messages # is replaced by the formatted number. Apostrophes quote syntax characters like '{' or '#', two apostrophes in a row print one.
+// Simple placeholders without an argument are kept as they are.
+func FormatMessage(locale string, pattern string, args Args) (string, error) {
+	parts, err := parseMessageCached(pattern)
+	if err != nil {
+		return pattern, err
+	}
+	var out strings.Builder
+	if err := formatParts(&out, parts, locale, args, nil); err != nil {
+		return pattern, err
+	}
+	return out.String(), nil
+}
+
+// Checks the syntax of a message pattern
+func ValidateMessage(pattern string) error {
+	_, err := parseMessageCached(pattern)
+	return err
+}
+
+func parseMessageCached(pattern string) ([]msgPart, error) {
+	if parts, ok := messageCache.Load(pattern); ok {
+		return parts.([]msgPart), nil
+	}
+	p := &msgParser{src: []rune(pattern)}
+	parts, err := p.parse(0, false)
+	if err != nil {
+		return nil, err
+	}
+	messageCache.Store(pattern, parts)
+	return parts, nil
+}
+
+type msgParser struct {
+	src []rune
+	pos int
+}
+
+func (p *msgParser) errorf(format string, v ...any) error {
+	return fmt.Errorf("invalid message at %d: "+format, append([]any{p.pos}, v...)...)
+}
+
+// Parses text and arguments until the end of input or the closing brace of the enclosing option
+func (p *msgParser) parse(depth int, inPlural bool) ([]msgPart, error) {
+	parts := []msgPart{}
+	var text strings.Builder
+	flush := func() {
+		if text.Len() > 0 {
+			parts = append(parts, msgPart{text: text.String()})
+			text.Reset()
+		}
+	}
+	for p.pos < len(p.src) {
+		r := p.src[p.pos]
+		switch {
+		case r == '\'':
+			p.pos++
+			if p.pos < len(p.src) && p.src[p.pos] == '\'' {
+				text.WriteRune('\'')
+				p.pos++
+			} else if p.pos < len(p.src) && (p.src[p.pos] == '{' || p.src[p.pos] == '}' || (inPlural && p.src[p.pos] == '#')) {
+				p.quoted(&text)
+			} else {
+				text.WriteRune('\'')
+			}
+		case r == '{':
+			flush()
+			arg, err := p.parseArg(depth, inPlural)
+			if err != nil {
+				return nil, err
+			}
+			parts = append(parts, msgPart{arg: arg})
+		case r == '}':
+			if depth == 0 {
+				return nil, p.errorf("unmatched }")
+			}
+			flush()
+			return parts, nil
+		case r == '#' && inPlural:
+			flush()
+			parts = append(parts, msgPart{pound: true})
+			p.pos++
+		default:
+			text.WriteRune(r)
+			p.pos++
+		}
+	}
+	if depth > 0 {
+		return nil, p.errorf("unclosed {")
+	}
+	flush()
+	return parts, nil
+}
+
+// Reads quoted literal text up to the closing apostrophe, two apostrophes in a row stay one
+func (p *msgParser) quoted(text *strings.Builder) {
+	for p.pos < len(p.src) {
+		r := p.src[p.pos]
+		p.pos++
+		if r != '\'' {
+			text.WriteRune(r)
+			continue
+		}
+		if p.pos < len(p.src) && p.src[p.pos] == '\'' {
+			text.WriteRune('\'')
+			p.pos++
+			continue
+		}
+		return
+	}
+}
+
+func (p *msgParser) parseArg(depth int, inPlural bool) (*msgArg, error) {
+	p.pos++
+	arg := &msgArg{name: p.ident()}
+	if len(arg.name) == 0 {
+		return nil, p.errorf("missing argument name")
+	}
+	if p.consume('}') {
+		return arg, nil
+	}
+	if !p.consume(',') {
+		return nil, p.errorf("expected , or } after %s", arg.name)
+	}
+	arg.kind = p.ident()
+	if p.consume('}') {
+		return arg, nil
+	}
+	if !p.consume(',') {
+		return nil, p.errorf("expected , or } after %s", arg.kind)
+	}
+	switch arg.kind {
+	case "plural", "selectordinal":
+		return arg, p.parseOptions(arg, depth, true)
+	case "select":
+		return arg, p.parseOptions(arg, depth, inPlural)
+	}
+	start := p.pos
+	for p.pos < len(p.src) && p.src[p.pos] != '}' {
+		p.pos++
+	}
+	arg.style = strings.TrimSpace(string(p.src[start:p.pos]))
+	if !p.consume('}') {
+		return nil, p.errorf("unclosed {")
+	}
+	return arg, nil
+}
+
+func (p *msgParser) parseOptions(arg *msgArg, depth int, inPlural bool) error {
+	for {
+		p.skipSpace()
+		if p.consume('}') {
+			break
+		}
+		if p.pos >= len(p.src) {
+			return p.errorf("unclosed {")
+		}
+		selector := p.ident()
+		if arg.kind == "plural" && strings.HasPrefix(selector, "offset:") {
+			offset, err := strconv.ParseFloat(strings.TrimPrefix(selector, "offset:"), 64)
+			if err != nil {
+				return p.errorf("invalid offset %s", selector)
+			}
+			arg.offset = offset
+			continue
+		}
+		if len(selector) == 0 {
+			return p.errorf("missing selector in %s", arg.name)
+		}
+		if !p.consume('{') {
+			return p.errorf("expected { after %s", selector)
+		}
+		parts, err := p.parse(depth+1, inPlural)
+		if err != nil {
+			return err
+		}
+		p.pos++
+		arg.options = append(arg.options, msgOption{selector: selector, parts: parts})
+	}
+	for _, option := range arg.options {
+		if option.selector == PLURAL_OTHER {
+			return nil
+		}
+	}
+	return p.errorf("%s has no other option", arg.name)
+}
+
+func (p *msgParser) ident() string {
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.src) {
+		r := p.src[p.pos]
+		if unicode.IsSpace(r) || r == ',' || r == '{' || r == '}' || r == '\'' || r == '#' {
+			break
+		}
+		p.pos++
+	}
+	return string(p.src[start:p.pos])
+}
+
+func (p *msgParser) skipSpace() {
+	for p.pos < len(p.src) && unicode.IsSpace(p.src[p.pos]) {
+		p.pos++
+	}
+}
+
+func (p *msgParser) consume(r rune) bool {
+	p.skipSpace()
+	if p.pos < len(p.src) && p.src[p.pos] == r {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// Writes the parts, pound is the number # stands for within plural options
+func formatParts(out *strings.Builder, parts []msgPart, locale string, args Args, pound any) error {
+	for _, part := range parts {
+		switch {
+		case part.arg != nil:
+			if err := formatArg(out, part.arg, locale, args, pound); err != nil {
+				return err
+			}
+		case part.pound:
+			if pound == nil {
+				out.WriteRune('#')
+			} else {
+				out.WriteString(FormatNumber(locale, pound))
+			}
+		default:
+			out.WriteString(part.text)
+		}
+	}
+	return nil
+}
+
+func formatArg(out *strings.Builder, arg *msgArg, locale string, args Args, pound any) error {
+	val, ok := args[arg.name]
+	if !ok {
+		if len(arg.options) > 0 {
+			return errors.New("missing argument " + arg.name)
+		}
+		out.WriteString("{" + arg.name + "}")
+		return nil
+	}
+	switch arg.kind {
+	case "plural", "selectordinal":
+		number, ok := numberText(val)
+		if !ok {
+			return fmt.Errorf("argument %s is not a number", arg.name)
+		}
+		n, _ := strconv.ParseFloat(number, 64)
+		var shifted any = val
+		if arg.offset != 0 {
+			shifted = n - arg.offset
+		}
+		category := ""
+		if arg.kind == "plural" {
+			category = PluralCategory(locale, shifted)
+		} else {
+			category = OrdinalCategory(locale, shifted)
+		}
+		return formatParts(out, arg.choose(func(selector string) bool {
+			if exact, ok := strings.CutPrefix(selector, "="); ok {
+				e, err := strconv.ParseFloat(exact, 64)
+				return err == nil && e == n
+			}
+			return false
+		}, category), locale, args, shifted)
+	case "select":
+		return formatParts(out, arg.choose(nil, fmt.Sprint(val)), locale, args, pound)
+	case "number":
+		out.WriteString(formatNumberStyle(locale, val, arg.style))
+	case "date", "time":
+		if t, ok := val.(time.Time); ok {
+			if arg.kind == "date" {
+				out.WriteString(t.Format(dateLayout(locale)))
+			} else {
+				out.WriteString(t.Format("15:04"))
+			}
+			return nil
+		}
+		out.WriteString(fmt.Sprint(val))
+	default:
+		if _, ok := numberText(val); ok {
+			if _, isString := val.(string); !isString {
+				out.WriteString(FormatNumber(locale, val))
+				return nil
+			}
+		}
+		out.WriteString(fmt.Sprint(val))
+	}
+	return nil
+}
+
+// Picks the first option matching exactly, then the one named selector, then other
+func (arg *msgArg) choose(exact func(selector string) bool, selector string) []msgPart {
+	if exact != nil {
+		for _, option := range arg.options {
+			if exact(option.selector) {
+				return option.parts
+			}
+		}
+	}
+	var other []msgPart
+	for _, option := range arg.options {
+		if option.selector == selector {
+			return option.parts
+		}
+		if option.selector == PLURAL_OTHER {
+			other = option.parts
+		}
+	}
+	return other
+}
+
+// Decimal and grouping separators per language, languages without an entry format like en
+var numberSeparators = map[string][2]string{
+	"de": {",", "."}, "nl": {",", "."}, "it": {",", "."}, "es": {",", "."}, "pt": {",", "."}, "da": {",", "."},
+	"tr": {",", "."}, "el": {",", "."}, "ro": {",", "."}, "hr": {",", "."}, "sr": {",", "."}, "id": {",", "."},
+	"fr": {",", " "}, "ru": {",", " "}, "uk": {",", " "}, "pl": {",", " "}, "cs": {",", " "},
+	"sk": {",", " "}, "sv": {",", " "}, "fi": {",", " "}, "nb": {",", " "}, "lt": {",", " "},
+	"hu": {",", " "}, "bg": {",", " "},
+}
+
+// Languages that only group numbers with five or more integer digits
+var minGrouping2 = map[string]bool{"es": true, "pl": true}
+
+// Formats a number with the decimal and grouping separators of the locale, e.g. 1234.5 as 1.234,5 in de_DE.
+// Values that aren't numbers are printed as they are.
+func FormatNumber(locale string, number any) string {
+	text, ok := numberText(number)
+	if !ok {
+		return fmt.Sprint(number)
+	}
+	lang := language(locale)
+	separators, ok := numberSeparators[lang]
+	if !ok {
+		separators = [2]string{".", ","}
+	}
+	sign := ""
+	if strings.HasPrefix(text, "-") {
+		sign, text = "-", text[1:]
+	}
+	integer, fraction, hasFraction := strings.Cut(strings.TrimPrefix(text, "+"), ".")
+	minDigits := 4
+	if minGrouping2[lang] {
+		minDigits = 5
+	}
+	if len(integer) >= minDigits {
+		var grouped strings.Builder
+		for i, r := range integer {
+			if i > 0 && (len(integer)-i)%3 == 0 {
+				grouped.WriteString(separators[1])
+			}
+			grouped.WriteRune(r)
+		}
+		integer = grouped.String()
+	}
+	if hasFraction {
+		return sign + integer + separators[0] + fraction
+	}
+	return sign + integer
+}
+
+func formatNumberStyle(locale string, val any, style string) string {
+	text, ok := numberText(val)
+	if !ok {
+		return fmt.Sprint(val)
+	}
+	n, _ := strconv.ParseFloat(text, 64)
+	switch style {
+	case "integer":
+		return FormatNumber(locale, int64(math.Round(n)))
+	case "percent":
+		return FormatNumber(locale, int64(math.Round(n*100))) + "%"
+	}
+	return FormatNumber(locale, val)
+}
+
+func dateLayout(locale string) string {
+	switch language(locale) {
+	case "en":
+		if strings.HasSuffix(locale, "US") {
+			return "01/02/2006"
+		}
+		return "02/01/2006"
+	case "de", "ru", "pl", "cs", "sk", "fi", "nb", "da", "tr", "ro", "uk":
+		return "02.01.2006"
+	case "nl":
+		return "02-01-2006"
+	case "fr", "es", "it", "pt", "el":
+		return "02/01/2006"
+	}
+	return "2006-01-02"
+}
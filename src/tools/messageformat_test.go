@@ -0,0 +1,105 @@
+package tools
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFormatMessage(t *testing.T) {
+	day := time.Date(2024, 6, 1, 10, 7, 0, 0, time.UTC)
+	others := "{count, plural, offset:1 =0 {nobody} =1 {{name}} one {{name} and # other} other {{name} and # others}}"
+	ordinal := "{place, selectordinal, one {#st} two {#nd} few {#rd} other {#th}}"
+	gender := "{gender, select, female {she} male {he} other {they}}"
+	tests := []struct {
+		locale  string
+		pattern string
+		args    Args
+		want    string
+	}{
+		{"en_EN", "Hello {name}!", Args{"name": "Anna"}, "Hello Anna!"},
+		{"en_EN", "Hello {name}!", nil, "Hello {name}!"},
+		{"en_EN", "{count} items", Args{"count": 1234}, "1,234 items"},
+		{"de_DE", "{count} Artikel", Args{"count": 1234.5}, "1.234,5 Artikel"},
+		{"fr_FR", "{count}", Args{"count": 1234567}, "1\u202f234\u202f567"},
+		{"es_ES", "{count}", Args{"count": 1234}, "1234"},
+		{"en_EN", "{code}", Args{"code": "1234"}, "1234"},
+		{"en_EN", "{n, number, integer}", Args{"n": 2.6}, "3"},
+		{"en_EN", "{n, number, percent}", Args{"n": 0.25}, "25%"},
+		{"en_EN", "{count, plural, =0 {none} one {# item} other {# items}}", Args{"count": 0}, "none"},
+		{"en_EN", "{count, plural, =0 {none} one {# item} other {# items}}", Args{"count": 1}, "1 item"},
+		{"de_DE", "{count, plural, one {# Artikel} other {# Artikel}}", Args{"count": 1500}, "1.500 Artikel"},
+		{"ru_RU", "{n, plural, one {# файл} few {# файла} many {# файлов} other {# файла}}", Args{"n": 22}, "22 файла"},
+		{"en_EN", others, Args{"count": 1, "name": "Anna"}, "Anna"},
+		{"en_EN", others, Args{"count": 2, "name": "Anna"}, "Anna and 1 other"},
+		{"en_EN", others, Args{"count": 3, "name": "Anna"}, "Anna and 2 others"},
+		{"en_EN", ordinal, Args{"place": 22}, "22nd"},
+		{"en_EN", ordinal, Args{"place": 11}, "11th"},
+		{"en_EN", gender, Args{"gender": "female"}, "she"},
+		{"en_EN", gender, Args{"gender": "unknown"}, "they"},
+		{"en_EN", "{n, plural, one {{g, select, female {her #} other {their #}}} other {#}}", Args{"n": 1, "g": "female"}, "her 1"},
+		{"en_EN", "It''s '{literal}' and don't", nil, "It's {literal} and don't"},
+		{"en_EN", "{n, plural, other {'#' #}}", Args{"n": 3}, "# 3"},
+		{"en_EN", "# outside plural", nil, "# outside plural"},
+		{"de_DE", "{day, date}", Args{"day": day}, "01.06.2024"},
+		{"en_US", "{day, date}", Args{"day": day}, "06/01/2024"},
+		{"en_EN", "{day, time}", Args{"day": day}, "10:07"},
+	}
+	for _, tt := range tests {
+		got, err := FormatMessage(tt.locale, tt.pattern, tt.args)
+		if err != nil {
+			t.Errorf("FormatMessage(%q, %q): %v", tt.locale, tt.pattern, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("FormatMessage(%q, %q) = %q, want %q", tt.locale, tt.pattern, got, tt.want)
+		}
+	}
+}
+
+func TestFormatMessageArgumentErrors(t *testing.T) {
+	tests := []struct {
+		pattern string
+		args    Args
+	}{
+		{"{count, plural, one {#} other {#}}", nil},
+		{"{count, plural, one {#} other {#}}", Args{"count": "many"}},
+		{"{gender, select, other {they}}", Args{}},
+	}
+	for _, tt := range tests {
+		got, err := FormatMessage("en_EN", tt.pattern, tt.args)
+		if err == nil {
+			t.Errorf("FormatMessage(%q, %v): expected an error", tt.pattern, tt.args)
+		}
+		if got != tt.pattern {
+			t.Errorf("FormatMessage(%q, %v) = %q, want the pattern on errors", tt.pattern, tt.args, got)
+		}
+	}
+}
+
+func TestValidateMessage(t *testing.T) {
+	tests := []struct {
+		pattern string
+		valid   bool
+	}{
+		{"plain text", true},
+		{"{name}", true},
+		{"{count, plural, one {#} other {#}}", true},
+		{"'{' and '}' quoted", true},
+		{"{", false},
+		{"}", false},
+		{"{name", false},
+		{"{ }", false},
+		{"{name foo}", false},
+		{"{n, number", false},
+		{"{n, plural, one {x}}", false},
+		{"{n, plural, other {x}", false},
+		{"{n, plural, other x}", false},
+		{"{n, plural, offset:x other {x}}", false},
+		{"{n, select, {x} other {y}}", false},
+	}
+	for _, tt := range tests {
+		if err := ValidateMessage(tt.pattern); (err == nil) != tt.valid {
+			t.Errorf("ValidateMessage(%q) = %v, want valid %v", tt.pattern, err, tt.valid)
+		}
+	}
+}
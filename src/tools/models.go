@@ -12,8 +12,24 @@ type GinRoute struct {
 	Endpoint   string
 	Handler    gin.HandlerFunc
 	Permission uint
+	// Optional scope a service token needs to call this route, user tokens are not affected
+	Scope string
+	// Successful GET responses are cached for CacheTTL if set, keyed by path and the CacheVary rules
+	// (VARY_USER, VARY_LOCALE, VARY_QUERY). CacheTags allow invalidating them from code.
+	CacheTTL  time.Duration
+	CacheVary []string
+	CacheTags []string
+	// Optional, skips the cached response and stores a fresh one if it returns true. Runs before the lookup,
+	// so it can also invalidate tags (e.g. on ?invalidate=true).
+	CacheBypass func(c *gin.Context) bool
 }
 
+const (
+	VARY_USER   = "user"
+	VARY_LOCALE = "locale"
+	VARY_QUERY  = "query"
+)
+
 const (
 	PERM_LOGIN = 0
 	PERM_ZERO  = 1
@@ -0,0 +1,281 @@
+package tools
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// CLDR plural categories
+const (
+	PLURAL_ZERO  = "zero"
+	PLURAL_ONE   = "one"
+	PLURAL_TWO   = "two"
+	PLURAL_FEW   = "few"
+	PLURAL_MANY  = "many"
+	PLURAL_OTHER = "other"
+)
+
+// Plural operands as defined by CLDR: absolute value, integer digits, number and value of the visible fraction digits
+type pluralOperands struct {
+	n float64
+	i int64
+	v int
+	f int64
+}
+
+type pluralRule func(o pluralOperands) string
+
+// Cardinal rules per language, locales can override their language (pt_PT). Languages without an entry only use "other".
+var cardinalRules = map[string]pluralRule{
+	"en": oneIfInteger1, "de": oneIfInteger1, "nl": oneIfInteger1, "sv": oneIfInteger1, "it": oneIfInteger1,
+	"ca": oneIfInteger1, "et": oneIfInteger1, "fi": oneIfInteger1, "gl": oneIfInteger1, "pt_PT": oneIfInteger1,
+	"es": oneIfN1, "tr": oneIfN1, "el": oneIfN1, "hu": oneIfN1, "bg": oneIfN1, "nb": oneIfN1, "da": oneIfN1,
+	"fr": oneIf0or1, "pt": oneIf0or1,
+	"hi": oneIf0orN1, "bn": oneIf0orN1, "fa": oneIf0orN1,
+	"ru": slavicRule, "uk": slavicRule, "be": slavicRule,
+	"hr": bosnianRule, "sr": bosnianRule, "bs": bosnianRule,
+	"pl": func(o pluralOperands) string {
+		i10, i100 := o.i%10, o.i%100
+		switch {
+		case o.i == 1 && o.v == 0:
+			return PLURAL_ONE
+		case o.v == 0 && i10 >= 2 && i10 <= 4 && (i100 < 12 || i100 > 14):
+			return PLURAL_FEW
+		case o.v == 0:
+			return PLURAL_MANY
+		}
+		return PLURAL_OTHER
+	},
+	"cs": czechRule, "sk": czechRule,
+	"ro": func(o pluralOperands) string {
+		n100 := math.Mod(o.n, 100)
+		switch {
+		case o.i == 1 && o.v == 0:
+			return PLURAL_ONE
+		case o.v != 0 || o.n == 0 || (o.n != 1 && n100 >= 1 && n100 <= 19 && n100 == math.Trunc(n100)):
+			return PLURAL_FEW
+		}
+		return PLURAL_OTHER
+	},
+	"lt": func(o pluralOperands) string {
+		n10, n100 := math.Mod(o.n, 10), math.Mod(o.n, 100)
+		teen := n100 >= 11 && n100 <= 19
+		switch {
+		case o.f != 0:
+			return PLURAL_MANY
+		case n10 == 1 && !teen:
+			return PLURAL_ONE
+		case n10 >= 2 && n10 <= 9 && !teen:
+			return PLURAL_FEW
+		}
+		return PLURAL_OTHER
+	},
+	"he": func(o pluralOperands) string {
+		switch {
+		case (o.i == 1 && o.v == 0) || (o.i == 0 && o.v != 0):
+			return PLURAL_ONE
+		case o.i == 2 && o.v == 0:
+			return PLURAL_TWO
+		}
+		return PLURAL_OTHER
+	},
+	"ar": func(o pluralOperands) string {
+		n100 := math.Mod(o.n, 100)
+		switch {
+		case o.n == 0:
+			return PLURAL_ZERO
+		case o.n == 1:
+			return PLURAL_ONE
+		case o.n == 2:
+			return PLURAL_TWO
+		case n100 >= 3 && n100 <= 10 && n100 == math.Trunc(n100):
+			return PLURAL_FEW
+		case n100 >= 11 && n100 <= 99 && n100 == math.Trunc(n100):
+			return PLURAL_MANY
+		}
+		return PLURAL_OTHER
+	},
+}
+
+// Ordinal rules per language, used by selectordinal
+var ordinalRules = map[string]pluralRule{
+	"en": func(o pluralOperands) string {
+		n10, n100 := math.Mod(o.n, 10), math.Mod(o.n, 100)
+		switch {
+		case n10 == 1 && n100 != 11:
+			return PLURAL_ONE
+		case n10 == 2 && n100 != 12:
+			return PLURAL_TWO
+		case n10 == 3 && n100 != 13:
+			return PLURAL_FEW
+		}
+		return PLURAL_OTHER
+	},
+	"fr": oneIfN1,
+	"it": func(o pluralOperands) string {
+		if o.n == 11 || o.n == 8 || o.n == 80 || o.n == 800 {
+			return PLURAL_MANY
+		}
+		return PLURAL_OTHER
+	},
+	"sv": func(o pluralOperands) string {
+		n10, n100 := math.Mod(o.n, 10), math.Mod(o.n, 100)
+		if (n10 == 1 || n10 == 2) && n100 != 11 && n100 != 12 {
+			return PLURAL_ONE
+		}
+		return PLURAL_OTHER
+	},
+}
+
+func oneIfInteger1(o pluralOperands) string {
+	if o.i == 1 && o.v == 0 {
+		return PLURAL_ONE
+	}
+	return PLURAL_OTHER
+}
+
+func oneIfN1(o pluralOperands) string {
+	if o.n == 1 {
+		return PLURAL_ONE
+	}
+	return PLURAL_OTHER
+}
+
+func oneIf0or1(o pluralOperands) string {
+	if o.i == 0 || o.i == 1 {
+		return PLURAL_ONE
+	}
+	return PLURAL_OTHER
+}
+
+func oneIf0orN1(o pluralOperands) string {
+	if o.i == 0 || o.n == 1 {
+		return PLURAL_ONE
+	}
+	return PLURAL_OTHER
+}
+
+func slavicRule(o pluralOperands) string {
+	if o.v != 0 {
+		return PLURAL_OTHER
+	}
+	i10, i100 := o.i%10, o.i%100
+	switch {
+	case i10 == 1 && i100 != 11:
+		return PLURAL_ONE
+	case i10 >= 2 && i10 <= 4 && (i100 < 12 || i100 > 14):
+		return PLURAL_FEW
+	}
+	return PLURAL_MANY
+}
+
+func bosnianRule(o pluralOperands) string {
+	i10, i100, f10, f100 := o.i%10, o.i%100, o.f%10, o.f%100
+	switch {
+	case (o.v == 0 && i10 == 1 && i100 != 11) || (f10 == 1 && f100 != 11):
+		return PLURAL_ONE
+	case (o.v == 0 && i10 >= 2 && i10 <= 4 && (i100 < 12 || i100 > 14)) || (f10 >= 2 && f10 <= 4 && (f100 < 12 || f100 > 14)):
+		return PLURAL_FEW
+	}
+	return PLURAL_OTHER
+}
+
+func czechRule(o pluralOperands) string {
+	switch {
+	case o.i == 1 && o.v == 0:
+		return PLURAL_ONE
+	case o.i >= 2 && o.i <= 4 && o.v == 0:
+		return PLURAL_FEW
+	case o.v != 0:
+		return PLURAL_MANY
+	}
+	return PLURAL_OTHER
+}
+
+// Returns the CLDR plural category of the number for the locale, e.g. "one" for 1 and "other" for 2 in en_EN.
+// Numbers can be of any integer or float type or a numeric string, "1.0" keeps its visible fraction digit.
+func PluralCategory(locale string, number any) string {
+	return pluralCategory(cardinalRules, locale, number)
+}
+
+// Like PluralCategory but for ordinals, e.g. "two" for 2 in en_EN (2nd)
+func OrdinalCategory(locale string, number any) string {
+	return pluralCategory(ordinalRules, locale, number)
+}
+
+func pluralCategory(rules map[string]pluralRule, locale string, number any) string {
+	o, ok := operands(number)
+	if !ok {
+		return PLURAL_OTHER
+	}
+	if rule, ok := rules[locale]; ok {
+		return rule(o)
+	}
+	if rule, ok := rules[language(locale)]; ok {
+		return rule(o)
+	}
+	return PLURAL_OTHER
+}
+
+func operands(number any) (pluralOperands, bool) {
+	text, ok := numberText(number)
+	if !ok {
+		return pluralOperands{}, false
+	}
+	text = strings.TrimPrefix(text, "-")
+	n, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return pluralOperands{}, false
+	}
+	o := pluralOperands{n: n}
+	integer, fraction, _ := strings.Cut(text, ".")
+	o.i, _ = strconv.ParseInt(integer, 10, 64)
+	o.v = len(fraction)
+	if o.v > 0 {
+		o.f, _ = strconv.ParseInt(fraction, 10, 64)
+	}
+	return o, true
+}
+
+// Plain decimal representation of a number, without exponent or grouping
+func numberText(number any) (string, bool) {
+	switch val := number.(type) {
+	case int:
+		return strconv.FormatInt(int64(val), 10), true
+	case int8:
+		return strconv.FormatInt(int64(val), 10), true
+	case int16:
+		return strconv.FormatInt(int64(val), 10), true
+	case int32:
+		return strconv.FormatInt(int64(val), 10), true
+	case int64:
+		return strconv.FormatInt(val, 10), true
+	case uint:
+		return strconv.FormatUint(uint64(val), 10), true
+	case uint8:
+		return strconv.FormatUint(uint64(val), 10), true
+	case uint16:
+		return strconv.FormatUint(uint64(val), 10), true
+	case uint32:
+		return strconv.FormatUint(uint64(val), 10), true
+	case uint64:
+		return strconv.FormatUint(val, 10), true
+	case float32:
+		return strconv.FormatFloat(float64(val), 'f', -1, 32), true
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64), true
+	case string:
+		if _, err := strconv.ParseFloat(val, 64); err != nil || strings.Trim(val, "+-.0123456789") != "" {
+			return "", false
+		}
+		return val, true
+	}
+	return "", false
+}
+
+// Language part of a locale, "de" for de_DE and de-AT
+func language(locale string) string {
+	lang, _, _ := strings.Cut(strings.ReplaceAll(locale, "-", "_"), "_")
+	return strings.ToLower(lang)
+}
@@ -0,0 +1,94 @@
+package tools
+
+import "testing"
+
+func TestPluralCategory(t *testing.T) {
+	tests := []struct {
+		locale string
+		number any
+		want   string
+	}{
+		{"en_EN", 1, PLURAL_ONE},
+		{"en_EN", 0, PLURAL_OTHER},
+		{"en_EN", 2, PLURAL_OTHER},
+		{"en_EN", -1, PLURAL_ONE},
+		{"en_EN", "1.0", PLURAL_OTHER},
+		{"en_EN", 1.5, PLURAL_OTHER},
+		{"de-AT", 1, PLURAL_ONE},
+		{"es_ES", "1.0", PLURAL_ONE},
+		{"fr_FR", 0, PLURAL_ONE},
+		{"fr_FR", 1.5, PLURAL_ONE},
+		{"fr_FR", 2, PLURAL_OTHER},
+		{"pt_BR", 0, PLURAL_ONE},
+		{"pt_PT", 0, PLURAL_OTHER},
+		{"ru_RU", 1, PLURAL_ONE},
+		{"ru_RU", 21, PLURAL_ONE},
+		{"ru_RU", 11, PLURAL_MANY},
+		{"ru_RU", 3, PLURAL_FEW},
+		{"ru_RU", 22, PLURAL_FEW},
+		{"ru_RU", 13, PLURAL_MANY},
+		{"ru_RU", 5, PLURAL_MANY},
+		{"ru_RU", 1.5, PLURAL_OTHER},
+		{"pl_PL", 1, PLURAL_ONE},
+		{"pl_PL", 21, PLURAL_MANY},
+		{"pl_PL", 24, PLURAL_FEW},
+		{"pl_PL", 2.5, PLURAL_OTHER},
+		{"hr_HR", "0.1", PLURAL_ONE},
+		{"hr_HR", "0.2", PLURAL_FEW},
+		{"hr_HR", 5, PLURAL_OTHER},
+		{"cs_CZ", 3, PLURAL_FEW},
+		{"cs_CZ", 0.5, PLURAL_MANY},
+		{"cs_CZ", 5, PLURAL_OTHER},
+		{"ro_RO", 0, PLURAL_FEW},
+		{"ro_RO", 19, PLURAL_FEW},
+		{"ro_RO", 20, PLURAL_OTHER},
+		{"ro_RO", 101, PLURAL_FEW},
+		{"lt_LT", 21, PLURAL_ONE},
+		{"lt_LT", 11, PLURAL_OTHER},
+		{"lt_LT", 9, PLURAL_FEW},
+		{"lt_LT", 0.5, PLURAL_MANY},
+		{"he_IL", 2, PLURAL_TWO},
+		{"ar_SA", 0, PLURAL_ZERO},
+		{"ar_SA", 2, PLURAL_TWO},
+		{"ar_SA", 105, PLURAL_FEW},
+		{"ar_SA", 111, PLURAL_MANY},
+		{"ar_SA", 100, PLURAL_OTHER},
+		{"ja_JP", 1, PLURAL_OTHER},
+		{"en_EN", "abc", PLURAL_OTHER},
+		{"en_EN", nil, PLURAL_OTHER},
+	}
+	for _, tt := range tests {
+		if got := PluralCategory(tt.locale, tt.number); got != tt.want {
+			t.Errorf("PluralCategory(%q, %v) = %q, want %q", tt.locale, tt.number, got, tt.want)
+		}
+	}
+}
+
+func TestOrdinalCategory(t *testing.T) {
+	tests := []struct {
+		locale string
+		number any
+		want   string
+	}{
+		{"en_EN", 1, PLURAL_ONE},
+		{"en_EN", 2, PLURAL_TWO},
+		{"en_EN", 3, PLURAL_FEW},
+		{"en_EN", 4, PLURAL_OTHER},
+		{"en_EN", 11, PLURAL_OTHER},
+		{"en_EN", 12, PLURAL_OTHER},
+		{"en_EN", 13, PLURAL_OTHER},
+		{"en_EN", 21, PLURAL_ONE},
+		{"en_EN", 102, PLURAL_TWO},
+		{"fr_FR", 1, PLURAL_ONE},
+		{"fr_FR", 2, PLURAL_OTHER},
+		{"it_IT", 8, PLURAL_MANY},
+		{"sv_SE", 22, PLURAL_ONE},
+		{"sv_SE", 12, PLURAL_OTHER},
+		{"de_DE", 1, PLURAL_OTHER},
+	}
+	for _, tt := range tests {
+		if got := OrdinalCategory(tt.locale, tt.number); got != tt.want {
+			t.Errorf("OrdinalCategory(%q, %v) = %q, want %q", tt.locale, tt.number, got, tt.want)
+		}
+	}
+}
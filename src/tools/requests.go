@@ -29,7 +29,7 @@ func GetPaged[T any](c *gin.Context, db *gorm.DB) (Paging, error) {
 
 	single := new(T)
 	multi := new([]T)
-	availableOrder := deepcorebundle.GetAllowedFilters(single)
+	availableOrder := deepcorebundle.GetAllowedFilters(*single)
 	page, perPage, order, orderDir := getPageInfo(c, availableOrder)
 	var count int64
 
@@ -141,19 +141,26 @@ func RespondWithJSON(c *gin.Context, code int, payload interface{}) {
 
 	tr := tryTranslate(payload, c).(reflect.Value)
 	c.JSON(code, tr.Interface())
-	go logRequestDetails(c, code, tr.Interface())
+	go logRequestDetails(requestLogLine(c, code), code)
 }
 
 func RespondWithJsonSilent(c *gin.Context, code int, payload interface{}) {
 
 	tr := tryTranslate(payload, c).(reflect.Value)
 	c.JSON(code, tr.Interface())
-	//go logRequestDetails(c, code, tr.Interface())
+	//go logRequestDetails(requestLogLine(c, code), code)
 }
 
-func logRequestDetails(c *gin.Context, code int, payload interface{}) {
-	logStr := ""
-	logStr += c.Request.Method + ":" + c.Request.RequestURI + ":" + fmt.Sprint(code) + ":" + c.Request.RemoteAddr
+// Builds the log line while the request is still being handled, the gin context must not be read from the logging goroutine
+func requestLogLine(c *gin.Context, code int) string {
+	logStr := c.Request.Method + ":" + c.Request.RequestURI + ":" + fmt.Sprint(code) + ":" + c.Request.RemoteAddr
+	if impersonator := GetImpersonator(c); impersonator > 0 {
+		logStr += ":impersonated_by=" + Encode(impersonator)
+	}
+	return logStr
+}
+
+func logRequestDetails(logStr string, code int) {
 	if code != http.StatusOK && code != http.StatusAccepted {
 		pour.LogColor(true, pour.ColorRed, logStr)
 		return
@@ -161,40 +168,44 @@ func logRequestDetails(c *gin.Context, code int, payload interface{}) {
 	pour.LogColor(true, pour.ColorWhite, logStr)
 }
 
-func getLocaleFromRequest(c *gin.Context) string {
-	loc := c.GetHeader("X-LOCALE")
-	if ValidatorCallback(loc) {
-		return loc
-	}
-	return "en_EN"
+// Returns the locale of the request, negotiated from the X-LOCALE header, the preferred locale of the user
+// and Accept-Language. Falls back to the default locale (en_EN unless configured).
+func GetLocale(c *gin.Context) string {
+	return getLocaleFromRequest(c)
+}
 
+func getLocaleFromRequest(c *gin.Context) string {
+	return negotiateLocale(c)
 }
 
 func RespondWithError(c *gin.Context, code int, message string) {
 	RespondWithJSON(c, code, map[string]string{"error": message})
 }
 
+// Responds with the error and its translation for the locale of the request.
+// Optional values are the translation key to use instead of the error (e.g. "internal_error") and Args
+// to format the translation with, see FormatMessage.
 func RespondError(err error, code int, c *gin.Context, v ...any) {
 
-	var msg ErrorMessage
-	locale := getLocaleFromRequest(c)
-	if len(v) > 0 {
-		errMessage := fmt.Sprint(v[0])
-		trans, transErr := SingleTranslationCallback(locale, errMessage)
-		if transErr != nil || len(trans) == 0 {
-			trans = errMessage
-		}
-		msg = ErrorMessage{Code: code, Error: err.Error(), Localized: trans}
-		c.JSON(code, msg)
-	} else {
-		trans, transErr := SingleTranslationCallback(locale, err.Error())
-		if transErr != nil || len(trans) == 0 {
-			trans = err.Error()
+	key := err.Error()
+	var args Args
+	overridden := false
+	for _, val := range v {
+		if a, ok := val.(Args); ok {
+			args = a
+		} else if !overridden {
+			key = fmt.Sprint(val)
+			overridden = true
 		}
-		msg = ErrorMessage{Code: code, Error: err.Error(), Localized: trans}
-		c.JSON(code, msg)
 	}
-	go logRequestDetails(c, code, msg)
+	locale := getLocaleFromRequest(c)
+	trans, transErr := SingleTranslationCallback(locale, key)
+	if transErr != nil || len(trans) == 0 {
+		trans = key
+	}
+	msg := ErrorMessage{Code: code, Error: err.Error(), Localized: formatTranslation(locale, trans, args)}
+	c.JSON(code, msg)
+	go logRequestDetails(requestLogLine(c, code), code)
 	c.Error(err)
 }
 
@@ -9,25 +9,38 @@ import (
 )
 
 var routePermissionMap map[string]uint = make(map[string]uint)
+var routeScopeMap map[string]string = make(map[string]string)
+
+type responseCacheOperator func(route GinRoute) gin.HandlerFunc
+
+// Set by the cache bundle, wraps routes with a CacheTTL
+var ResponseCacheCallback responseCacheOperator
 
 func InitHandlers(r *gin.RouterGroup, routes []GinRoute) {
 
 	for _, element := range routes {
 		routePermissionMap[element.Endpoint] = element.Permission
+		if len(element.Scope) > 0 {
+			routeScopeMap[element.Endpoint] = element.Scope
+		}
+		handlers := []gin.HandlerFunc{element.Handler}
+		if element.CacheTTL > 0 && element.Method == http.MethodGet && ResponseCacheCallback != nil {
+			handlers = []gin.HandlerFunc{ResponseCacheCallback(element), element.Handler}
+		}
 		switch element.Method {
 
 		case (http.MethodGet):
-			r.GET(element.Endpoint, element.Handler)
+			r.GET(element.Endpoint, handlers...)
 		case (http.MethodPost):
-			r.POST(element.Endpoint, element.Handler)
+			r.POST(element.Endpoint, handlers...)
 		case (http.MethodPatch):
-			r.PATCH(element.Endpoint, element.Handler)
+			r.PATCH(element.Endpoint, handlers...)
 		case (http.MethodDelete):
-			r.DELETE(element.Endpoint, element.Handler)
+			r.DELETE(element.Endpoint, handlers...)
 		case (http.MethodPut):
-			r.PUT(element.Endpoint, element.Handler)
+			r.PUT(element.Endpoint, handlers...)
 		case (http.MethodOptions):
-			r.OPTIONS(element.Endpoint, element.Handler)
+			r.OPTIONS(element.Endpoint, handlers...)
 		}
 
 	}
@@ -41,6 +54,10 @@ func CheckRouteNeedsAdmin(endpoint string) bool {
 	return routePermissionMap[endpoint] >= 2
 }
 
+func GetRouteScope(endpoint string) string {
+	return routeScopeMap[endpoint]
+}
+
 func CreateDirectoryTree(path string) string {
 
 	path = DOCKER_PATH + "/" + path
@@ -2,6 +2,7 @@ package tools
 
 import (
 	"crypto/md5"
+	"crypto/sha256"
 	"encoding/hex"
 	"io/fs"
 	"os"
@@ -16,6 +17,12 @@ func GetMD5(input string) string {
 	return hex.EncodeToString(hash[:])
 }
 
+// 32 byte key derived from the salt passed to Init, purpose separates the keys of different users of it
+func DeriveKey(purpose string) []byte {
+	key := sha256.Sum256([]byte(secretSalt + ":" + purpose))
+	return key[:]
+}
+
 func WalkDir(root string, exts []string) ([]string, error) {
 
 	var files []string
@@ -1,9 +1,11 @@
 package tools
 
 import (
+	"encoding/json"
 	"reflect"
 
 	"github.com/gin-gonic/gin"
+	"github.com/sc-js/pour"
 )
 
 type translationOperator func(obj reflect.Value, locale string) interface{}
@@ -26,3 +28,64 @@ func tryTranslate(x interface{}, c *gin.Context) interface{} {
 
 	return TranslationCallback(val, getLocaleFromRequest(c))
 }
+
+// Translates key into the locale of the request and formats it with args, see FormatMessage.
+// Keys without a translation are returned as they are.
+func T(c *gin.Context, key string, args Args) string {
+	return TLocale(getLocaleFromRequest(c), key, args)
+}
+
+// Like T for a given locale, e.g. outside of requests
+func TLocale(locale string, key string, args Args) string {
+	text := key
+	if SingleTranslationCallback != nil {
+		if trans, err := SingleTranslationCallback(locale, key); err == nil && len(trans) > 0 {
+			text = trans
+		}
+	}
+	return formatTranslation(locale, text, args)
+}
+
+// Formats a translated text, invalid patterns are logged and returned unformatted
+func formatTranslation(locale string, text string, args Args) string {
+	if args == nil {
+		return text
+	}
+	formatted, err := FormatMessage(locale, text, args)
+	if err != nil {
+		pour.LogColor(false, pour.ColorRed, "Error formatting message:", err)
+	}
+	return formatted
+}
+
+// A translation key with arguments to be used in response payloads. It's translated and formatted
+// for the locale of the request wherever it appears, no trans tag needed, and encodes as the resulting string.
+type Message struct {
+	Key  string
+	Args Args
+	text string
+}
+
+func Msg(key string, args Args) Message {
+	return Message{Key: key, Args: args}
+}
+
+// Returns the message with translation formatted for locale, used by the TranslationCallback
+func (m Message) Localize(locale string, translation string) Message {
+	if len(translation) == 0 {
+		translation = m.Key
+	}
+	m.text = formatTranslation(locale, translation, m.Args)
+	return m
+}
+
+func (m Message) String() string {
+	if len(m.text) > 0 {
+		return m.text
+	}
+	return m.Key
+}
+
+func (m Message) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.String())
+}
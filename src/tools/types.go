@@ -14,9 +14,16 @@ func (i ModelID) MarshalBinary() ([]byte, error) {
 	return json.Marshal(i)
 }
 
+func (i *ModelID) UnmarshalBinary(data []byte) error {
+	return i.UnmarshalJSON(data)
+}
+
 var h *hashids.HashID
 
+var secretSalt string
+
 func Init(salt string) {
+	secretSalt = salt
 	hd := hashids.NewData()
 	hd.Salt = salt
 	hd.MinLength = 10